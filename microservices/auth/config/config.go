@@ -0,0 +1,441 @@
+// Package config loads the auth service settings. Later sources override
+// earlier ones: defaults, the optional .env file, environment variables and
+// finally command-line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"iLeon/microservices/auth/keyring"
+	"iLeon/microservices/auth/lockout"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/password"
+	"iLeon/microservices/auth/revocation"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/auth/totp"
+	"iLeon/microservices/natsrpc"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	NATS natsrpc.Config
+
+	MongoURI      string
+	MongoDatabase string
+	SecretKey     string
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// SigningAlgorithm is RS256, ES256 or EdDSA.
+	SigningAlgorithm    string
+	KeyRotationInterval time.Duration
+	// RevocationSyncInterval is how often revocations made by other
+	// replicas are picked up.
+	RevocationSyncInterval time.Duration
+	// ValidationCacheSize is the number of verified tokens auth.validateToken
+	// keeps in memory.
+	ValidationCacheSize int
+
+	// LoginMaxFailures and LoginIPMaxFailures are the failed logins per
+	// account and per client address that lock them for LockoutDuration,
+	// doubled on every further failure up to LockoutMaxDuration. Failures
+	// are forgotten after LockoutWindow without one.
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LockoutDuration    time.Duration
+	LockoutMaxDuration time.Duration
+	LockoutWindow      time.Duration
+
+	// PasswordMinLength, PasswordMaxLength (bytes) and PasswordMinClasses
+	// are the password policy; PasswordDenylist names a file of refused
+	// passwords, one per line.
+	PasswordMinLength  int
+	PasswordMaxLength  int
+	PasswordMinClasses int
+	PasswordDenylist   string
+	// PasswordHash is the algorithm new hashes are made with, "bcrypt" or
+	// "argon2id", with BcryptCost or the Argon2 parameters (memory in
+	// KiB). Weaker hashes are upgraded as users log in.
+	PasswordHash      string
+	BcryptCost        int
+	Argon2Memory      int
+	Argon2Iterations  int
+	Argon2Parallelism int
+
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	// RequireVerifiedEmail refuses logins until the user has verified
+	// their email address.
+	RequireVerifiedEmail bool
+	// MFAIssuer names the service in authenticator apps.
+	MFAIssuer string
+	// OutboxPublisher is "nats", or "log" to print events such as reset
+	// emails instead of publishing them, for local runs without a mailer.
+	OutboxPublisher string
+
+	ShutdownTimeout time.Duration
+}
+
+func defaults() *Config {
+	return &Config{
+		NATS:            natsrpc.DefaultConfig("auth"),
+		MongoDatabase:   "go-test",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+
+		SigningAlgorithm:    keyring.DefaultAlgorithm,
+		KeyRotationInterval: keyring.DefaultRotationInterval,
+
+		RevocationSyncInterval: revocation.DefaultSyncInterval,
+		ValidationCacheSize:    10000,
+
+		LoginMaxFailures:   lockout.DefaultAccountPolicy.Threshold,
+		LoginIPMaxFailures: lockout.DefaultIPPolicy.Threshold,
+		LockoutDuration:    lockout.DefaultAccountPolicy.Duration,
+		LockoutMaxDuration: lockout.DefaultAccountPolicy.MaxDuration,
+		LockoutWindow:      lockout.DefaultAccountPolicy.Window,
+
+		PasswordMinLength: password.DefaultPolicy.MinLength,
+		PasswordMaxLength: password.DefaultPolicy.MaxLength,
+		PasswordHash:      password.Bcrypt,
+		BcryptCost:        10,
+		Argon2Memory:      int(password.DefaultArgon2idParams.Memory),
+		Argon2Iterations:  int(password.DefaultArgon2idParams.Iterations),
+		Argon2Parallelism: int(password.DefaultArgon2idParams.Parallelism),
+
+		PasswordResetTTL:     models.DefaultPasswordResetTTL,
+		EmailVerificationTTL: models.DefaultEmailVerificationTTL,
+		MFAIssuer:            totp.DefaultIssuer,
+		OutboxPublisher:      "nats",
+
+		ShutdownTimeout: 15 * time.Second,
+	}
+}
+
+// Load builds the effective configuration from args (usually os.Args[1:]).
+// ENV_FILE points at the .env file; a missing file is not an error.
+func Load(args []string) (*Config, error) {
+	envFile := os.Getenv("ENV_FILE")
+	if envFile == "" {
+		envFile = ".env"
+	}
+	// godotenv never overrides variables that are already set, so the real
+	// environment wins over the file.
+	if err := godotenv.Load(envFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("loading %s: %w", envFile, err)
+	}
+
+	return load(os.Getenv, args)
+}
+
+func load(getenv func(string) string, args []string) (*Config, error) {
+	cfg := defaults()
+	if err := cfg.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	flags := flag.NewFlagSet("auth", flag.ContinueOnError)
+	cfg.registerFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	errs := []error{c.NATS.ApplyEnv(getenv)}
+
+	if v := getenv("MONGO_URI"); v != "" {
+		c.MongoURI = v
+	}
+	if v := getenv("MONGO_DATABASE"); v != "" {
+		c.MongoDatabase = v
+	}
+	if v := getenv("SECRET_KEY"); v != "" {
+		c.SecretKey = v
+	}
+	if v := getenv("SIGNING_ALGORITHM"); v != "" {
+		c.SigningAlgorithm = v
+	}
+	if v := getenv("PASSWORD_DENYLIST"); v != "" {
+		c.PasswordDenylist = v
+	}
+	if v := getenv("PASSWORD_HASH"); v != "" {
+		c.PasswordHash = v
+	}
+	if v := getenv("MFA_ISSUER"); v != "" {
+		c.MFAIssuer = v
+	}
+	if v := getenv("OUTBOX_PUBLISHER"); v != "" {
+		c.OutboxPublisher = v
+	}
+	errs = append(errs,
+		envInt(getenv, "VALIDATION_CACHE_SIZE", &c.ValidationCacheSize),
+		envInt(getenv, "LOGIN_MAX_FAILURES", &c.LoginMaxFailures),
+		envInt(getenv, "LOGIN_IP_MAX_FAILURES", &c.LoginIPMaxFailures),
+		envInt(getenv, "PASSWORD_MIN_LENGTH", &c.PasswordMinLength),
+		envInt(getenv, "PASSWORD_MAX_LENGTH", &c.PasswordMaxLength),
+		envInt(getenv, "PASSWORD_MIN_CLASSES", &c.PasswordMinClasses),
+		envInt(getenv, "BCRYPT_COST", &c.BcryptCost),
+		envInt(getenv, "ARGON2_MEMORY", &c.Argon2Memory),
+		envInt(getenv, "ARGON2_ITERATIONS", &c.Argon2Iterations),
+		envInt(getenv, "ARGON2_PARALLELISM", &c.Argon2Parallelism),
+		envDuration(getenv, "ACCESS_TOKEN_TTL", &c.AccessTokenTTL),
+		envDuration(getenv, "REFRESH_TOKEN_TTL", &c.RefreshTokenTTL),
+		envDuration(getenv, "KEY_ROTATION_INTERVAL", &c.KeyRotationInterval),
+		envDuration(getenv, "REVOCATION_SYNC_INTERVAL", &c.RevocationSyncInterval),
+		envDuration(getenv, "LOCKOUT_DURATION", &c.LockoutDuration),
+		envDuration(getenv, "LOCKOUT_MAX_DURATION", &c.LockoutMaxDuration),
+		envDuration(getenv, "LOCKOUT_WINDOW", &c.LockoutWindow),
+		envDuration(getenv, "PASSWORD_RESET_TTL", &c.PasswordResetTTL),
+		envDuration(getenv, "EMAIL_VERIFICATION_TTL", &c.EmailVerificationTTL),
+		envBool(getenv, "REQUIRE_VERIFIED_EMAIL", &c.RequireVerifiedEmail),
+		envDuration(getenv, "SHUTDOWN_TIMEOUT", &c.ShutdownTimeout),
+	)
+
+	return errors.Join(errs...)
+}
+
+func envDuration(getenv func(string) string, key string, dst *time.Duration) error {
+	v := getenv(key)
+	if v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
+
+func envInt(getenv func(string) string, key string, dst *int) error {
+	v := getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envBool(getenv func(string) string, key string, dst *bool) error {
+	v := getenv(key)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+func (c *Config) registerFlags(fs *flag.FlagSet) {
+	c.NATS.RegisterFlags(fs)
+	fs.StringVar(&c.MongoURI, "mongo-uri", c.MongoURI, "MongoDB connection string")
+	fs.StringVar(&c.MongoDatabase, "mongo-database", c.MongoDatabase, "MongoDB database name")
+	fs.StringVar(&c.SecretKey, "secret-key", c.SecretKey, "secret sealing the signing keys and MFA secrets stored in Mongo")
+	fs.DurationVar(&c.AccessTokenTTL, "access-token-ttl", c.AccessTokenTTL, "lifetime of issued access tokens")
+	fs.DurationVar(&c.RefreshTokenTTL, "refresh-token-ttl", c.RefreshTokenTTL, "lifetime of issued refresh tokens")
+	fs.StringVar(&c.SigningAlgorithm, "signing-algorithm", c.SigningAlgorithm, "JWT signing algorithm: RS256, ES256 or EdDSA")
+	fs.DurationVar(&c.KeyRotationInterval, "key-rotation-interval", c.KeyRotationInterval, "how long each signing key signs before the next takes over")
+	fs.DurationVar(&c.RevocationSyncInterval, "revocation-sync-interval", c.RevocationSyncInterval, "how often revocations from other replicas are loaded")
+	fs.IntVar(&c.ValidationCacheSize, "validation-cache-size", c.ValidationCacheSize, "verified tokens kept in memory by auth.validateToken (0 disables)")
+	fs.IntVar(&c.LoginMaxFailures, "login-max-failures", c.LoginMaxFailures, "failed logins that lock an account")
+	fs.IntVar(&c.LoginIPMaxFailures, "login-ip-max-failures", c.LoginIPMaxFailures, "failed logins that lock a client address")
+	fs.DurationVar(&c.LockoutDuration, "lockout-duration", c.LockoutDuration, "first lock, doubled on every further failure")
+	fs.DurationVar(&c.LockoutMaxDuration, "lockout-max-duration", c.LockoutMaxDuration, "longest lock")
+	fs.DurationVar(&c.LockoutWindow, "lockout-window", c.LockoutWindow, "how long failed logins are remembered")
+	fs.IntVar(&c.PasswordMinLength, "password-min-length", c.PasswordMinLength, "shortest password, in characters")
+	fs.IntVar(&c.PasswordMaxLength, "password-max-length", c.PasswordMaxLength, "longest password, in bytes (at most 72 with bcrypt)")
+	fs.IntVar(&c.PasswordMinClasses, "password-min-classes", c.PasswordMinClasses, "character classes (lower, upper, digit, symbol) a password must mix")
+	fs.StringVar(&c.PasswordDenylist, "password-denylist", c.PasswordDenylist, "file of refused passwords, one per line")
+	fs.StringVar(&c.PasswordHash, "password-hash", c.PasswordHash, "password hash algorithm: bcrypt or argon2id")
+	fs.IntVar(&c.BcryptCost, "bcrypt-cost", c.BcryptCost, "bcrypt cost of new password hashes")
+	fs.IntVar(&c.Argon2Memory, "argon2-memory", c.Argon2Memory, "argon2id memory of new password hashes, in KiB")
+	fs.IntVar(&c.Argon2Iterations, "argon2-iterations", c.Argon2Iterations, "argon2id iterations of new password hashes")
+	fs.IntVar(&c.Argon2Parallelism, "argon2-parallelism", c.Argon2Parallelism, "argon2id lanes of new password hashes")
+	fs.DurationVar(&c.PasswordResetTTL, "password-reset-ttl", c.PasswordResetTTL, "lifetime of password reset tokens")
+	fs.DurationVar(&c.EmailVerificationTTL, "email-verification-ttl", c.EmailVerificationTTL, "lifetime of email verification tokens")
+	fs.BoolVar(&c.RequireVerifiedEmail, "require-verified-email", c.RequireVerifiedEmail, "refuse logins until the email is verified")
+	fs.StringVar(&c.MFAIssuer, "mfa-issuer", c.MFAIssuer, "service name shown in authenticator apps")
+	fs.StringVar(&c.OutboxPublisher, "outbox-publisher", c.OutboxPublisher, "where events go: nats, or log for local runs")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time in-flight requests get to finish on shutdown")
+}
+
+func (c *Config) Validate() error {
+	errs := []error{c.NATS.Validate()}
+
+	if c.MongoURI == "" {
+		errs = append(errs, errors.New("MONGO_URI is required"))
+	}
+	if c.MongoDatabase == "" {
+		errs = append(errs, errors.New("MONGO_DATABASE is required"))
+	}
+	if c.SecretKey == "" {
+		errs = append(errs, errors.New("SECRET_KEY is required"))
+	}
+	if c.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("ACCESS_TOKEN_TTL must be positive"))
+	}
+	if c.RefreshTokenTTL <= c.AccessTokenTTL {
+		errs = append(errs, errors.New("REFRESH_TOKEN_TTL must be longer than ACCESS_TOKEN_TTL"))
+	}
+	if !token.ValidAlgorithm(c.SigningAlgorithm) {
+		errs = append(errs, fmt.Errorf("SIGNING_ALGORITHM must be RS256, ES256 or EdDSA, got %q", c.SigningAlgorithm))
+	}
+	if c.KeyRotationInterval < time.Hour {
+		errs = append(errs, errors.New("KEY_ROTATION_INTERVAL must be at least 1h"))
+	}
+	if c.RevocationSyncInterval <= 0 {
+		errs = append(errs, errors.New("REVOCATION_SYNC_INTERVAL must be positive"))
+	}
+	if c.ValidationCacheSize < 0 {
+		errs = append(errs, errors.New("VALIDATION_CACHE_SIZE must not be negative"))
+	}
+	if c.LoginMaxFailures < 1 || c.LoginIPMaxFailures < 1 {
+		errs = append(errs, errors.New("LOGIN_MAX_FAILURES and LOGIN_IP_MAX_FAILURES must be at least 1"))
+	}
+	if c.LockoutDuration <= 0 || c.LockoutMaxDuration < c.LockoutDuration {
+		errs = append(errs, errors.New("LOCKOUT_DURATION must be positive and at most LOCKOUT_MAX_DURATION"))
+	}
+	if c.LockoutWindow <= 0 {
+		errs = append(errs, errors.New("LOCKOUT_WINDOW must be positive"))
+	}
+	if c.PasswordMinLength < 1 || c.PasswordMaxLength < c.PasswordMinLength {
+		errs = append(errs, errors.New("PASSWORD_MIN_LENGTH must be at least 1 and at most PASSWORD_MAX_LENGTH"))
+	}
+	if c.PasswordHash == password.Bcrypt && c.PasswordMaxLength > password.BcryptMaxLength {
+		errs = append(errs, fmt.Errorf("PASSWORD_MAX_LENGTH must be at most %d with bcrypt", password.BcryptMaxLength))
+	}
+	if c.PasswordMinClasses < 0 || c.PasswordMinClasses > 4 {
+		errs = append(errs, errors.New("PASSWORD_MIN_CLASSES must be between 0 and 4"))
+	}
+	if _, err := c.PasswordHasher(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.PasswordResetTTL <= 0 || c.EmailVerificationTTL <= 0 {
+		errs = append(errs, errors.New("PASSWORD_RESET_TTL and EMAIL_VERIFICATION_TTL must be positive"))
+	}
+	if c.MFAIssuer == "" || strings.Contains(c.MFAIssuer, ":") {
+		errs = append(errs, errors.New("MFA_ISSUER must be set and must not contain a colon"))
+	}
+	if c.OutboxPublisher != "nats" && c.OutboxPublisher != "log" {
+		errs = append(errs, fmt.Errorf("OUTBOX_PUBLISHER must be nats or log, got %q", c.OutboxPublisher))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// String renders the effective configuration with secrets masked, for the
+// startup log.
+func (c *Config) String() string {
+	lines := append(c.NATS.Redacted(),
+		"MONGO_URI="+natsrpc.RedactURL(c.MongoURI),
+		"MONGO_DATABASE="+c.MongoDatabase,
+		"SECRET_KEY="+redact(c.SecretKey),
+		"ACCESS_TOKEN_TTL="+c.AccessTokenTTL.String(),
+		"REFRESH_TOKEN_TTL="+c.RefreshTokenTTL.String(),
+		"SIGNING_ALGORITHM="+c.SigningAlgorithm,
+		"KEY_ROTATION_INTERVAL="+c.KeyRotationInterval.String(),
+		"REVOCATION_SYNC_INTERVAL="+c.RevocationSyncInterval.String(),
+		"VALIDATION_CACHE_SIZE="+strconv.Itoa(c.ValidationCacheSize),
+		"LOGIN_MAX_FAILURES="+strconv.Itoa(c.LoginMaxFailures),
+		"LOGIN_IP_MAX_FAILURES="+strconv.Itoa(c.LoginIPMaxFailures),
+		"LOCKOUT_DURATION="+c.LockoutDuration.String(),
+		"LOCKOUT_MAX_DURATION="+c.LockoutMaxDuration.String(),
+		"LOCKOUT_WINDOW="+c.LockoutWindow.String(),
+		"PASSWORD_MIN_LENGTH="+strconv.Itoa(c.PasswordMinLength),
+		"PASSWORD_MAX_LENGTH="+strconv.Itoa(c.PasswordMaxLength),
+		"PASSWORD_MIN_CLASSES="+strconv.Itoa(c.PasswordMinClasses),
+		"PASSWORD_DENYLIST="+c.PasswordDenylist,
+		"PASSWORD_HASH="+c.PasswordHash,
+		"BCRYPT_COST="+strconv.Itoa(c.BcryptCost),
+		"ARGON2_MEMORY="+strconv.Itoa(c.Argon2Memory),
+		"ARGON2_ITERATIONS="+strconv.Itoa(c.Argon2Iterations),
+		"ARGON2_PARALLELISM="+strconv.Itoa(c.Argon2Parallelism),
+		"PASSWORD_RESET_TTL="+c.PasswordResetTTL.String(),
+		"EMAIL_VERIFICATION_TTL="+c.EmailVerificationTTL.String(),
+		"REQUIRE_VERIFIED_EMAIL="+strconv.FormatBool(c.RequireVerifiedEmail),
+		"MFA_ISSUER="+c.MFAIssuer,
+		"OUTBOX_PUBLISHER="+c.OutboxPublisher,
+		"SHUTDOWN_TIMEOUT="+c.ShutdownTimeout.String(),
+	)
+	return strings.Join(lines, "\n")
+}
+
+// Lockout returns the failed login policies.
+func (c *Config) Lockout() lockout.Config {
+	account := lockout.Policy{
+		Threshold:   c.LoginMaxFailures,
+		Duration:    c.LockoutDuration,
+		MaxDuration: c.LockoutMaxDuration,
+		Window:      c.LockoutWindow,
+	}
+	ip := account
+	ip.Threshold = c.LoginIPMaxFailures
+	return lockout.Config{Account: account, IP: ip}
+}
+
+// PasswordPolicy returns the policy new passwords must follow, loading the
+// denylist file if one is set.
+func (c *Config) PasswordPolicy() (*password.Policy, error) {
+	policy := &password.Policy{
+		MinLength:  c.PasswordMinLength,
+		MaxLength:  c.PasswordMaxLength,
+		MinClasses: c.PasswordMinClasses,
+	}
+	if c.PasswordDenylist != "" {
+		list, err := password.LoadDenylist(c.PasswordDenylist)
+		if err != nil {
+			return nil, fmt.Errorf("PASSWORD_DENYLIST: %w", err)
+		}
+		policy.Denylist = list
+	}
+	return policy, nil
+}
+
+// PasswordHasher returns the hasher new password hashes are made with.
+func (c *Config) PasswordHasher() (password.Hasher, error) {
+	if c.Argon2Memory < 1 || int64(c.Argon2Memory) > math.MaxUint32 || c.Argon2Iterations < 1 || int64(c.Argon2Iterations) > math.MaxUint32 ||
+		c.Argon2Parallelism < 1 || c.Argon2Parallelism > math.MaxUint8 {
+		return nil, errors.New("ARGON2_MEMORY and ARGON2_ITERATIONS must be positive, ARGON2_PARALLELISM between 1 and 255")
+	}
+	argon := password.DefaultArgon2idParams
+	argon.Memory = uint32(c.Argon2Memory)
+	argon.Iterations = uint32(c.Argon2Iterations)
+	argon.Parallelism = uint8(c.Argon2Parallelism)
+
+	hasher, err := password.NewHasher(c.PasswordHash, c.BcryptCost, argon)
+	if err != nil {
+		return nil, fmt.Errorf("PASSWORD_HASH: %w", err)
+	}
+	return hasher, nil
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "xxxxx"
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"context"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/service"
+	"iLeon/microservices/natsrpc"
+)
+
+// The account patterns act on the user of the access token in the
+// Authorization header, except auth.confirmEmailChange, which is reached
+// from the mailed link.
+
+func GetMe(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.getMe", func(ctx context.Context, _ struct{}) (*models.Profile, error) {
+		return s.GetMe(ctx, natsrpc.BearerToken(ctx))
+	})
+}
+
+func UpdateProfile(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.updateProfile", func(ctx context.Context, body models.UpdateProfileBody) (*models.Profile, error) {
+		return s.UpdateProfile(ctx, natsrpc.BearerToken(ctx), body)
+	})
+}
+
+func ChangeEmail(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.changeEmail", func(ctx context.Context, body models.ChangeEmailBody) (*models.CustomeResponse, error) {
+		return s.ChangeEmail(ctx, natsrpc.BearerToken(ctx), body)
+	})
+}
+
+func ConfirmEmailChange(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.confirmEmailChange", func(ctx context.Context, body models.ConfirmEmailChangeBody) (*models.CustomeResponse, error) {
+		return s.ConfirmEmailChange(ctx, body)
+	})
+}
+
+func ChangePassword(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.changePassword", func(ctx context.Context, body models.ChangePasswordBody) (*models.CustomeResponse, error) {
+		return s.ChangePassword(ctx, natsrpc.BearerToken(ctx), body)
+	})
+}
+
+func DeleteAccount(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.deleteAccount", func(ctx context.Context, body models.DeleteAccountBody) (*models.CustomeResponse, error) {
+		return s.DeleteAccount(ctx, natsrpc.BearerToken(ctx), body)
+	})
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/rbac"
+	"iLeon/microservices/auth/service"
+	"iLeon/microservices/natsrpc"
+)
+
+func AssignRole(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.assignRole", func(ctx context.Context, body models.RoleBody) (*models.UserRoles, error) {
+		return s.AssignRole(ctx, body)
+	}, natsrpc.RequirePermission(rbac.UsersRoles))
+}
+
+func RevokeRole(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.revokeRole", func(ctx context.Context, body models.RoleBody) (*models.UserRoles, error) {
+		return s.RevokeRole(ctx, body)
+	}, natsrpc.RequirePermission(rbac.UsersRoles))
+}
+
+func UnlockAccount(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.unlockAccount", func(ctx context.Context, body models.UnlockAccountBody) (*models.CustomeResponse, error) {
+		return s.UnlockAccount(ctx, body)
+	}, natsrpc.RequirePermission(rbac.UsersWrite))
+}
+
+func ListUsers(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.listUsers", func(ctx context.Context, query models.UserQuery) (*models.UserPage, error) {
+		return s.ListUsers(ctx, query)
+	}, natsrpc.RequirePermission(rbac.UsersRead))
+}
+
+func GetUser(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.getUser", func(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error) {
+		return s.GetUser(ctx, body)
+	}, natsrpc.RequirePermission(rbac.UsersRead))
+}
+
+func DisableUser(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.disableUser", func(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error) {
+		return s.DisableUser(ctx, body)
+	}, natsrpc.RequirePermission(rbac.UsersWrite))
+}
+
+func EnableUser(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.enableUser", func(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error) {
+		return s.EnableUser(ctx, body)
+	}, natsrpc.RequirePermission(rbac.UsersWrite))
+}
+
+func ForcePasswordReset(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.forcePasswordReset", func(ctx context.Context, body models.UserIDBody) (*models.CustomeResponse, error) {
+		return s.ForcePasswordReset(ctx, body)
+	}, natsrpc.RequirePermission(rbac.UsersWrite))
+}
+
+func DeleteUser(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.deleteUser", func(ctx context.Context, body models.UserIDBody) (*models.CustomeResponse, error) {
+		return s.DeleteUser(ctx, body)
+	}, natsrpc.RequirePermission(rbac.UsersDelete))
+}
+
+func AuditLog(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.admin.auditLog", func(ctx context.Context, query models.AuditQuery) ([]models.AuditRecord, error) {
+		return s.AuditLog(ctx, query)
+	}, natsrpc.RequirePermission(rbac.UsersRead))
+}
@@ -1,76 +1,103 @@
 package controller
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"iLeon/microservices/auth/models"
 	"iLeon/microservices/auth/service"
-
-	"github.com/nats-io/nats.go"
+	"iLeon/microservices/natsrpc"
 )
 
-// NestJS ClientProxy wraps messages as {"pattern":..., "data":..., "id":...}
-// and expects responses as {"response":..., "id":..., "isDisposed":true, "err":null}
-type natsRequest struct {
-	ID   string          `json:"id"`
-	Data json.RawMessage `json:"data"`
+// Authenticator lets handlers registered with natsrpc.RequirePermission
+// check tokens against this service directly, without a NATS round trip.
+func Authenticator(s service.AuthService) natsrpc.Authenticator {
+	return func(ctx context.Context, token string) (*natsrpc.Principal, error) {
+		res, err := s.ValidateToken(ctx, models.ValidateTokenBody{Token: token})
+		if err != nil {
+			return nil, err
+		}
+		if !res.Active {
+			return nil, natsrpc.NewError(natsrpc.CodeUnauthenticated, "Invalid or expired access token")
+		}
+
+		return &natsrpc.Principal{
+			Subject:     res.UserID,
+			Email:       res.Email,
+			Roles:       res.Roles,
+			Permissions: res.Scopes,
+		}, nil
+	}
 }
 
-type natsResponse struct {
-	Response   any    `json:"response"`
-	ID         string `json:"id"`
-	IsDisposed bool   `json:"isDisposed"`
-	Err        any    `json:"err"`
+func LoginUser(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.loginUser", func(ctx context.Context, body models.LoginUserBody) (*models.TokenResponse, error) {
+		return s.LoginUser(ctx, body)
+	})
 }
 
-func reply(nc *nats.Conn, msg *nats.Msg, id string, response any) {
-	data, _ := json.Marshal(natsResponse{
-		Response:   response,
-		ID:         id,
-		IsDisposed: true,
-		Err:        nil,
+func RegisterUser(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.registerUser", func(ctx context.Context, body models.CreateUserBody) (*models.CustomeResponse, error) {
+		return s.RegisterUser(ctx, body)
 	})
-	nc.Publish(msg.Reply, data)
 }
 
-func LoginUser(nc *nats.Conn, s service.AuthService) {
-	nc.Subscribe("auth.loginUser", func(msg *nats.Msg) {
-		var req natsRequest
-		if err := json.Unmarshal(msg.Data, &req); err != nil {
-			fmt.Println("Couldn't unmarshal NATS request:", err)
-			return
-		}
+func RequestPasswordReset(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.requestPasswordReset", func(ctx context.Context, body models.PasswordResetRequestBody) (*models.CustomeResponse, error) {
+		return s.RequestPasswordReset(ctx, body)
+	})
+}
 
-		var body models.LoginUserBody
-		if err := json.Unmarshal(req.Data, &body); err != nil {
-			fmt.Println("Couldn't unmarshal login payload:", err)
-			return
-		}
+func ResetPassword(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.resetPassword", func(ctx context.Context, body models.ResetPasswordBody) (*models.CustomeResponse, error) {
+		return s.ResetPassword(ctx, body)
+	})
+}
 
-		response := s.LoginUser(body)
-		reply(nc, msg, req.ID, response)
+func VerifyEmail(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.verifyEmail", func(ctx context.Context, body models.VerifyEmailBody) (*models.CustomeResponse, error) {
+		return s.VerifyEmail(ctx, body)
 	})
+}
 
-	nc.Flush()
+func ResendVerification(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.resendVerification", func(ctx context.Context, body models.ResendVerificationBody) (*models.CustomeResponse, error) {
+		return s.ResendVerification(ctx, body)
+	})
 }
 
-func RegisterUser(nc *nats.Conn, s service.AuthService) {
-	nc.Subscribe("auth.registerUser", func(msg *nats.Msg) {
-		var req natsRequest
-		if err := json.Unmarshal(msg.Data, &req); err != nil {
-			fmt.Println("Couldn't unmarshal NATS request:", err)
-			return
-		}
+func RefreshToken(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.refreshToken", func(ctx context.Context, body models.RefreshTokenBody) (*models.TokenResponse, error) {
+		return s.RefreshToken(ctx, body)
+	})
+}
 
-		var body models.CreateUserBody
-		if err := json.Unmarshal(req.Data, &body); err != nil {
-			fmt.Println("Couldn't unmarshal register payload:", err)
-			return
-		}
+// ListSessions authenticates with the access token in the Authorization
+// header.
+func ListSessions(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.listSessions", func(ctx context.Context, _ struct{}) ([]models.Session, error) {
+		return s.ListSessions(ctx, natsrpc.BearerToken(ctx))
+	})
+}
 
-		response := s.RegisterUser(body)
-		reply(nc, msg, req.ID, response)
+func Logout(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.logout", func(ctx context.Context, _ struct{}) (*models.CustomeResponse, error) {
+		return s.Logout(ctx, natsrpc.BearerToken(ctx))
 	})
+}
 
-	nc.Flush()
+func RevokeAllSessions(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.revokeAllSessions", func(ctx context.Context, _ struct{}) (*models.CustomeResponse, error) {
+		return s.RevokeAllSessions(ctx, natsrpc.BearerToken(ctx))
+	})
+}
+
+func ValidateToken(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.validateToken", func(ctx context.Context, body models.ValidateTokenBody) (*models.TokenValidation, error) {
+		return s.ValidateToken(ctx, body)
+	})
+}
+
+func JWKS(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.jwks", func(ctx context.Context, _ struct{}) (*models.JWKS, error) {
+		return s.JWKS(ctx)
+	})
 }
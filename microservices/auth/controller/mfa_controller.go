@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"context"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/service"
+	"iLeon/microservices/natsrpc"
+)
+
+// MFAEnroll, MFAConfirm and MFADisable authenticate with the access token
+// in the Authorization header.
+func MFAEnroll(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.mfa.enroll", func(ctx context.Context, _ struct{}) (*models.MFAEnrollment, error) {
+		return s.EnrollMFA(ctx, natsrpc.BearerToken(ctx))
+	})
+}
+
+func MFAConfirm(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.mfa.confirm", func(ctx context.Context, body models.MFACodeBody) (*models.RecoveryCodes, error) {
+		return s.ConfirmMFA(ctx, natsrpc.BearerToken(ctx), body)
+	})
+}
+
+func MFADisable(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.mfa.disable", func(ctx context.Context, body models.MFACodeBody) (*models.CustomeResponse, error) {
+		return s.DisableMFA(ctx, natsrpc.BearerToken(ctx), body)
+	})
+}
+
+// MFAVerify is the second step of a login for users with MFA enabled.
+func MFAVerify(srv *natsrpc.Server, s service.AuthService) error {
+	return natsrpc.Handle(srv, "auth.mfa.verify", func(ctx context.Context, body models.MFAVerifyBody) (*models.TokenResponse, error) {
+		return s.VerifyMFA(ctx, body)
+	})
+}
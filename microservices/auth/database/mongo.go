@@ -3,10 +3,6 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
-
-	"github.com/joho/godotenv"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -17,23 +13,25 @@ type MongoInstance struct {
 	Db     *mongo.Database
 }
 
-func Connect() (*MongoInstance, error) {
-
-	if err := godotenv.Load(".env"); err != nil {
-		log.Fatal("Can't reload env variables")
-	}
-	mongo_uri := os.Getenv("MONGO_URI")
+// Connect opens the client and pings the server so a bad URI fails at
+// startup rather than on the first request.
+func Connect(ctx context.Context, uri, database string) (*MongoInstance, error) {
 
-	client, err := mongo.Connect(context.TODO(), options.Client().
-		ApplyURI(mongo_uri))
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(uri))
 
 	if err != nil {
-		log.Fatal("Can't connect to mongo database")
+		return nil, fmt.Errorf("Can't connect to mongo database: %w", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("Can't reach mongo database: %w", err)
 	}
 
 	fmt.Println("Connected to the database successfully")
 
-	db := client.Database("go-test")
+	db := client.Database(database)
 
 	mg := &MongoInstance{
 		Client: client,
@@ -43,3 +41,7 @@ func Connect() (*MongoInstance, error) {
 	return mg, nil
 
 }
+
+func (mg *MongoInstance) Close(ctx context.Context) error {
+	return mg.Client.Disconnect(ctx)
+}
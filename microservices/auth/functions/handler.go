@@ -1,13 +1,45 @@
 package functions
 
 import (
+	"errors"
 	"iLeon/microservices/auth/controller"
 	"iLeon/microservices/auth/service"
-
-	"github.com/nats-io/nats.go"
+	"iLeon/microservices/natsrpc"
 )
 
-func Handler(n *nats.Conn, service service.AuthService) {
-	controller.LoginUser(n, service)
-	controller.RegisterUser(n, service)
+func Handler(srv *natsrpc.Server, service service.AuthService) error {
+	return errors.Join(
+		controller.LoginUser(srv, service),
+		controller.RegisterUser(srv, service),
+		controller.RefreshToken(srv, service),
+		controller.RequestPasswordReset(srv, service),
+		controller.ResetPassword(srv, service),
+		controller.VerifyEmail(srv, service),
+		controller.ResendVerification(srv, service),
+		controller.GetMe(srv, service),
+		controller.UpdateProfile(srv, service),
+		controller.ChangeEmail(srv, service),
+		controller.ConfirmEmailChange(srv, service),
+		controller.ChangePassword(srv, service),
+		controller.DeleteAccount(srv, service),
+		controller.MFAEnroll(srv, service),
+		controller.MFAConfirm(srv, service),
+		controller.MFADisable(srv, service),
+		controller.MFAVerify(srv, service),
+		controller.ListSessions(srv, service),
+		controller.Logout(srv, service),
+		controller.RevokeAllSessions(srv, service),
+		controller.ValidateToken(srv, service),
+		controller.JWKS(srv, service),
+		controller.AssignRole(srv, service),
+		controller.RevokeRole(srv, service),
+		controller.UnlockAccount(srv, service),
+		controller.ListUsers(srv, service),
+		controller.GetUser(srv, service),
+		controller.DisableUser(srv, service),
+		controller.EnableUser(srv, service),
+		controller.ForcePasswordReset(srv, service),
+		controller.DeleteUser(srv, service),
+		controller.AuditLog(srv, service),
+	)
 }
@@ -0,0 +1,240 @@
+// Package keyring keeps the JWT signing keys in Mongo and rotates them.
+//
+// Time is cut into rotation periods; each period has its own key, named
+// after the algorithm and the period start. The key for the next period is
+// created and published ahead of time, so verifiers that cache the key set
+// know it before the first token signed with it shows up. A key stays
+// published until every token it signed has expired. Private keys are
+// sealed with a key derived from the service secret before they are stored.
+package keyring
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/secretbox"
+	"iLeon/microservices/auth/token"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAlgorithm        = token.ES256
+	DefaultRotationInterval = 30 * 24 * time.Hour
+	DefaultRefreshInterval  = time.Minute
+
+	// publishAheadFraction of a rotation period before it starts, the
+	// period's key is created and published.
+	publishAheadFraction = 10
+
+	kidTimeFormat = "20060102T150405Z"
+)
+
+type Config struct {
+	Algorithm        string
+	RotationInterval time.Duration
+	// TokenLifetime is the access token lifetime; a key stays published
+	// that long after it stops signing.
+	TokenLifetime time.Duration
+	// Secret seals the private keys at rest.
+	Secret []byte
+}
+
+// Ring is a token.KeySet backed by Mongo.
+type Ring struct {
+	repo repository.KeyRepository
+	cfg  Config
+	box  *secretbox.Box
+	now  func() time.Time
+
+	mu   sync.RWMutex
+	keys []*token.Key // newest NotBefore first
+}
+
+var _ token.KeySet = (*Ring)(nil)
+
+func New(repo repository.KeyRepository, cfg Config) (*Ring, error) {
+	if !token.ValidAlgorithm(cfg.Algorithm) {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", cfg.Algorithm)
+	}
+
+	box, err := secretbox.New(cfg.Secret, "auth signing keys")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ring{repo: repo, cfg: cfg, box: box, now: time.Now}, nil
+}
+
+// Signing returns the newest key of the configured algorithm that has
+// started signing.
+func (r *Ring) Signing() (*token.Key, error) {
+	now := r.now()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.Algorithm == r.cfg.Algorithm && !k.NotBefore.After(now) {
+			return k, nil
+		}
+	}
+	return nil, errors.New("no signing key loaded")
+}
+
+func (r *Ring) Lookup(kid string) (*token.Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.ID == kid {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Ring) Published() []*token.Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*token.Key(nil), r.keys...)
+}
+
+// Refresh creates the keys for the current and, once it is near, the next
+// rotation period if no replica has yet, then reloads the ring from Mongo.
+func (r *Ring) Refresh(ctx context.Context) error {
+	now := r.now()
+	interval := r.cfg.RotationInterval
+
+	current := now.Truncate(interval)
+	starts := []time.Time{current}
+	if next := current.Add(interval); !now.Before(next.Add(-interval / publishAheadFraction)) {
+		starts = append(starts, next)
+	}
+
+	if err := r.load(ctx); err != nil {
+		return err
+	}
+
+	missing := false
+	for _, start := range starts {
+		if _, ok := r.Lookup(r.kid(start)); ok {
+			continue
+		}
+		missing = true
+		if err := r.create(ctx, start); err != nil {
+			return err
+		}
+	}
+	if !missing {
+		return nil
+	}
+	return r.load(ctx)
+}
+
+func (r *Ring) kid(start time.Time) string {
+	return r.cfg.Algorithm + "-" + start.UTC().Format(kidTimeFormat)
+}
+
+func (r *Ring) create(ctx context.Context, start time.Time) error {
+	key, err := token.GenerateKey(r.kid(start), r.cfg.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return fmt.Errorf("encoding key %s: %w", key.ID, err)
+	}
+	sealed, err := r.seal(key.ID, der)
+	if err != nil {
+		return err
+	}
+
+	created, err := r.repo.InsertSigningKey(ctx, models.SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: sealed,
+		CreatedAt:  r.now(),
+		NotBefore:  start,
+		// Tokens signed at the very end of the period stay valid for a
+		// token lifetime; replicas may notice the next key a refresh late.
+		ExpiresAt: start.Add(r.cfg.RotationInterval + r.cfg.TokenLifetime + DefaultRefreshInterval),
+	})
+	if err != nil {
+		return fmt.Errorf("storing key %s: %w", key.ID, err)
+	}
+	if created {
+		log.Printf("Created signing key %s, signing from %s", key.ID, start.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (r *Ring) load(ctx context.Context) error {
+	docs, err := r.repo.ListSigningKeys(ctx, r.now())
+	if err != nil {
+		return fmt.Errorf("loading signing keys: %w", err)
+	}
+
+	keys := make([]*token.Key, 0, len(docs))
+	for _, doc := range docs {
+		der, err := r.open(doc.ID, doc.PrivateKey)
+		if err != nil {
+			return err
+		}
+		private, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return fmt.Errorf("decoding key %s: %w", doc.ID, err)
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("key %s is not a signing key", doc.ID)
+		}
+
+		keys = append(keys, &token.Key{
+			ID:        doc.ID,
+			Algorithm: doc.Algorithm,
+			Private:   signer,
+			NotBefore: doc.NotBefore,
+			ExpiresAt: doc.ExpiresAt,
+		})
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.mu.Unlock()
+	return nil
+}
+
+// seal encrypts a private key, binding the ciphertext to its kid.
+func (r *Ring) seal(kid string, plaintext []byte) ([]byte, error) {
+	return r.box.Seal(plaintext, []byte(kid))
+}
+
+func (r *Ring) open(kid string, sealed []byte) ([]byte, error) {
+	plaintext, err := r.box.Open(sealed, []byte(kid))
+	if err != nil {
+		return nil, fmt.Errorf("unsealing key %s (was SECRET_KEY changed?): %w", kid, err)
+	}
+	return plaintext, nil
+}
+
+// Run refreshes the ring every interval until ctx is done.
+func (r *Ring) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Println("Couldn't refresh signing keys:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package keyring
+
+import (
+	"context"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/token"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ── In-memory KeyRepository ─────────────────────────────────────────────────
+
+type memRepo struct {
+	docs    map[string]models.SigningKey
+	inserts int
+}
+
+func newMemRepo() *memRepo { return &memRepo{docs: map[string]models.SigningKey{}} }
+
+func (m *memRepo) ListSigningKeys(_ context.Context, now time.Time) ([]models.SigningKey, error) {
+	var out []models.SigningKey
+	for _, k := range m.docs {
+		if k.ExpiresAt.After(now) {
+			out = append(out, k)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NotBefore.After(out[j].NotBefore) })
+	return out, nil
+}
+
+func (m *memRepo) InsertSigningKey(_ context.Context, k models.SigningKey) (bool, error) {
+	if _, ok := m.docs[k.ID]; ok {
+		return false, nil
+	}
+	m.inserts++
+	m.docs[k.ID] = k
+	return true, nil
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+var ctx = context.Background()
+
+// start is the beginning of a 10 day rotation period.
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Truncate(10 * 24 * time.Hour)
+
+func newRing(t *testing.T, repo *memRepo, secret string, at *time.Time) *Ring {
+	t.Helper()
+	r, err := New(repo, Config{
+		Algorithm:        token.ES256,
+		RotationInterval: 10 * 24 * time.Hour,
+		TokenLifetime:    15 * time.Minute,
+		Secret:           []byte(secret),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return *at }
+	return r
+}
+
+func ids(keys []*token.Key) []string {
+	var out []string
+	for _, k := range keys {
+		out = append(out, k.ID)
+	}
+	return out
+}
+
+// ── Tests ────────────────────────────────────────────────────────────────────
+
+func TestRefresh_CreatesKeyForCurrentPeriod(t *testing.T) {
+	now := start.Add(time.Hour)
+	r := newRing(t, newMemRepo(), "secret", &now)
+
+	if err := r.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := r.Signing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID != "ES256-"+start.Format(kidTimeFormat) || !key.NotBefore.Equal(start) {
+		t.Errorf("unexpected signing key %s from %v", key.ID, key.NotBefore)
+	}
+	if got := ids(r.Published()); len(got) != 1 {
+		t.Errorf("expected only the current key, got %v", got)
+	}
+}
+
+func TestRefresh_PublishesNextKeyBeforeUsingIt(t *testing.T) {
+	now := start.Add(9*24*time.Hour + time.Hour)
+	r := newRing(t, newMemRepo(), "secret", &now)
+
+	r.Refresh(ctx)
+
+	current, _ := r.Signing()
+	if len(r.Published()) != 2 || !current.NotBefore.Equal(start) {
+		t.Fatalf("expected the next key published but not signing, got %v signing with %s", ids(r.Published()), current.ID)
+	}
+
+	now = start.Add(10 * 24 * time.Hour)
+	next, _ := r.Signing()
+	if !next.NotBefore.Equal(now) {
+		t.Errorf("expected the next key to sign once its period starts, got %s", next.ID)
+	}
+}
+
+func TestRefresh_DropsKeysOnceTheirTokensExpired(t *testing.T) {
+	now := start.Add(time.Hour)
+	repo := newMemRepo()
+	r := newRing(t, repo, "secret", &now)
+	r.Refresh(ctx)
+	old, _ := r.Signing()
+
+	now = start.Add(20*24*time.Hour + time.Hour)
+	r.Refresh(ctx)
+
+	if _, ok := r.Lookup(old.ID); ok {
+		t.Errorf("expected key %s to be dropped, have %v", old.ID, ids(r.Published()))
+	}
+}
+
+func TestRefresh_ReplicasShareKeys(t *testing.T) {
+	now := start.Add(time.Hour)
+	repo := newMemRepo()
+	a := newRing(t, repo, "secret", &now)
+	b := newRing(t, repo, "secret", &now)
+
+	a.Refresh(ctx)
+	b.Refresh(ctx)
+
+	if repo.inserts != 1 {
+		t.Errorf("expected one key for the period, got %d inserts", repo.inserts)
+	}
+	signed, err := token.NewManager(a, time.Minute, time.Hour, token.WithClock(time.Now)).Issue(token.Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := token.NewManager(b, time.Minute, time.Hour).Parse(signed); err != nil {
+		t.Errorf("expected replica b to verify replica a's token, got %v", err)
+	}
+}
+
+func TestRefresh_WrongSecretCannotUnseal(t *testing.T) {
+	now := start.Add(time.Hour)
+	repo := newMemRepo()
+	newRing(t, repo, "secret", &now).Refresh(ctx)
+
+	err := newRing(t, repo, "other", &now).Refresh(ctx)
+
+	if err == nil || !strings.Contains(err.Error(), "unsealing") {
+		t.Errorf("expected an unsealing error, got %v", err)
+	}
+}
+
+func TestRefresh_StoresPrivateKeysSealed(t *testing.T) {
+	now := start.Add(time.Hour)
+	repo := newMemRepo()
+	r := newRing(t, repo, "secret", &now)
+	r.Refresh(ctx)
+
+	for id, doc := range repo.docs {
+		if _, err := r.open("another-kid", doc.PrivateKey); err == nil {
+			t.Errorf("%s: expected the ciphertext to be bound to its kid", id)
+		}
+	}
+}
+
+func TestNew_RejectsUnsupportedAlgorithm(t *testing.T) {
+	if _, err := New(newMemRepo(), Config{Algorithm: "HS256", Secret: []byte("s")}); err == nil {
+		t.Errorf("expected HS256 to be refused")
+	}
+}
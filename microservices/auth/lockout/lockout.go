@@ -0,0 +1,161 @@
+// Package lockout slows down password guessing. Failed logins are counted
+// per account and per client address; past a threshold the key is locked,
+// for twice as long with every further failure.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"strings"
+	"time"
+)
+
+// Policy says when a key gets locked and for how long.
+type Policy struct {
+	// Threshold is the number of failures that triggers the first lock.
+	Threshold int
+	// Duration is the first lock; each further failure doubles it, up to
+	// MaxDuration.
+	Duration    time.Duration
+	MaxDuration time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+var (
+	DefaultAccountPolicy = Policy{Threshold: 5, Duration: time.Minute, MaxDuration: time.Hour, Window: 15 * time.Minute}
+	// DefaultIPPolicy tolerates more failures, since one address can be
+	// shared by many users.
+	DefaultIPPolicy = Policy{Threshold: 20, Duration: time.Minute, MaxDuration: time.Hour, Window: 15 * time.Minute}
+)
+
+type Config struct {
+	Account Policy
+	IP      Policy
+	// Clock defaults to time.Now.
+	Clock func() time.Time
+}
+
+// Guard applies the policies. A nil *Guard allows every login.
+type Guard struct {
+	repo    repository.AttemptRepository
+	account Policy
+	ip      Policy
+	now     func() time.Time
+}
+
+func New(repo repository.AttemptRepository, cfg Config) *Guard {
+	now := cfg.Clock
+	if now == nil {
+		now = time.Now
+	}
+	return &Guard{repo: repo, account: cfg.Account, ip: cfg.IP, now: now}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Locked returns how long logins to email from ip are still refused, or
+// zero. ip may be empty when the caller's address is unknown.
+func (g *Guard) Locked(ctx context.Context, email, ip string) (time.Duration, error) {
+	if g == nil {
+		return 0, nil
+	}
+
+	var wait time.Duration
+	for _, key := range g.keys(email, ip) {
+		attempts, err := g.repo.FindAttempts(ctx, key)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, attempts.LockedUntil.Sub(g.now()))
+	}
+	return wait, nil
+}
+
+// Fail records a failed login and returns how long logins are now refused,
+// or zero.
+func (g *Guard) Fail(ctx context.Context, email, ip string) (time.Duration, error) {
+	if g == nil {
+		return 0, nil
+	}
+
+	wait, err := g.fail(ctx, accountKey(email), g.account)
+	if err != nil || ip == "" {
+		return wait, err
+	}
+	ipWait, err := g.fail(ctx, ipKey(ip), g.ip)
+	return max(wait, ipWait), err
+}
+
+func (g *Guard) fail(ctx context.Context, key string, p Policy) (time.Duration, error) {
+	now := g.now()
+
+	// Mongo only purges expired counters every minute or so; a stale one
+	// starts over.
+	old, err := g.repo.FindAttempts(ctx, key)
+	if err == nil && !old.ExpiresAt.After(now) {
+		err = g.repo.DeleteAttempts(ctx, key)
+	}
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return 0, err
+	}
+
+	attempts, err := g.repo.RecordFailure(ctx, key, now, now.Add(p.Window))
+	if err != nil {
+		return 0, err
+	}
+	lock := p.lockFor(attempts)
+	if lock == 0 {
+		return 0, nil
+	}
+
+	until := now.Add(lock)
+	return lock, g.repo.LockAttempts(ctx, key, until, until.Add(p.Window))
+}
+
+// lockFor returns the lock earned by the failures so far.
+func (p Policy) lockFor(attempts *models.LoginAttempts) time.Duration {
+	over := attempts.Failures - p.Threshold
+	if p.Threshold <= 0 || over < 0 {
+		return 0
+	}
+	lock := p.Duration
+	for ; over > 0 && lock < p.MaxDuration; over-- {
+		lock *= 2
+	}
+	return min(lock, p.MaxDuration)
+}
+
+// Succeed forgets the account's failures after a successful login. The
+// address keeps its count, so logging into one account doesn't reset a
+// guessing spree against others.
+func (g *Guard) Succeed(ctx context.Context, email string) error {
+	return g.Unlock(ctx, email)
+}
+
+// Unlock lifts an account's lock and forgets its failures.
+func (g *Guard) Unlock(ctx context.Context, email string) error {
+	if g == nil {
+		return nil
+	}
+	return g.repo.DeleteAttempts(ctx, accountKey(email))
+}
+
+func (g *Guard) keys(email, ip string) []string {
+	keys := []string{accountKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
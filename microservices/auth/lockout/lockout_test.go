@@ -0,0 +1,194 @@
+package lockout_test
+
+import (
+	"context"
+	"iLeon/microservices/auth/lockout"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"testing"
+	"time"
+)
+
+// ── In-memory AttemptRepository ─────────────────────────────────────────────
+
+type memAttempts map[string]models.LoginAttempts
+
+func (m memAttempts) FindAttempts(_ context.Context, key string) (*models.LoginAttempts, error) {
+	a, ok := m[key]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &a, nil
+}
+
+func (m memAttempts) RecordFailure(_ context.Context, key string, at, expiresAt time.Time) (*models.LoginAttempts, error) {
+	a := m[key]
+	a.Key = key
+	a.Failures++
+	a.LastFailureAt = at
+	a.ExpiresAt = expiresAt
+	m[key] = a
+	return &a, nil
+}
+
+func (m memAttempts) LockAttempts(_ context.Context, key string, until, expiresAt time.Time) error {
+	a := m[key]
+	a.LockedUntil = until
+	a.ExpiresAt = expiresAt
+	m[key] = a
+	return nil
+}
+
+func (m memAttempts) DeleteAttempts(_ context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+var ctx = context.Background()
+
+var policy = lockout.Policy{Threshold: 3, Duration: time.Minute, MaxDuration: 10 * time.Minute, Window: 15 * time.Minute}
+
+type fixture struct {
+	guard *lockout.Guard
+	repo  memAttempts
+	now   time.Time
+}
+
+func newFixture() *fixture {
+	f := &fixture{repo: memAttempts{}, now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f.guard = lockout.New(f.repo, lockout.Config{
+		Account: policy,
+		IP:      lockout.Policy{Threshold: 5, Duration: time.Minute, MaxDuration: time.Hour, Window: time.Hour},
+		Clock:   func() time.Time { return f.now },
+	})
+	return f
+}
+
+func (f *fixture) fail(t *testing.T, email, ip string, n int) time.Duration {
+	t.Helper()
+	var wait time.Duration
+	for i := 0; i < n; i++ {
+		var err error
+		if wait, err = f.guard.Fail(ctx, email, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return wait
+}
+
+func (f *fixture) locked(t *testing.T, email, ip string) time.Duration {
+	t.Helper()
+	wait, err := f.guard.Locked(ctx, email, ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wait
+}
+
+// ── Tests ────────────────────────────────────────────────────────────────────
+
+func TestFail_LocksAtThreshold(t *testing.T) {
+	f := newFixture()
+
+	if wait := f.fail(t, "a@test.com", "", 2); wait != 0 {
+		t.Errorf("expected no lock below the threshold, got %v", wait)
+	}
+	if wait := f.fail(t, "a@test.com", "", 1); wait != time.Minute {
+		t.Errorf("expected a 1m lock at the threshold, got %v", wait)
+	}
+	if wait := f.locked(t, "A@Test.com ", ""); wait != time.Minute {
+		t.Errorf("expected the account to be locked regardless of case, got %v", wait)
+	}
+}
+
+func TestFail_BacksOffExponentially(t *testing.T) {
+	f := newFixture()
+	f.fail(t, "a@test.com", "", 3)
+
+	var waits []time.Duration
+	for i := 0; i < 5; i++ {
+		f.now = f.now.Add(f.locked(t, "a@test.com", ""))
+		waits = append(waits, f.fail(t, "a@test.com", "", 1))
+	}
+
+	want := []time.Duration{2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Fatalf("expected locks %v, got %v", want, waits)
+		}
+	}
+}
+
+func TestLocked_ExpiresWithTime(t *testing.T) {
+	f := newFixture()
+	f.fail(t, "a@test.com", "", 3)
+
+	f.now = f.now.Add(time.Minute)
+
+	if wait := f.locked(t, "a@test.com", ""); wait > 0 {
+		t.Errorf("expected the lock to be over, got %v", wait)
+	}
+}
+
+func TestFail_ForgetsAfterQuietWindow(t *testing.T) {
+	f := newFixture()
+	f.fail(t, "a@test.com", "", 2)
+
+	f.now = f.now.Add(policy.Window)
+
+	if wait := f.fail(t, "a@test.com", "", 1); wait != 0 {
+		t.Errorf("expected old failures to be forgotten, got a %v lock", wait)
+	}
+}
+
+func TestFail_LocksAddressAcrossAccounts(t *testing.T) {
+	f := newFixture()
+	for _, email := range []string{"a@test.com", "b@test.com", "c@test.com", "d@test.com", "e@test.com"} {
+		f.fail(t, email, "203.0.113.7", 1)
+	}
+
+	if wait := f.locked(t, "f@test.com", "203.0.113.7"); wait != time.Minute {
+		t.Errorf("expected the address to be locked, got %v", wait)
+	}
+	if wait := f.locked(t, "f@test.com", "198.51.100.1"); wait != 0 {
+		t.Errorf("expected other addresses to be unaffected, got %v", wait)
+	}
+}
+
+func TestSucceed_ResetsAccountButNotAddress(t *testing.T) {
+	f := newFixture()
+	f.fail(t, "a@test.com", "203.0.113.7", 2)
+
+	f.guard.Succeed(ctx, "a@test.com")
+
+	if _, ok := f.repo["account:a@test.com"]; ok {
+		t.Errorf("expected the account counter to be cleared")
+	}
+	if f.repo["ip:203.0.113.7"].Failures != 2 {
+		t.Errorf("expected the address counter to stay, got %+v", f.repo["ip:203.0.113.7"])
+	}
+}
+
+func TestUnlock_LiftsTheLock(t *testing.T) {
+	f := newFixture()
+	f.fail(t, "a@test.com", "", 3)
+
+	f.guard.Unlock(ctx, "A@test.com")
+
+	if wait := f.locked(t, "a@test.com", ""); wait != 0 {
+		t.Errorf("expected no lock after unlocking, got %v", wait)
+	}
+}
+
+func TestNilGuard_AllowsEverything(t *testing.T) {
+	var g *lockout.Guard
+
+	if wait, err := g.Fail(ctx, "a@test.com", ""); wait != 0 || err != nil {
+		t.Errorf("expected a nil guard to do nothing, got %v, %v", wait, err)
+	}
+	if wait, err := g.Locked(ctx, "a@test.com", ""); wait != 0 || err != nil {
+		t.Errorf("expected a nil guard to do nothing, got %v, %v", wait, err)
+	}
+}
@@ -0,0 +1,46 @@
+package lru
+
+import "testing"
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("expected a to survive, got %v %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestCache_AddReplacesAndRemoveDeletes(t *testing.T) {
+	c := New[string, int](2)
+	c.Add("a", 1)
+	c.Add("a", 2)
+
+	if v, _ := c.Get("a"); v != 2 || c.Len() != 1 {
+		t.Errorf("expected a=2 in a single entry, got %d (len %d)", v, c.Len())
+	}
+
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("expected a to be removed")
+	}
+}
+
+func TestCache_ZeroCapacityDisablesCaching(t *testing.T) {
+	c := New[string, int](0)
+	c.Add("a", 1)
+
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Errorf("expected nothing to be cached")
+	}
+}
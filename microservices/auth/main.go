@@ -1,36 +1,160 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"iLeon/microservices/auth/config"
+	"iLeon/microservices/auth/controller"
 	"iLeon/microservices/auth/database"
 	"iLeon/microservices/auth/functions"
+	"iLeon/microservices/auth/keyring"
+	"iLeon/microservices/auth/lockout"
+	"iLeon/microservices/auth/outbox"
+	"iLeon/microservices/auth/password"
 	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/revocation"
+	"iLeon/microservices/auth/secretbox"
 	"iLeon/microservices/auth/service"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/natsrpc"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
-
-	"github.com/nats-io/nats.go"
 )
 
+// connectTimeout bounds the startup database connection.
+const connectTimeout = 10 * time.Second
+
 func main() {
 
-	natsUrl := nats.DefaultURL
-	nc, err := nats.Connect(natsUrl)
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:\n", err)
+	}
+	fmt.Println("Effective configuration:\n" + cfg.String())
+
+	nc, err := cfg.NATS.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Connected to NATS server at", nc.ConnectedUrlRedacted())
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	db, err := database.Connect(ctx, cfg.MongoURI, cfg.MongoDatabase)
+	if err == nil {
+		var normalized int
+		normalized, err = repository.NormalizeEmails(ctx, db)
+		if normalized > 0 {
+			fmt.Println("Lower-cased the email of", normalized, "users")
+		}
+	}
+	if err == nil {
+		err = repository.EnsureIndexes(ctx, db)
+	}
+	revocations := revocation.NewStore(repository.NewRevocationRepo(db))
+	if err == nil {
+		err = revocations.Sync(ctx)
+	}
+	var keys *keyring.Ring
+	if err == nil {
+		keys, err = keyring.New(repository.NewKeyRepo(db), keyring.Config{
+			Algorithm:        cfg.SigningAlgorithm,
+			RotationInterval: cfg.KeyRotationInterval,
+			TokenLifetime:    cfg.AccessTokenTTL,
+			Secret:           []byte(cfg.SecretKey),
+		})
+	}
+	if err == nil {
+		err = keys.Refresh(ctx)
+	}
+	cancel()
+	var mfaSecrets *secretbox.Box
+	if err == nil {
+		mfaSecrets, err = secretbox.New([]byte(cfg.SecretKey), "auth mfa secrets")
+	}
+	var passwordPolicy *password.Policy
+	if err == nil {
+		passwordPolicy, err = cfg.PasswordPolicy()
+	}
+	var passwordHasher password.Hasher
+	if err == nil {
+		passwordHasher, err = cfg.PasswordHasher()
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer nc.Close()
 
-	fmt.Println("Connected to NATS server at", natsUrl)
+	service, err := service.NewService(service.Dependencies{
+		Users:       repository.NewRepo(db),
+		Sessions:    repository.NewSessionRepo(db),
+		Revocations: revocations,
+		Tokens:      token.NewManager(keys, cfg.AccessTokenTTL, cfg.RefreshTokenTTL),
+		Lockout:     lockout.New(repository.NewAttemptRepo(db), cfg.Lockout()),
+		Audit:       repository.NewAuditRepo(db),
 
-	db, _ := database.Connect()
-	repo := repository.NewRepo(db)
-	service := service.NewService(repo)
+		OneTimeTokens:    repository.NewOneTimeTokenRepo(db),
+		Outbox:           repository.NewOutboxRepo(db),
+		PasswordResetTTL: cfg.PasswordResetTTL,
+		PasswordPolicy:   passwordPolicy,
+		PasswordHasher:   passwordHasher,
 
-	functions.Handler(nc, service)
+		EmailVerificationTTL: cfg.EmailVerificationTTL,
+		RequireVerifiedEmail: cfg.RequireVerifiedEmail,
 
-	for {
-		time.Sleep(10 * time.Second)
+		Secrets:   mfaSecrets,
+		MFAIssuer: cfg.MFAIssuer,
+
+		ValidationCacheSize: cfg.ValidationCacheSize,
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	srv := natsrpc.NewServer(nc, append(cfg.NATS.ServerOptions(),
+		natsrpc.WithAuthenticator(controller.Authenticator(service)))...)
+	if err := functions.Handler(srv, service); err != nil {
+		log.Fatal(err)
+	}
+
+	var publisher outbox.Publisher = outbox.NewNATSPublisher(nc)
+	if cfg.OutboxPublisher == "log" {
+		publisher = outbox.LogPublisher{}
+	}
+	relay := outbox.NewRelay(repository.NewOutboxRepo(db), publisher)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go revocations.Run(ctx, cfg.RevocationSyncInterval)
+	go relay.Run(ctx, outbox.DefaultInterval)
+	go keys.Run(ctx, keyring.DefaultRefreshInterval)
+	<-ctx.Done()
+	stop()
+
+	os.Exit(shutdown(srv, db, cfg.ShutdownTimeout))
+}
+
+// shutdown drains NATS so in-flight requests are answered, then closes the
+// database. It returns the process exit status.
+func shutdown(srv *natsrpc.Server, db *database.MongoInstance, timeout time.Duration) int {
+	fmt.Println("Shutting down, draining NATS subscriptions")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	status := 0
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Couldn't drain NATS connection:", err)
+		status = 1
+	}
+	if err := db.Close(ctx); err != nil {
+		log.Println("Couldn't close the database connection:", err)
+		status = 1
+	}
+
+	if status == 0 {
+		fmt.Println("Shutdown complete")
+	}
+	return status
 }
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserQuery is the auth.admin.listUsers payload. Search matches part of the
+// username or email, ignoring case; Status and Role match exactly.
+type UserQuery struct {
+	Page   int    `json:"page"`
+	Limit  int    `json:"limit"`
+	Search string `json:"search"`
+	Status string `json:"status"`
+	Role   string `json:"role"`
+}
+
+type UserPage struct {
+	Data  []AdminUser `json:"data"`
+	Total int         `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+	Pages int         `json:"pages"`
+}
+
+// AdminUser is a user as operators see it: the profile plus the state only
+// admins change.
+type AdminUser struct {
+	Profile
+	Status            string `json:"status"`
+	MustResetPassword bool   `json:"must_reset_password"`
+}
+
+// UserIDBody names the user an admin command acts on.
+type UserIDBody struct {
+	UserID string `json:"user_id"`
+}
+
+// Audited admin actions.
+const (
+	AuditUserDisabled        = "user.disabled"
+	AuditUserEnabled         = "user.enabled"
+	AuditPasswordResetForced = "user.passwordResetForced"
+	AuditUserDeleted         = "user.deleted"
+	AuditRoleAssigned        = "user.roleAssigned"
+	AuditRoleRevoked         = "user.roleRevoked"
+	AuditAccountUnlocked     = "user.unlocked"
+)
+
+// AuditEntry is a document in the audit_log collection, recording one
+// change an operator made to an account.
+type AuditEntry struct {
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+	// ActorID is the operator's user ID.
+	ActorID string `bson:"actor_id"`
+	Action  string `bson:"action"`
+	// TargetID is the user acted on; unlocks by email have the email.
+	TargetID string `bson:"target_id"`
+	// Details are the action's arguments, e.g. the role assigned.
+	Details map[string]string `bson:"details,omitempty"`
+	At      time.Time         `bson:"at"`
+}
+
+// AuditQuery is the auth.admin.auditLog payload. Without UserID the latest
+// entries for every user are listed.
+type AuditQuery struct {
+	UserID string `json:"user_id"`
+	Limit  int    `json:"limit"`
+}
+
+// AuditRecord is an AuditEntry as listed by auth.admin.auditLog.
+type AuditRecord struct {
+	ID       string            `json:"id"`
+	ActorID  string            `json:"actor_id"`
+	Action   string            `json:"action"`
+	TargetID string            `json:"target_id"`
+	Details  map[string]string `json:"details,omitempty"`
+	At       time.Time         `json:"at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LoginAttempts counts recent failed logins for one account or client
+// address. Mongo deletes it at ExpiresAt, once it is quiet and unlocked.
+type LoginAttempts struct {
+	Key           string    `bson:"_id"`
+	Failures      int       `bson:"failures"`
+	LastFailureAt time.Time `bson:"last_failure_at"`
+	LockedUntil   time.Time `bson:"locked_until,omitempty"`
+	ExpiresAt     time.Time `bson:"expires_at"`
+}
+
+type UnlockAccountBody struct {
+	Email string `json:"email"`
+}
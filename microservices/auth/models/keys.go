@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// SigningKey is a document in the signing_keys collection. The private key
+// is PKCS #8 DER sealed with the service secret; the ID is the JWT kid.
+type SigningKey struct {
+	ID         string    `bson:"_id"`
+	Algorithm  string    `bson:"algorithm"`
+	PrivateKey []byte    `bson:"private_key"`
+	CreatedAt  time.Time `bson:"created_at"`
+	NotBefore  time.Time `bson:"not_before"`
+	ExpiresAt  time.Time `bson:"expires_at"`
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is the key set served by auth.jwks.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// MFA is the user's authenticator app enrolment. Secret is sealed with the
+// service's secret key; RecoveryCodes holds hashes of the codes that are
+// left.
+type MFA struct {
+	Secret        []byte     `bson:"secret"`
+	Enabled       bool       `bson:"enabled"`
+	EnabledAt     *time.Time `bson:"enabled_at,omitempty"`
+	RecoveryCodes []string   `bson:"recovery_codes,omitempty"`
+	// LastStep is the time step of the last code accepted; codes from it or
+	// earlier steps are refused so they can't be replayed.
+	LastStep int64 `bson:"last_step"`
+}
+
+// MFAEnrollment is shown once, for the user to add to an authenticator app
+// by typing Secret or scanning URI as a QR code.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFACodeBody carries a code from the authenticator app, or for disabling
+// MFA a recovery code.
+type MFACodeBody struct {
+	Code string `json:"code"`
+}
+
+// MFAVerifyBody redeems the challenge a login returned.
+type MFAVerifyBody struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// RecoveryCodes are shown once when MFA is enabled. Each signs in once in
+// place of a code from the app.
+type RecoveryCodes struct {
+	CustomeResponse
+	Codes []string `json:"recovery_codes"`
+}
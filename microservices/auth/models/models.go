@@ -9,6 +9,13 @@ type CreateUserBody struct {
 type LoginUserBody struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Device labels the session in the per-device listing, e.g. the
+	// browser's user agent.
+	Device string `json:"device,omitempty"`
+}
+
+type RefreshTokenBody struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type CreateUserPayload struct {
@@ -23,3 +30,19 @@ type CustomeResponse struct {
 	Msg     string `json:"message"`
 	Context bool   `json:"context"`
 }
+
+// TokenResponse answers a login or refresh. The access token is also in
+// message so existing callers that only read message keep working.
+//
+// A login for a user with MFA enabled answers with MFARequired and an
+// MFAToken to redeem with auth.mfa.verify instead of tokens; ExpiresIn is
+// then the challenge lifetime.
+type TokenResponse struct {
+	CustomeResponse
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	// ExpiresIn is the access token lifetime in seconds.
+	ExpiresIn   int64  `json:"expires_in"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
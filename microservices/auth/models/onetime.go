@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purposes of one-time tokens. A token only works for the purpose it was
+// issued for.
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeMFAChallenge      = "mfa_challenge"
+	PurposeEmailChange       = "email_change"
+)
+
+// How long mailed tokens last unless configured otherwise.
+const (
+	DefaultPasswordResetTTL     = time.Hour
+	DefaultEmailVerificationTTL = 24 * time.Hour
+)
+
+// OneTimeToken is a document in the one_time_tokens collection: a secret
+// mailed to a user that can be spent once before ExpiresAt. Only its hash is
+// stored.
+type OneTimeToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"`
+	Purpose   string             `bson:"purpose"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	// Device is carried from an MFA challenge to the session it opens.
+	Device string `bson:"device,omitempty"`
+}
+
+type PasswordResetRequestBody struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordBody struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type VerifyEmailBody struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationBody struct {
+	Email string `json:"email"`
+}
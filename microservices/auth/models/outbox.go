@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Event types written to the outbox. They are published on the NATS subject
+// of the same name.
+const (
+	EventPasswordResetRequested     = "auth.passwordResetRequested"
+	EventEmailVerificationRequested = "auth.emailVerificationRequested"
+	EventEmailChangeRequested       = "auth.emailChangeRequested"
+	EventUserDeleted                = "auth.userDeleted"
+)
+
+// OutboxEvent is a document in the outbox collection. Events are stored
+// before they are published, so a NATS outage delays them instead of losing
+// them. The payload is dropped once published.
+type OutboxEvent struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty"`
+	Type string             `bson:"type"`
+	// Payload is the JSON encoded event data.
+	Payload     []byte     `bson:"payload,omitempty"`
+	CreatedAt   time.Time  `bson:"created_at"`
+	PublishedAt *time.Time `bson:"published_at,omitempty"`
+}
+
+// TokenMail is the payload of the events asking the mailer to send a
+// one-time token to Email.
+type TokenMail struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UserDeleted is the payload of EventUserDeleted, for services holding data
+// about the user to clean up.
+type UserDeleted struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
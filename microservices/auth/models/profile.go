@@ -0,0 +1,44 @@
+package models
+
+// Profile is what a user sees of their own account; it never carries the
+// password hash or MFA secret.
+type Profile struct {
+	ID            string   `json:"id"`
+	Username      string   `json:"username"`
+	DisplayName   string   `json:"display_name,omitempty"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	PendingEmail  string   `json:"pending_email,omitempty"`
+	Roles         []string `json:"roles"`
+	Permissions   []string `json:"permissions"`
+	MFAEnabled    bool     `json:"mfa_enabled"`
+}
+
+// UpdateProfileBody changes the fields that are set and leaves the others.
+type UpdateProfileBody struct {
+	Username    *string `json:"username,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+}
+
+// ChangeEmailBody asks to move the account to Email. The current password
+// is required so a stolen access token can't take the account over.
+type ChangeEmailBody struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type ConfirmEmailChangeBody struct {
+	Token string `json:"token"`
+}
+
+type ChangePasswordBody struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// DeleteAccountBody confirms the deletion with the password, and with a
+// code from the authenticator app or a recovery code if MFA is enabled.
+type DeleteAccountBody struct {
+	Password string `json:"password"`
+	Code     string `json:"code,omitempty"`
+}
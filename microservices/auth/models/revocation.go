@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+const (
+	// RevokedToken revokes the single access token whose jti is the ID.
+	RevokedToken = "token"
+	// RevokedUser revokes every access token of UserID issued before
+	// RevokedAt, a whole second; tokens issued at RevokedAt stay valid.
+	RevokedUser = "user"
+	// RevokedSession revokes every access token of the session SessionID.
+	RevokedSession = "session"
+)
+
+// Revocation is a document in the revoked_tokens collection. Mongo deletes
+// it at ExpiresAt, once every token it covers has expired anyway.
+type Revocation struct {
+	ID        string    `bson:"_id"`
+	Kind      string    `bson:"kind"`
+	UserID    string    `bson:"user_id"`
+	SessionID string    `bson:"session_id,omitempty"`
+	RevokedAt time.Time `bson:"revoked_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+type ValidateTokenBody struct {
+	Token string `json:"token"`
+}
+
+// TokenValidation is the introspection result for an access token. Only
+// Active is set for tokens that are malformed, expired or revoked.
+type TokenValidation struct {
+	Active    bool       `json:"active"`
+	UserID    string     `json:"sub,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	SessionID string     `json:"sid,omitempty"`
+	TokenID   string     `json:"jti,omitempty"`
+	Roles     []string   `json:"roles,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"exp,omitempty"`
+}
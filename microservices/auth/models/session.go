@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a document in the refresh_tokens collection. Every login
+// starts a family; each refresh rotates the token, marking the old one
+// rotated and inserting its successor into the same family. Only the hash of
+// the opaque token is stored.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"`
+	FamilyID  string             `bson:"family_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Device    string             `bson:"device,omitempty"`
+
+	// SessionStartedAt is when the family's login happened.
+	SessionStartedAt time.Time  `bson:"session_started_at"`
+	CreatedAt        time.Time  `bson:"created_at"`
+	ExpiresAt        time.Time  `bson:"expires_at"`
+	RotatedAt        *time.Time `bson:"rotated_at,omitempty"`
+	RevokedAt        *time.Time `bson:"revoked_at,omitempty"`
+}
+
+// Session is one signed-in device, as listed by auth.listSessions.
+type Session struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session the request was made from.
+	Current bool `json:"current"`
+}
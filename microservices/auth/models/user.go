@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User is a document in the users collection.
+type User struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Username string             `bson:"username"`
+	// Email is stored normalized, trimmed and lowercased, and is unique.
+	Email string `bson:"email"`
+	// Password is the hash of the password, never the password itself.
+	Password string `bson:"password"`
+	// DisplayName is how the user wants to be addressed; optional.
+	DisplayName string `bson:"display_name,omitempty"`
+	// PendingEmail is the address the user asked to move to; it replaces
+	// Email once confirmed from a mail sent to it.
+	PendingEmail string `bson:"pending_email,omitempty"`
+	// EmailVerified is set once the user follows the verification mail.
+	EmailVerified bool `bson:"email_verified"`
+	// Roles name entries of the rbac catalog; Permissions are granted on
+	// top of what the roles give.
+	Roles       []string `bson:"roles,omitempty"`
+	Permissions []string `bson:"permissions,omitempty"`
+	// MFA is set from enrolment on; logins need a second factor once it is
+	// enabled.
+	MFA *MFA `bson:"mfa,omitempty"`
+	// Status is StatusActive or StatusDisabled. Users stored before it
+	// existed have none and count as active.
+	Status string `bson:"status,omitempty"`
+	// MustResetPassword refuses logins until the password is reset; an
+	// admin sets it to force a reset.
+	MustResetPassword bool `bson:"must_reset_password,omitempty"`
+
+	// CreatedAt is set on registration and UpdatedAt on every change;
+	// users stored before they existed have neither.
+	CreatedAt time.Time `bson:"created_at,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty"`
+	// Version counts the changes made to the document.
+	Version int64 `bson:"version"`
+}
+
+// Account statuses. Disabled users can't log in.
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+)
+
+// Disabled reports whether an admin disabled the account.
+func (u *User) Disabled() bool {
+	return u.Status == StatusDisabled
+}
+
+// MFAEnabled reports whether logins need a code from the authenticator app.
+func (u *User) MFAEnabled() bool {
+	return u.MFA != nil && u.MFA.Enabled
+}
+
+type RoleBody struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// UserRoles answers a role change with the user's resulting access.
+type UserRoles struct {
+	UserID      string   `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,141 @@
+// Package outbox delivers the events the service records in Mongo. Events
+// are written alongside the change that caused them and published by a
+// Relay afterwards, so they survive a NATS outage. Delivery is at least
+// once: consumers should ignore an event ID they have already seen.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultInterval = time.Second
+
+	// batchSize bounds the events published per Flush.
+	batchSize = 100
+)
+
+// NewEvent encodes data as the payload of an event of type typ.
+func NewEvent(typ string, data any, at time.Time) (*models.OutboxEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OutboxEvent{Type: typ, Payload: payload, CreatedAt: at}, nil
+}
+
+// Publisher sends an event to its consumers.
+type Publisher interface {
+	Publish(ctx context.Context, e models.OutboxEvent) error
+}
+
+// Relay moves events from the outbox to a Publisher.
+type Relay struct {
+	repo repository.OutboxRepository
+	pub  Publisher
+	now  func() time.Time
+}
+
+func NewRelay(repo repository.OutboxRepository, pub Publisher) *Relay {
+	return &Relay{repo: repo, pub: pub, now: time.Now}
+}
+
+// Flush publishes the pending events in order. It stops at the first
+// failure so events aren't reordered; the rest are retried next time.
+func (r *Relay) Flush(ctx context.Context) error {
+	events, err := r.repo.PendingEvents(ctx, batchSize)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := r.pub.Publish(ctx, e); err != nil {
+			return err
+		}
+		if err := r.repo.MarkPublished(ctx, e.ID, r.now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+				log.Println("Couldn't publish outbox events:", err)
+			}
+		}
+	}
+}
+
+// NATSPublisher publishes each event on the subject named by its type, in
+// the envelope NestJS event handlers (@EventPattern) expect. The event ID
+// goes in the Nats-Msg-Id header.
+type NATSPublisher struct {
+	nc *nats.Conn
+}
+
+func NewNATSPublisher(nc *nats.Conn) *NATSPublisher {
+	return &NATSPublisher{nc: nc}
+}
+
+func (p *NATSPublisher) Publish(_ context.Context, e models.OutboxEvent) error {
+	data, err := json.Marshal(struct {
+		Pattern string          `json:"pattern"`
+		Data    json.RawMessage `json:"data"`
+	}{e.Type, e.Payload})
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(e.Type)
+	msg.Header.Set(nats.MsgIdHdr, e.ID.Hex())
+	msg.Data = data
+	return p.nc.PublishMsg(msg)
+}
+
+// Sink keeps published events in memory. It stands in for the mailer in
+// tests.
+type Sink struct {
+	mu     sync.Mutex
+	events []models.OutboxEvent
+}
+
+func (s *Sink) Publish(_ context.Context, e models.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+// Events returns the events published so far.
+func (s *Sink) Events() []models.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.OutboxEvent(nil), s.events...)
+}
+
+// LogPublisher prints events instead of publishing them, for running the
+// service locally without a mailer. The payloads contain live tokens, so it
+// must not be used in production.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(_ context.Context, e models.OutboxEvent) error {
+	log.Printf("event %s %s: %s", e.Type, e.ID.Hex(), e.Payload)
+	return nil
+}
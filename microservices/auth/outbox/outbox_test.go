@@ -0,0 +1,116 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/outbox"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ── In-memory OutboxRepository ──────────────────────────────────────────────
+
+type memOutbox struct {
+	events []*models.OutboxEvent
+}
+
+func (m *memOutbox) EnqueueEvent(_ context.Context, e *models.OutboxEvent) error {
+	e.ID = primitive.NewObjectID()
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *memOutbox) PendingEvents(_ context.Context, limit int) ([]models.OutboxEvent, error) {
+	var out []models.OutboxEvent
+	for _, e := range m.events {
+		if e.PublishedAt == nil && len(out) < limit {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (m *memOutbox) MarkPublished(_ context.Context, id primitive.ObjectID, at time.Time) error {
+	for _, e := range m.events {
+		if e.ID == id {
+			e.PublishedAt, e.Payload = &at, nil
+		}
+	}
+	return nil
+}
+
+// failAfter publishes n events, then fails.
+type failAfter struct {
+	outbox.Sink
+	n int
+}
+
+func (f *failAfter) Publish(ctx context.Context, e models.OutboxEvent) error {
+	if len(f.Events()) == f.n {
+		return errors.New("nats down")
+	}
+	return f.Sink.Publish(ctx, e)
+}
+
+var ctx = context.Background()
+
+func enqueue(t *testing.T, repo *memOutbox, emails ...string) {
+	t.Helper()
+	for _, email := range emails {
+		e, err := outbox.NewEvent(models.EventPasswordResetRequested, models.TokenMail{Email: email}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.EnqueueEvent(ctx, e)
+	}
+}
+
+// ── Relay tests ──────────────────────────────────────────────────────────────
+
+func TestFlush_PublishesPendingEventsInOrder(t *testing.T) {
+	repo := &memOutbox{}
+	enqueue(t, repo, "a@test.com", "b@test.com")
+	sink := &outbox.Sink{}
+
+	if err := outbox.NewRelay(repo, sink).Flush(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := sink.Events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	var first models.TokenMail
+	json.Unmarshal(events[0].Payload, &first)
+	if events[0].Type != models.EventPasswordResetRequested || first.Email != "a@test.com" {
+		t.Errorf("unexpected first event: %s %+v", events[0].Type, first)
+	}
+	if pending, _ := repo.PendingEvents(ctx, 10); len(pending) != 0 {
+		t.Errorf("expected nothing left to publish, got %d", len(pending))
+	}
+}
+
+func TestFlush_StopsAtFirstFailureAndRetries(t *testing.T) {
+	repo := &memOutbox{}
+	enqueue(t, repo, "a@test.com", "b@test.com", "c@test.com")
+	pub := &failAfter{n: 1}
+	relay := outbox.NewRelay(repo, pub)
+
+	if err := relay.Flush(ctx); err == nil {
+		t.Fatal("expected the publish failure to be reported")
+	}
+	if pending, _ := repo.PendingEvents(ctx, 10); len(pending) != 2 {
+		t.Fatalf("expected 2 events still pending, got %d", len(pending))
+	}
+
+	pub.n = 10
+	relay.Flush(ctx)
+
+	if got := len(pub.Events()); got != 3 {
+		t.Errorf("expected every event published once, got %d", got)
+	}
+}
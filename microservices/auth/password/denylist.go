@@ -0,0 +1,47 @@
+package password
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// Denylist is a set of passwords that must not be used, compared case
+// insensitively.
+type Denylist map[string]struct{}
+
+// ReadDenylist reads one password per line. Blank lines and lines starting
+// with # are skipped.
+func ReadDenylist(r io.Reader) (Denylist, error) {
+	list := Denylist{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// LoadDenylist reads the denylist file at path.
+func LoadDenylist(path string) (Denylist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadDenylist(f)
+}
+
+// Contains reports whether password is on the list. A nil list contains
+// nothing.
+func (d Denylist) Contains(password string) bool {
+	_, ok := d[strings.ToLower(password)]
+	return ok
+}
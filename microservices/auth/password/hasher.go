@@ -0,0 +1,69 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Hash algorithms. Bcrypt hashes are stored in their own "$2a$" format,
+// argon2id hashes in the PHC string format.
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// ErrUnknownHash is returned for a stored hash no supported algorithm made.
+var ErrUnknownHash = errors.New("password: unknown hash format")
+
+// Hasher hashes new passwords with one algorithm and parameters, and checks
+// passwords against hashes of any supported algorithm, so the algorithm can
+// change without locking users out.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash.
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether hash was made with another algorithm or
+	// weaker parameters than the hasher uses; the password should then be
+	// hashed again the next time it is known.
+	NeedsRehash(hash string) bool
+}
+
+// DefaultHasher is bcrypt at cost 10, what hashes were made with before the
+// algorithm was configurable.
+var DefaultHasher Hasher = BcryptHasher{Cost: 10}
+
+// NewHasher returns the hasher for algorithm, with the parameters for it.
+func NewHasher(algorithm string, bcryptCost int, argon Argon2idParams) (Hasher, error) {
+	switch algorithm {
+	case Bcrypt:
+		h := BcryptHasher{Cost: bcryptCost}
+		return h, h.validate()
+	case Argon2id:
+		h := Argon2idHasher{Params: argon}
+		return h, h.Params.validate()
+	}
+	return nil, fmt.Errorf("unknown password hash algorithm %q, want %s or %s", algorithm, Bcrypt, Argon2id)
+}
+
+// Verify checks password against a hash of any supported algorithm.
+func Verify(hash, password string) (bool, error) {
+	switch Algorithm(hash) {
+	case Bcrypt:
+		return verifyBcrypt(hash, password)
+	case Argon2id:
+		return verifyArgon2id(hash, password)
+	}
+	return false, ErrUnknownHash
+}
+
+// Algorithm returns which algorithm made hash, or "" if none supported did.
+func Algorithm(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return Bcrypt
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return Argon2id
+	}
+	return ""
+}
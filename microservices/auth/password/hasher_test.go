@@ -0,0 +1,114 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon keeps the tests quick; the format is what is under test.
+var fastArgon = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashers_HashAndVerify(t *testing.T) {
+	hashers := map[string]Hasher{
+		Bcrypt:   BcryptHasher{Cost: bcrypt.MinCost},
+		Argon2id: Argon2idHasher{Params: fastArgon},
+	}
+	for name, h := range hashers {
+		hash, err := h.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if Algorithm(hash) != name {
+			t.Errorf("%s: hash %q is recognised as %q", name, hash, Algorithm(hash))
+		}
+		if ok, err := h.Verify(hash, "correct horse"); !ok || err != nil {
+			t.Errorf("%s: expected the password to match, got %v, %v", name, ok, err)
+		}
+		if ok, err := h.Verify(hash, "wrong horse"); ok || err != nil {
+			t.Errorf("%s: expected another password not to match, got %v, %v", name, ok, err)
+		}
+	}
+}
+
+func TestArgon2idHasher_PHCFormat(t *testing.T) {
+	hash, _ := Argon2idHasher{Params: fastArgon}.Hash("pw")
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") || strings.Contains(hash, "=$") {
+		t.Errorf("unexpected PHC string %q", hash)
+	}
+	a, _ := Argon2idHasher{Params: fastArgon}.Hash("pw")
+	if a == hash {
+		t.Errorf("expected a fresh salt per hash")
+	}
+}
+
+func TestVerify_AcrossAlgorithms(t *testing.T) {
+	old, _ := BcryptHasher{Cost: bcrypt.MinCost}.Hash("correct horse")
+
+	ok, err := Argon2idHasher{Params: fastArgon}.Verify(old, "correct horse")
+
+	if !ok || err != nil {
+		t.Errorf("expected an argon2id hasher to verify a bcrypt hash, got %v, %v", ok, err)
+	}
+}
+
+func TestVerify_RejectsUnknownAndMalformedHashes(t *testing.T) {
+	for _, hash := range []string{"", "plaintext", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA", "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5"} {
+		if ok, err := Verify(hash, "pw"); ok || err == nil {
+			t.Errorf("%q: expected an error, got %v, %v", hash, ok, err)
+		}
+	}
+	if _, err := Verify("plaintext", "pw"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("expected ErrUnknownHash, got %v", err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weakBcrypt, _ := BcryptHasher{Cost: bcrypt.MinCost}.Hash("pw")
+	bcrypt5, _ := BcryptHasher{Cost: bcrypt.MinCost + 1}.Hash("pw")
+	weakArgon, _ := Argon2idHasher{Params: fastArgon}.Hash("pw")
+	strongerArgon := fastArgon
+	strongerArgon.Memory *= 2
+
+	cases := []struct {
+		name   string
+		hasher Hasher
+		hash   string
+		want   bool
+	}{
+		{"same bcrypt cost", BcryptHasher{Cost: bcrypt.MinCost}, weakBcrypt, false},
+		{"higher bcrypt cost stays", BcryptHasher{Cost: bcrypt.MinCost}, bcrypt5, false},
+		{"lower bcrypt cost", BcryptHasher{Cost: bcrypt.MinCost + 1}, weakBcrypt, true},
+		{"argon2id to bcrypt", BcryptHasher{Cost: bcrypt.MinCost}, weakArgon, true},
+		{"bcrypt to argon2id", Argon2idHasher{Params: fastArgon}, weakBcrypt, true},
+		{"same argon2id params", Argon2idHasher{Params: fastArgon}, weakArgon, false},
+		{"more argon2id memory", Argon2idHasher{Params: strongerArgon}, weakArgon, true},
+		{"unknown hash", Argon2idHasher{Params: fastArgon}, "plaintext", true},
+	}
+	for _, c := range cases {
+		if got := c.hasher.NeedsRehash(c.hash); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestNewHasher_ValidatesParameters(t *testing.T) {
+	if _, err := NewHasher(Bcrypt, 10, DefaultArgon2idParams); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewHasher(Argon2id, 0, DefaultArgon2idParams); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewHasher(Bcrypt, 3, DefaultArgon2idParams); err == nil {
+		t.Errorf("expected a too low bcrypt cost to be refused")
+	}
+	if _, err := NewHasher(Argon2id, 10, Argon2idParams{Memory: 64, Parallelism: 1, SaltLength: 16, KeyLength: 32}); err == nil {
+		t.Errorf("expected zero iterations to be refused")
+	}
+	if _, err := NewHasher("md5", 10, DefaultArgon2idParams); err == nil {
+		t.Errorf("expected an unknown algorithm to be refused")
+	}
+}
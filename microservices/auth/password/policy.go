@@ -0,0 +1,63 @@
+// Package password holds the rules new passwords must follow and how they
+// are hashed.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy is what a new password must satisfy. MaxLength is in bytes, the
+// unit hashers limit passwords in; with bcrypt it must be at most
+// BcryptMaxLength.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MinClasses is how many of lower case letters, upper case letters,
+	// digits and other characters the password must mix; zero disables it.
+	MinClasses int
+	// Denylist holds known breached passwords, which are refused.
+	Denylist Denylist
+}
+
+var DefaultPolicy = Policy{MinLength: 8, MaxLength: 72}
+
+// Check returns an error describing why password breaks the policy, or nil.
+func (p Policy) Check(password string) error {
+	if password == "" {
+		return errors.New("Password is required")
+	}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return fmt.Errorf("Password must be at most %d bytes long", p.MaxLength)
+	}
+	if classes(password) < p.MinClasses {
+		return fmt.Errorf("Password must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinClasses)
+	}
+	if p.Denylist.Contains(password) {
+		return errors.New("Password is too common or has appeared in a data breach")
+	}
+	return nil
+}
+
+// classes counts the character classes password uses.
+func classes(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
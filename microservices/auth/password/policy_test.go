@@ -0,0 +1,78 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	p := Policy{MinLength: 8, MaxLength: 16}
+
+	cases := map[string]bool{
+		"":                     false,
+		"short":                false,
+		"éééééééé":             true,
+		"long enough":          true,
+		"this is far too long": false,
+	}
+	for pw, ok := range cases {
+		if err := p.Check(pw); (err == nil) != ok {
+			t.Errorf("%q: expected ok=%v, got %v", pw, ok, err)
+		}
+	}
+}
+
+func TestPolicy_CheckClasses(t *testing.T) {
+	p := Policy{MinLength: 8, MinClasses: 3}
+
+	cases := map[string]bool{
+		"alllowercase": false,
+		"lowerUPPER":   false,
+		"lowerUPPER1":  true,
+		"lower-1234":   true,
+		"Ünïcödé-pass": true,
+	}
+	for pw, ok := range cases {
+		if err := p.Check(pw); (err == nil) != ok {
+			t.Errorf("%q: expected ok=%v, got %v", pw, ok, err)
+		}
+	}
+}
+
+func TestPolicy_CheckDenylist(t *testing.T) {
+	list, err := ReadDenylist(strings.NewReader("# top passwords\npassword1\n\n  Qwerty123  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Policy{MinLength: 8, Denylist: list}
+
+	for _, pw := range []string{"password1", "PASSWORD1", "qwerty123"} {
+		if err := p.Check(pw); err == nil || !strings.Contains(err.Error(), "breach") {
+			t.Errorf("%q: expected the denylist to refuse it, got %v", pw, err)
+		}
+	}
+	if err := p.Check("correct horse"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("expected comments and blank lines to be skipped, got %v", list)
+	}
+}
+
+func TestLoadDenylist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("letmein1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadDenylist(path)
+
+	if err != nil || !list.Contains("LetMeIn1") {
+		t.Errorf("expected the file to be loaded, got %v, %v", list, err)
+	}
+	if _, err := LoadDenylist(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected a missing file to be an error")
+	}
+}
@@ -0,0 +1,65 @@
+// Package rbac defines the roles users can hold and the permissions each
+// role grants. Permissions are "<resource>:<action>"; "*" and
+// "<resource>:*" grant everything, respectively everything on a resource.
+package rbac
+
+import "slices"
+
+const (
+	CustomersRead   = "customers:read"
+	CustomersWrite  = "customers:write"
+	CustomersDelete = "customers:delete"
+	ProductsRead    = "products:read"
+	ProductsWrite   = "products:write"
+	ProductsDelete  = "products:delete"
+	UsersRead       = "users:read"
+	UsersWrite      = "users:write"
+	UsersDelete     = "users:delete"
+	UsersRoles      = "users:roles"
+
+	All = "*"
+)
+
+const (
+	RoleUser    = "user"
+	RoleManager = "manager"
+	RoleAdmin   = "admin"
+
+	// DefaultRole is given to new users, and assumed for users stored
+	// before roles existed.
+	DefaultRole = RoleUser
+)
+
+var roles = map[string][]string{
+	RoleUser:    {CustomersRead, ProductsRead},
+	RoleManager: {CustomersRead, CustomersWrite, ProductsRead, ProductsWrite},
+	RoleAdmin:   {All},
+}
+
+// ValidRole reports whether role exists.
+func ValidRole(role string) bool {
+	_, ok := roles[role]
+	return ok
+}
+
+// Roles returns a user's roles, falling back to DefaultRole for users that
+// have none recorded.
+func Roles(assigned []string) []string {
+	if len(assigned) == 0 {
+		return []string{DefaultRole}
+	}
+	return assigned
+}
+
+// Permissions returns the sorted, de-duplicated permissions granted by
+// roles plus those granted to the user directly.
+func Permissions(assigned []string, direct []string) []string {
+	var perms []string
+	for _, role := range Roles(assigned) {
+		perms = append(perms, roles[role]...)
+	}
+	perms = append(perms, direct...)
+
+	slices.Sort(perms)
+	return slices.Compact(perms)
+}
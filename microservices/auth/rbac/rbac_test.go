@@ -0,0 +1,29 @@
+package rbac
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPermissions_UnionOfRolesAndDirectGrants(t *testing.T) {
+	got := Permissions([]string{RoleUser, RoleManager}, []string{CustomersDelete})
+
+	want := []string{CustomersDelete, CustomersRead, CustomersWrite, ProductsRead, ProductsWrite}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestPermissions_DefaultsToUserRole(t *testing.T) {
+	got := Permissions(nil, nil)
+
+	if !slices.Equal(got, []string{CustomersRead, ProductsRead}) {
+		t.Errorf("expected the user role's permissions, got %v", got)
+	}
+}
+
+func TestValidRole(t *testing.T) {
+	if !ValidRole(RoleAdmin) || ValidRole("root") {
+		t.Errorf("expected only catalogued roles to be valid")
+	}
+}
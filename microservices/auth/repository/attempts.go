@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"iLeon/microservices/auth/database"
+	"iLeon/microservices/auth/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AttemptRepository stores failed login counters. It is the only storage
+// lockout needs, so another backend can be plugged in by implementing it.
+type AttemptRepository interface {
+	// FindAttempts returns ErrNotFound when key has no failures.
+	FindAttempts(ctx context.Context, key string) (*models.LoginAttempts, error)
+	// RecordFailure counts one more failure for key, creating the counter
+	// if needed, and returns the result.
+	RecordFailure(ctx context.Context, key string, at, expiresAt time.Time) (*models.LoginAttempts, error)
+	// LockAttempts locks key until the given time.
+	LockAttempts(ctx context.Context, key string, until, expiresAt time.Time) error
+	// DeleteAttempts forgets key's failures and lock.
+	DeleteAttempts(ctx context.Context, key string) error
+}
+
+type AttemptRepo struct {
+	Mg *database.MongoInstance
+}
+
+func NewAttemptRepo(mg *database.MongoInstance) AttemptRepository {
+	return &AttemptRepo{Mg: mg}
+}
+
+func (r *AttemptRepo) attempts() *mongo.Collection {
+	return r.Mg.Db.Collection("login_attempts")
+}
+
+func (r *AttemptRepo) FindAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	return findOne[models.LoginAttempts](ctx, r.attempts(), bson.D{{Key: "_id", Value: key}})
+}
+
+func (r *AttemptRepo) RecordFailure(ctx context.Context, key string, at, expiresAt time.Time) (*models.LoginAttempts, error) {
+	attempts := &models.LoginAttempts{}
+	err := r.attempts().FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: key}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "failures", Value: 1}}},
+			{Key: "$set", Value: bson.D{
+				{Key: "last_failure_at", Value: at},
+				{Key: "expires_at", Value: expiresAt},
+			}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(attempts)
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func (r *AttemptRepo) LockAttempts(ctx context.Context, key string, until, expiresAt time.Time) error {
+	_, err := r.attempts().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: key}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "locked_until", Value: until},
+			{Key: "expires_at", Value: expiresAt},
+		}}},
+	)
+	return err
+}
+
+func (r *AttemptRepo) DeleteAttempts(ctx context.Context, key string) error {
+	_, err := r.attempts().DeleteOne(ctx, bson.D{{Key: "_id", Value: key}})
+	return err
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"iLeon/microservices/auth/database"
+	"iLeon/microservices/auth/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository stores the audit trail of admin actions. Entries are
+// never updated or deleted.
+type AuditRepository interface {
+	RecordAudit(ctx context.Context, e *models.AuditEntry) error
+	// ListAudit returns up to limit entries, newest first; those about
+	// targetID only, unless it is empty.
+	ListAudit(ctx context.Context, targetID string, limit int) ([]models.AuditEntry, error)
+}
+
+type AuditRepo struct {
+	Mg *database.MongoInstance
+}
+
+func NewAuditRepo(mg *database.MongoInstance) AuditRepository {
+	return &AuditRepo{Mg: mg}
+}
+
+func (r *AuditRepo) auditLog() *mongo.Collection {
+	return r.Mg.Db.Collection("audit_log")
+}
+
+func (r *AuditRepo) RecordAudit(ctx context.Context, e *models.AuditEntry) error {
+	inserted, err := r.auditLog().InsertOne(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	e.ID = inserted.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *AuditRepo) ListAudit(ctx context.Context, targetID string, limit int) ([]models.AuditEntry, error) {
+	filter := bson.D{}
+	if targetID != "" {
+		filter = append(filter, bson.E{Key: "target_id", Value: targetID})
+	}
+	cursor, err := r.auditLog().Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
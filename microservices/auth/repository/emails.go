@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"iLeon/microservices/auth/database"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// EmailConflict is a set of users whose emails are the same but for case,
+// so none of them can be lower-cased without an admin deciding which
+// account keeps the address.
+type EmailConflict struct {
+	Email   string
+	UserIDs []primitive.ObjectID
+}
+
+func (c EmailConflict) String() string {
+	ids := make([]string, len(c.UserIDs))
+	for i, id := range c.UserIDs {
+		ids[i] = id.Hex()
+	}
+	return fmt.Sprintf("%s (%s)", c.Email, strings.Join(ids, ", "))
+}
+
+// emailGroup is what emailGroups reads: the users sharing one lower-cased
+// email.
+type emailGroup struct {
+	Email string        `bson:"_id"`
+	Users []emailHolder `bson:"users"`
+}
+
+type emailHolder struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Email string             `bson:"email"`
+}
+
+// emailGroups groups the users by lower-cased email and returns the groups
+// that need attention: an email not stored lower-cased, or shared by more
+// than one user.
+func emailGroups(ctx context.Context, users *mongo.Collection) ([]emailGroup, error) {
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "$toLower", Value: "$email"}}},
+			{Key: "users", Value: bson.D{{Key: "$push", Value: bson.D{
+				{Key: "_id", Value: "$_id"},
+				{Key: "email", Value: "$email"},
+			}}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "$gt", Value: bson.A{bson.D{{Key: "$size", Value: "$users"}}, 1}}},
+			bson.D{{Key: "$in", Value: bson.A{true, bson.D{{Key: "$map", Value: bson.D{
+				{Key: "input", Value: "$users"},
+				{Key: "in", Value: bson.D{{Key: "$ne", Value: bson.A{"$$this.email", "$_id"}}}},
+			}}}}}},
+		}}}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []emailGroup
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// planEmails splits groups into the emails that can be lower-cased, keyed
+// by the user ID with the stored email as value, and the conflicts that
+// can't.
+func planEmails(groups []emailGroup) (lower map[primitive.ObjectID]string, conflicts []EmailConflict) {
+	lower = make(map[primitive.ObjectID]string)
+	for _, g := range groups {
+		if len(g.Users) == 1 {
+			if g.Users[0].Email != g.Email {
+				lower[g.Users[0].ID] = g.Users[0].Email
+			}
+			continue
+		}
+		c := EmailConflict{Email: g.Email}
+		for _, u := range g.Users {
+			c.UserIDs = append(c.UserIDs, u.ID)
+		}
+		conflicts = append(conflicts, c)
+	}
+	return lower, conflicts
+}
+
+// NormalizeEmails lower-cases the emails stored before addresses were
+// normalized, since logins, resets and verification look them up
+// lower-cased, and returns how many it changed. It leaves alone the users
+// whose emails differ only by case, which EnsureIndexes then reports. It
+// runs on every startup; once every email is lower-cased it changes
+// nothing.
+func NormalizeEmails(ctx context.Context, mg *database.MongoInstance) (int, error) {
+	users := mg.Db.Collection("users")
+	groups, err := emailGroups(ctx, users)
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't look for emails to normalize: %w", err)
+	}
+	lower, _ := planEmails(groups)
+	n := 0
+	for id, email := range lower {
+		// Matching the stored email skips users whose address changed
+		// since it was read.
+		res, err := users.UpdateOne(ctx,
+			bson.D{{Key: "_id", Value: id}, {Key: "email", Value: email}},
+			touched(bson.D{{Key: "$set", Value: bson.D{{Key: "email", Value: strings.ToLower(email)}}}}))
+		if mongo.IsDuplicateKeyError(err) {
+			// Someone registered the lower-cased email meanwhile.
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("Couldn't normalize the email of user %s: %w", id.Hex(), err)
+		}
+		n += int(res.ModifiedCount)
+	}
+	return n, nil
+}
+
+// checkUniqueEmails fails, listing the users, while two users have the
+// same email but for case: the unique index can't be built over exact
+// duplicates, and only one of a set of case variants could log in.
+func checkUniqueEmails(ctx context.Context, users *mongo.Collection) error {
+	groups, err := emailGroups(ctx, users)
+	if err != nil {
+		return fmt.Errorf("Couldn't look for duplicate emails: %w", err)
+	}
+	if _, conflicts := planEmails(groups); len(conflicts) > 0 {
+		return duplicateEmailsError(conflicts)
+	}
+	return nil
+}
+
+func duplicateEmailsError(conflicts []EmailConflict) error {
+	list := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		list[i] = c.String()
+	}
+	return fmt.Errorf("Couldn't create the unique email index, these users share an email, ignoring case; merge them or give them other addresses: %s",
+		strings.Join(list, "; "))
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"iLeon/microservices/auth/database"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestPlanEmails_LowerCasesAStoredMixedCaseEmail(t *testing.T) {
+	id := primitive.NewObjectID()
+	lower, conflicts := planEmails([]emailGroup{
+		{Email: "alice@example.com", Users: []emailHolder{{ID: id, Email: "Alice@Example.COM"}}},
+	})
+	if len(conflicts) != 0 {
+		t.Fatalf("conflicts = %v, want none", conflicts)
+	}
+	if got := lower[id]; got != "Alice@Example.COM" || len(lower) != 1 {
+		t.Fatalf("lower = %v, want only %s with its stored email", lower, id.Hex())
+	}
+}
+
+func TestPlanEmails_LeavesCaseVariantsToAnAdmin(t *testing.T) {
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	lower, conflicts := planEmails([]emailGroup{
+		{Email: "bob@example.com", Users: []emailHolder{
+			{ID: a, Email: "bob@example.com"},
+			{ID: b, Email: "Bob@example.com"},
+		}},
+	})
+	if len(lower) != 0 {
+		t.Fatalf("lower = %v, want nothing lower-cased", lower)
+	}
+	if len(conflicts) != 1 || conflicts[0].Email != "bob@example.com" ||
+		len(conflicts[0].UserIDs) != 2 || conflicts[0].UserIDs[0] != a || conflicts[0].UserIDs[1] != b {
+		t.Fatalf("conflicts = %v, want bob@example.com with both users", conflicts)
+	}
+	want := "bob@example.com (" + a.Hex() + ", " + b.Hex() + ")"
+	if got := conflicts[0].String(); got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestPlanEmails_SkipsNormalizedEmails(t *testing.T) {
+	lower, conflicts := planEmails([]emailGroup{
+		{Email: "carol@example.com", Users: []emailHolder{{ID: primitive.NewObjectID(), Email: "carol@example.com"}}},
+	})
+	if len(lower) != 0 || len(conflicts) != 0 {
+		t.Fatalf("lower = %v, conflicts = %v, want nothing to do", lower, conflicts)
+	}
+}
+
+func TestNormalizeEmails_LowerCasesAStoredMixedCaseEmail(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("normalize", func(mt *mtest.T) {
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, mt.DB.Name()+".users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: "alice@example.com"},
+				{Key: "users", Value: bson.A{bson.D{{Key: "_id", Value: id}, {Key: "email", Value: "Alice@Example.COM"}}}},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		n, err := NormalizeEmails(context.Background(), &database.MongoInstance{Client: mt.Client, Db: mt.DB})
+		if err != nil {
+			mt.Fatal(err)
+		}
+		if n != 1 {
+			mt.Fatalf("n = %d, want 1", n)
+		}
+
+		mt.GetStartedEvent() // the aggregate
+		update := mt.GetStartedEvent()
+		if update == nil || update.CommandName != "update" {
+			mt.Fatalf("second command = %v, want an update", update)
+		}
+		u := update.Command.Lookup("updates").Array().Index(0).Value().Document()
+		if got := u.Lookup("q", "email").StringValue(); got != "Alice@Example.COM" {
+			mt.Errorf("filter email = %q, want the stored one", got)
+		}
+		if got := u.Lookup("q", "_id").ObjectID(); got != id {
+			mt.Errorf("filter _id = %s, want %s", got.Hex(), id.Hex())
+		}
+		if got := u.Lookup("u", "$set", "email").StringValue(); got != "alice@example.com" {
+			mt.Errorf("new email = %q, want alice@example.com", got)
+		}
+	})
+}
+
+func TestEnsureIndexes_ListsUsersSharingAnEmail(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("duplicates", func(mt *mtest.T) {
+		a, b := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, mt.DB.Name()+".users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: "bob@example.com"},
+				{Key: "users", Value: bson.A{
+					bson.D{{Key: "_id", Value: a}, {Key: "email", Value: "bob@example.com"}},
+					bson.D{{Key: "_id", Value: b}, {Key: "email", Value: "bob@example.com"}},
+				}},
+			}),
+		)
+
+		err := EnsureIndexes(context.Background(), &database.MongoInstance{Client: mt.Client, Db: mt.DB})
+		if err == nil {
+			mt.Fatal("EnsureIndexes succeeded, want the duplicates reported")
+		}
+		for _, want := range []string{"bob@example.com", a.Hex(), b.Hex()} {
+			if !strings.Contains(err.Error(), want) {
+				mt.Errorf("error %q doesn't mention %s", err, want)
+			}
+		}
+		if ev := mt.GetStartedEvent(); ev == nil || ev.CommandName != "aggregate" {
+			mt.Fatalf("first command = %v, want the aggregate", ev)
+		}
+		if ev := mt.GetStartedEvent(); ev != nil {
+			mt.Errorf("ran %s after finding duplicates, want no index built", ev.CommandName)
+		}
+	})
+}
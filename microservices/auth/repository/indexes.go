@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"iLeon/microservices/auth/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexes lists the indexes each collection needs. Creating an index that
+// already exists is a no-op, so EnsureIndexes runs on every startup.
+var indexes = map[string][]mongo.IndexModel{
+	// The unique email index is what keeps concurrent registrations from
+	// creating two users with one email.
+	"users": {
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"refresh_tokens": {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		// Mongo drops tokens once they expire.
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"signing_keys": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"login_attempts": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"one_time_tokens": {
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"outbox": {
+		{Keys: bson.D{{Key: "published_at", Value: 1}, {Key: "created_at", Value: 1}}},
+		// Published events are kept a week for debugging.
+		{Keys: bson.D{{Key: "published_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(7 * 24 * 60 * 60)},
+	},
+	"audit_log": {
+		{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "at", Value: -1}}},
+		{Keys: bson.D{{Key: "at", Value: -1}}},
+	},
+	"revoked_tokens": {
+		{Keys: bson.D{{Key: "revoked_at", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+}
+
+// EnsureIndexes creates the indexes, after checking that no two users share
+// an email; run NormalizeEmails first so that case variants of one user's
+// address don't count.
+func EnsureIndexes(ctx context.Context, mg *database.MongoInstance) error {
+	if err := checkUniqueEmails(ctx, mg.Db.Collection("users")); err != nil {
+		return err
+	}
+	for collection, models := range indexes {
+		if _, err := mg.Db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("Couldn't create indexes on %s: %w", collection, err)
+		}
+	}
+	return nil
+}
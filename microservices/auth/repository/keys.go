@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"iLeon/microservices/auth/database"
+	"iLeon/microservices/auth/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// KeyRepository stores the JWT signing key ring.
+type KeyRepository interface {
+	// ListSigningKeys returns the keys that have not expired, newest first.
+	ListSigningKeys(ctx context.Context, now time.Time) ([]models.SigningKey, error)
+	// InsertSigningKey reports false when a key with the same ID exists,
+	// i.e. another replica created it first.
+	InsertSigningKey(ctx context.Context, key models.SigningKey) (bool, error)
+}
+
+type KeyRepo struct {
+	Mg *database.MongoInstance
+}
+
+func NewKeyRepo(mg *database.MongoInstance) KeyRepository {
+	return &KeyRepo{Mg: mg}
+}
+
+func (r *KeyRepo) signingKeys() *mongo.Collection {
+	return r.Mg.Db.Collection("signing_keys")
+}
+
+func (r *KeyRepo) ListSigningKeys(ctx context.Context, now time.Time) ([]models.SigningKey, error) {
+	cursor, err := r.signingKeys().Find(ctx,
+		bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}}},
+		options.Find().SetSort(bson.D{{Key: "not_before", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []models.SigningKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *KeyRepo) InsertSigningKey(ctx context.Context, key models.SigningKey) (bool, error) {
+	_, err := r.signingKeys().InsertOne(ctx, key)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"iLeon/microservices/auth/database"
+	"iLeon/microservices/auth/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OneTimeTokenRepository stores the single-use tokens mailed to users.
+type OneTimeTokenRepository interface {
+	CreateOneTimeToken(ctx context.Context, t *models.OneTimeToken) error
+	// FindOneTimeToken returns the unused, unexpired token with the hash and
+	// purpose without spending it.
+	FindOneTimeToken(ctx context.Context, tokenHash, purpose string, now time.Time) (*models.OneTimeToken, error)
+	// ConsumeOneTimeToken marks the unused, unexpired token with the hash
+	// and purpose as used and returns it. Of concurrent callers only one
+	// succeeds; the others get ErrNotFound.
+	ConsumeOneTimeToken(ctx context.Context, tokenHash, purpose string, now time.Time) (*models.OneTimeToken, error)
+	// InvalidateOneTimeTokens marks the user's unused tokens for purpose as
+	// used, so only the latest one works.
+	InvalidateOneTimeTokens(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time) error
+}
+
+type OneTimeTokenRepo struct {
+	Mg *database.MongoInstance
+}
+
+func NewOneTimeTokenRepo(mg *database.MongoInstance) OneTimeTokenRepository {
+	return &OneTimeTokenRepo{Mg: mg}
+}
+
+func (r *OneTimeTokenRepo) tokens() *mongo.Collection {
+	return r.Mg.Db.Collection("one_time_tokens")
+}
+
+func (r *OneTimeTokenRepo) CreateOneTimeToken(ctx context.Context, t *models.OneTimeToken) error {
+	inserted, err := r.tokens().InsertOne(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	t.ID = inserted.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *OneTimeTokenRepo) FindOneTimeToken(ctx context.Context, tokenHash, purpose string, now time.Time) (*models.OneTimeToken, error) {
+	return findOne[models.OneTimeToken](ctx, r.tokens(), liveToken(tokenHash, purpose, now))
+}
+
+func (r *OneTimeTokenRepo) ConsumeOneTimeToken(ctx context.Context, tokenHash, purpose string, now time.Time) (*models.OneTimeToken, error) {
+	t := &models.OneTimeToken{}
+	err := r.tokens().FindOneAndUpdate(ctx,
+		liveToken(tokenHash, purpose, now),
+		bson.D{{Key: "$set", Value: bson.D{{Key: "used_at", Value: now}}}},
+	).Decode(t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *OneTimeTokenRepo) InvalidateOneTimeTokens(ctx context.Context, userID primitive.ObjectID, purpose string, now time.Time) error {
+	_, err := r.tokens().UpdateMany(ctx,
+		bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "purpose", Value: purpose},
+			{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "used_at", Value: now}}}},
+	)
+	return err
+}
+
+// liveToken matches the unused, unexpired token with the hash and purpose.
+func liveToken(tokenHash, purpose string, now time.Time) bson.D {
+	return bson.D{
+		{Key: "token_hash", Value: tokenHash},
+		{Key: "purpose", Value: purpose},
+		{Key: "used_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+}
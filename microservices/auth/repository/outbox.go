@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"iLeon/microservices/auth/database"
+	"iLeon/microservices/auth/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OutboxRepository stores events until they have been published.
+type OutboxRepository interface {
+	EnqueueEvent(ctx context.Context, e *models.OutboxEvent) error
+	// PendingEvents returns up to limit unpublished events, oldest first.
+	PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	// MarkPublished records that the event went out and drops its payload.
+	MarkPublished(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+type OutboxRepo struct {
+	Mg *database.MongoInstance
+}
+
+func NewOutboxRepo(mg *database.MongoInstance) OutboxRepository {
+	return &OutboxRepo{Mg: mg}
+}
+
+func (r *OutboxRepo) outbox() *mongo.Collection {
+	return r.Mg.Db.Collection("outbox")
+}
+
+func (r *OutboxRepo) EnqueueEvent(ctx context.Context, e *models.OutboxEvent) error {
+	inserted, err := r.outbox().InsertOne(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	e.ID = inserted.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *OutboxRepo) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	cursor, err := r.outbox().Find(ctx,
+		bson.D{{Key: "published_at", Value: bson.D{{Key: "$exists", Value: false}}}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []models.OutboxEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *OutboxRepo) MarkPublished(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.outbox().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{
+			{Key: "$set", Value: bson.D{{Key: "published_at", Value: at}}},
+			{Key: "$unset", Value: bson.D{{Key: "payload", Value: ""}}},
+		},
+	)
+	return err
+}
@@ -2,21 +2,67 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"iLeon/microservices/auth/database"
 	"iLeon/microservices/auth/models"
-	"os"
-	"time"
+	"regexp"
 
-	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrNotFound is returned when a lookup matches no document.
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicate is returned when a write would break a unique index, e.g. a
+// second user with the same email.
+var ErrDuplicate = errors.New("duplicate")
+
+// AuthRepository stores the users. Credential checks and token issuance live
+// in the service.
 type AuthRepository interface {
-	Login(body models.LoginUserBody) *models.CustomeResponse
-	Register(models.CreateUserBody) *models.CustomeResponse
+	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
+	FindUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	// CreateUser inserts user and sets its ID. It returns ErrDuplicate if
+	// the email is taken.
+	CreateUser(ctx context.Context, user *models.User) error
+	// UpdatePassword replaces the user's password hash and lifts a forced
+	// reset.
+	UpdatePassword(ctx context.Context, id primitive.ObjectID, hash string) error
+	// ReplacePasswordHash swaps a hash for one of the same password, made
+	// with other parameters. Nothing changes if the hash is no longer
+	// oldHash, e.g. because the password was changed meanwhile.
+	ReplacePasswordHash(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error
+	MarkEmailVerified(ctx context.Context, id primitive.ObjectID) error
+	// AddRole and RemoveRole return the updated user.
+	AddRole(ctx context.Context, id primitive.ObjectID, role string) (*models.User, error)
+	RemoveRole(ctx context.Context, id primitive.ObjectID, role string) (*models.User, error)
+	// UpdateProfile sets the fields that aren't nil and returns the user.
+	UpdateProfile(ctx context.Context, id primitive.ObjectID, username, displayName *string) (*models.User, error)
+	SetPendingEmail(ctx context.Context, id primitive.ObjectID, email string) error
+	// ConfirmEmail makes the pending email the user's verified email. It
+	// returns ErrNotFound if email is no longer the pending one, and
+	// ErrDuplicate if another user has it by now.
+	ConfirmEmail(ctx context.Context, id primitive.ObjectID, email string) error
+	DeleteUser(ctx context.Context, id primitive.ObjectID) error
+	// ListUsers returns a page of the users matching query, oldest first,
+	// and how many match in total. Page and Limit must be positive.
+	ListUsers(ctx context.Context, query models.UserQuery) ([]models.User, int, error)
+	// SetStatus and SetMustResetPassword return the updated user.
+	SetStatus(ctx context.Context, id primitive.ObjectID, status string) (*models.User, error)
+	SetMustResetPassword(ctx context.Context, id primitive.ObjectID, must bool) (*models.User, error)
+	// SetMFA replaces the user's MFA enrolment; ClearMFA removes it.
+	SetMFA(ctx context.Context, id primitive.ObjectID, mfa *models.MFA) error
+	ClearMFA(ctx context.Context, id primitive.ObjectID) error
+	// UseTOTPStep records step as the last one a code was accepted for. It
+	// reports false if that step or a later one was already used, so of
+	// concurrent callers with the same code only one succeeds.
+	UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error)
+	// UseRecoveryCode removes the recovery code hash, reporting false if the
+	// user didn't have it.
+	UseRecoveryCode(ctx context.Context, id primitive.ObjectID, codeHash string) (bool, error)
 }
 
 type Repository struct {
@@ -30,105 +76,234 @@ func NewRepo(mg *database.MongoInstance) AuthRepository {
 	}
 }
 
-func (r *Repository) Login(body models.LoginUserBody) *models.CustomeResponse {
+func (r *Repository) users() *mongo.Collection {
+	return r.Mg.Db.Collection("users")
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func (r *Repository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return findOne[models.User](ctx, r.users(), bson.D{primitive.E{Key: "email", Value: email}})
+}
 
-	users := r.Mg.Db.Collection("users")
-	query := bson.D{primitive.E{Key: "email", Value: body.Email}}
+func (r *Repository) FindUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return findOne[models.User](ctx, r.users(), bson.D{primitive.E{Key: "_id", Value: id}})
+}
 
-	user := users.FindOne(ctx, query)
+func (r *Repository) CreateUser(ctx context.Context, user *models.User) error {
+	inserted, err := r.users().InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
 
-	readUser := &models.CreateUserBody{}
-	user.Decode(readUser)
+	user.ID = inserted.InsertedID.(primitive.ObjectID)
+	return nil
+}
 
-	if readUser.Email == "" {
-		return &models.CustomeResponse{
-			Msg:     "Invalid user email or password",
-			Context: false,
-		}
+func (r *Repository) UpdatePassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	_, err := r.updateUser(ctx, id, bson.D{
+		{Key: "$set", Value: bson.D{{Key: "password", Value: hash}}},
+		{Key: "$unset", Value: bson.D{{Key: "must_reset_password", Value: ""}}},
+	})
+	return err
+}
+
+func (r *Repository) ReplacePasswordHash(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error {
+	_, err := r.users().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "password", Value: oldHash}},
+		touched(bson.D{{Key: "$set", Value: bson.D{{Key: "password", Value: newHash}}}}),
+	)
+	return err
+}
+
+func (r *Repository) MarkEmailVerified(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.updateUser(ctx, id, bson.D{{Key: "$set", Value: bson.D{{Key: "email_verified", Value: true}}}})
+	return err
+}
+
+func (r *Repository) AddRole(ctx context.Context, id primitive.ObjectID, role string) (*models.User, error) {
+	return r.updateUser(ctx, id, bson.D{{Key: "$addToSet", Value: bson.D{{Key: "roles", Value: role}}}})
+}
+
+func (r *Repository) RemoveRole(ctx context.Context, id primitive.ObjectID, role string) (*models.User, error) {
+	return r.updateUser(ctx, id, bson.D{{Key: "$pull", Value: bson.D{{Key: "roles", Value: role}}}})
+}
+
+func (r *Repository) UpdateProfile(ctx context.Context, id primitive.ObjectID, username, displayName *string) (*models.User, error) {
+	set := bson.D{}
+	if username != nil {
+		set = append(set, bson.E{Key: "username", Value: *username})
+	}
+	if displayName != nil {
+		set = append(set, bson.E{Key: "display_name", Value: *displayName})
 	}
+	if len(set) == 0 {
+		return r.FindUserByID(ctx, id)
+	}
+	return r.updateUser(ctx, id, bson.D{{Key: "$set", Value: set}})
+}
 
-	err := bcrypt.CompareHashAndPassword([]byte(readUser.Password), []byte(body.Password))
+func (r *Repository) SetPendingEmail(ctx context.Context, id primitive.ObjectID, email string) error {
+	_, err := r.updateUser(ctx, id, bson.D{{Key: "$set", Value: bson.D{{Key: "pending_email", Value: email}}}})
+	return err
+}
 
+func (r *Repository) ConfirmEmail(ctx context.Context, id primitive.ObjectID, email string) error {
+	res, err := r.users().UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "pending_email", Value: email}},
+		touched(bson.D{
+			{Key: "$set", Value: bson.D{{Key: "email", Value: email}, {Key: "email_verified", Value: true}}},
+			{Key: "$unset", Value: bson.D{{Key: "pending_email", Value: ""}}},
+		}),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
 	if err != nil {
-		return &models.CustomeResponse{
-			Msg:     "Invalid password",
-			Context: false,
-		}
+		return err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": body.Email,
-		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
 
-	// Sign and get the complete encoded token as a string using the secret
+func (r *Repository) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.users().DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+func (r *Repository) ListUsers(ctx context.Context, query models.UserQuery) ([]models.User, int, error) {
+	filter := bson.D{}
+	if query.Search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query.Search), Options: "i"}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "username", Value: pattern}},
+			bson.D{{Key: "email", Value: pattern}},
+		}})
+	}
+	switch query.Status {
+	case "":
+	case models.StatusActive:
+		// Users stored before statuses existed have none.
+		filter = append(filter, bson.E{Key: "status", Value: bson.D{{Key: "$ne", Value: models.StatusDisabled}}})
+	default:
+		filter = append(filter, bson.E{Key: "status", Value: query.Status})
+	}
+	if query.Role != "" {
+		filter = append(filter, bson.E{Key: "roles", Value: query.Role})
+	}
 
+	total, err := r.users().CountDocuments(ctx, filter)
 	if err != nil {
-		return &models.CustomeResponse{
-			Msg:     "Invalid password, faild to create token",
-			Context: false,
-		}
+		return nil, 0, err
+	}
+	cursor, err := r.users().Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip(int64((query.Page-1)*query.Limit)).
+		SetLimit(int64(query.Limit)))
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return &models.CustomeResponse{
-		Msg:     tokenString,
-		Context: true,
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
 	}
+	return users, int(total), nil
+}
 
+func (r *Repository) SetStatus(ctx context.Context, id primitive.ObjectID, status string) (*models.User, error) {
+	return r.updateUser(ctx, id, bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: status}}}})
 }
 
-func (r *Repository) Register(body models.CreateUserBody) *models.CustomeResponse {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func (r *Repository) SetMustResetPassword(ctx context.Context, id primitive.ObjectID, must bool) (*models.User, error) {
+	return r.updateUser(ctx, id, bson.D{{Key: "$set", Value: bson.D{{Key: "must_reset_password", Value: must}}}})
+}
 
-	users := r.Mg.Db.Collection("users")
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), 10)
-	query := bson.D{primitive.E{Key: "email", Value: body.Email}}
+func (r *Repository) SetMFA(ctx context.Context, id primitive.ObjectID, mfa *models.MFA) error {
+	_, err := r.updateUser(ctx, id, bson.D{{Key: "$set", Value: bson.D{{Key: "mfa", Value: mfa}}}})
+	return err
+}
 
+func (r *Repository) ClearMFA(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.updateUser(ctx, id, bson.D{{Key: "$unset", Value: bson.D{{Key: "mfa", Value: ""}}}})
+	return err
+}
+
+func (r *Repository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	res, err := r.users().UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "mfa.last_step", Value: bson.D{{Key: "$lt", Value: step}}},
+		},
+		touched(bson.D{{Key: "$set", Value: bson.D{{Key: "mfa.last_step", Value: step}}}}),
+	)
 	if err != nil {
-		fmt.Println(err)
-		return &models.CustomeResponse{
-			Msg:     "Couldn't hash the password",
-			Context: false,
-		}
+		return false, err
 	}
+	return res.ModifiedCount == 1, nil
+}
 
-	requestBody := &models.CreateUserBody{
-		Username: body.Username,
-		Email:    body.Email,
-		Password: string(hashPassword),
+func (r *Repository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, codeHash string) (bool, error) {
+	res, err := r.users().UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: id},
+			{Key: "mfa.recovery_codes", Value: codeHash},
+		},
+		touched(bson.D{{Key: "$pull", Value: bson.D{{Key: "mfa.recovery_codes", Value: codeHash}}}}),
+	)
+	if err != nil {
+		return false, err
 	}
+	return res.ModifiedCount == 1, nil
+}
 
-	// Check if user already exists
-	databseUser := users.FindOne(ctx, query)
-	if err := databseUser.Err(); err == nil {
-		existingUser := &models.CreateUserBody{}
-		if err := databseUser.Decode(existingUser); err == nil {
-			if existingUser.Email == body.Email {
-				return &models.CustomeResponse{
-					Msg:     "This user with the current email already exists!",
-					Context: false,
-				}
-			}
-		}
+// updateUser applies update to the user and returns the result.
+func (r *Repository) updateUser(ctx context.Context, id primitive.ObjectID, update bson.D) (*models.User, error) {
+	user := &models.User{}
+	err := r.users().FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		touched(update),
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrDuplicate
 	}
-
-	insertedUser, err := users.InsertOne(ctx, requestBody)
-
 	if err != nil {
-		fmt.Println(err)
-		return &models.CustomeResponse{
-			Msg:     "Couldn't insert the new user into the database",
-			Context: false,
-		}
+		return nil, err
 	}
+	return user, nil
+}
 
-	return &models.CustomeResponse{
-		Msg:     "Created the new user with ID " + insertedUser.InsertedID.(primitive.ObjectID).Hex(),
-		Context: true,
+// touched adds to a user update what every change does: bump the version
+// and set updated_at.
+func touched(update bson.D) bson.D {
+	return append(update,
+		bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+		bson.E{Key: "$currentDate", Value: bson.D{{Key: "updated_at", Value: true}}},
+	)
+}
+
+// findOne decodes the first document matching filter, translating
+// mongo.ErrNoDocuments into ErrNotFound.
+func findOne[T any](ctx context.Context, c *mongo.Collection, filter any) (*T, error) {
+	doc := new(T)
+	if err := c.FindOne(ctx, filter).Decode(doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
+	return doc, nil
 }
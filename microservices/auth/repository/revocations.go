@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"iLeon/microservices/auth/database"
+	"iLeon/microservices/auth/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RevocationRepository stores revoked access tokens until they expire.
+type RevocationRepository interface {
+	// SaveRevocation inserts r, replacing an earlier revocation with the
+	// same ID.
+	SaveRevocation(ctx context.Context, r models.Revocation) error
+	// RevocationsSince returns the live revocations made at or after since.
+	RevocationsSince(ctx context.Context, since, now time.Time) ([]models.Revocation, error)
+}
+
+type RevocationRepo struct {
+	Mg *database.MongoInstance
+}
+
+func NewRevocationRepo(mg *database.MongoInstance) RevocationRepository {
+	return &RevocationRepo{Mg: mg}
+}
+
+func (r *RevocationRepo) revocations() *mongo.Collection {
+	return r.Mg.Db.Collection("revoked_tokens")
+}
+
+func (r *RevocationRepo) SaveRevocation(ctx context.Context, rev models.Revocation) error {
+	_, err := r.revocations().ReplaceOne(ctx,
+		bson.D{{Key: "_id", Value: rev.ID}},
+		rev,
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *RevocationRepo) RevocationsSince(ctx context.Context, since, now time.Time) ([]models.Revocation, error) {
+	cursor, err := r.revocations().Find(ctx, bson.D{
+		{Key: "revoked_at", Value: bson.D{{Key: "$gte", Value: since}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := []models.Revocation{}
+	if err := cursor.All(ctx, &revocations); err != nil {
+		return nil, err
+	}
+	return revocations, nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"iLeon/microservices/auth/database"
+	"iLeon/microservices/auth/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SessionRepository stores refresh tokens, grouped into one family per
+// signed-in device.
+type SessionRepository interface {
+	CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error
+	FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// MarkRotated flags a live token as used. It reports false if the token
+	// was already rotated or revoked, i.e. another request spent it first.
+	MarkRotated(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, at time.Time) error
+	// ListActive returns the live token of each of the user's families.
+	ListActive(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]models.RefreshToken, error)
+}
+
+type SessionRepo struct {
+	Mg *database.MongoInstance
+}
+
+func NewSessionRepo(mg *database.MongoInstance) SessionRepository {
+	return &SessionRepo{Mg: mg}
+}
+
+func (r *SessionRepo) refreshTokens() *mongo.Collection {
+	return r.Mg.Db.Collection("refresh_tokens")
+}
+
+// live matches tokens that have been neither rotated nor revoked.
+func live(filter bson.D) bson.D {
+	return append(filter,
+		primitive.E{Key: "rotated_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		primitive.E{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	)
+}
+
+func (r *SessionRepo) CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	inserted, err := r.refreshTokens().InsertOne(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	t.ID = inserted.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *SessionRepo) FindRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	return findOne[models.RefreshToken](ctx, r.refreshTokens(), bson.D{{Key: "token_hash", Value: tokenHash}})
+}
+
+func (r *SessionRepo) MarkRotated(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	res, err := r.refreshTokens().UpdateOne(ctx,
+		live(bson.D{{Key: "_id", Value: id}}),
+		bson.D{{Key: "$set", Value: bson.D{{Key: "rotated_at", Value: at}}}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *SessionRepo) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	return r.revoke(ctx, bson.D{{Key: "family_id", Value: familyID}}, at)
+}
+
+func (r *SessionRepo) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	return r.revoke(ctx, bson.D{{Key: "user_id", Value: userID}}, at)
+}
+
+func (r *SessionRepo) revoke(ctx context.Context, filter bson.D, at time.Time) error {
+	filter = append(filter, primitive.E{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}})
+	_, err := r.refreshTokens().UpdateMany(ctx, filter,
+		bson.D{{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: at}}}},
+	)
+	return err
+}
+
+func (r *SessionRepo) ListActive(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]models.RefreshToken, error) {
+	cursor, err := r.refreshTokens().Find(ctx,
+		live(bson.D{
+			{Key: "user_id", Value: userID},
+			{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+		}),
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []models.RefreshToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
@@ -0,0 +1,198 @@
+// Package revocation tracks revoked access tokens. Revocations are written
+// to Mongo and kept in memory; every replica polls Mongo for the ones made
+// elsewhere, so checking a token never costs a database round trip.
+package revocation
+
+import (
+	"context"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/token"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSyncInterval = 5 * time.Second
+
+	// syncOverlap re-reads revocations slightly older than the last sync to
+	// absorb clock skew between replicas.
+	syncOverlap = time.Minute
+)
+
+type Store struct {
+	repo repository.RevocationRepository
+	now  func() time.Time
+
+	mu sync.RWMutex
+	// tokens maps a revoked jti to when the token expires.
+	tokens map[string]time.Time
+	// users maps a user ID to its revocation; tokens issued before its
+	// RevokedAt are revoked.
+	users map[string]models.Revocation
+	// sessions maps a revoked session ID to when its last token expires.
+	sessions map[string]time.Time
+	synced   time.Time
+}
+
+type Option func(*Store)
+
+// WithClock replaces time.Now when stamping and expiring revocations.
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) { s.now = now }
+}
+
+func NewStore(repo repository.RevocationRepository, opts ...Option) *Store {
+	s := &Store{
+		repo:     repo,
+		now:      time.Now,
+		tokens:   map[string]time.Time{},
+		users:    map[string]models.Revocation{},
+		sessions: map[string]time.Time{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// RevokeToken revokes one access token until it expires.
+func (s *Store) RevokeToken(ctx context.Context, claims *token.Claims) error {
+	return s.save(ctx, models.Revocation{
+		ID:        claims.ID,
+		Kind:      models.RevokedToken,
+		UserID:    claims.Subject,
+		RevokedAt: s.now(),
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+}
+
+// RevokeUser revokes every access token issued to userID before the current
+// second. Token issue times have second precision, so the cut is made on a
+// whole second and tokens issued right after the revocation, such as those
+// of the next login, stay valid. ttl is the access token lifetime, after
+// which the revocation can be forgotten.
+func (s *Store) RevokeUser(ctx context.Context, userID string, ttl time.Duration) error {
+	now := s.now().Truncate(time.Second)
+	return s.save(ctx, models.Revocation{
+		ID:        models.RevokedUser + ":" + userID,
+		Kind:      models.RevokedUser,
+		UserID:    userID,
+		RevokedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+}
+
+// RevokeSession revokes every access token of one session, leaving the
+// user's other sessions alone. ttl is the access token lifetime.
+func (s *Store) RevokeSession(ctx context.Context, userID, sessionID string, ttl time.Duration) error {
+	now := s.now()
+	return s.save(ctx, models.Revocation{
+		ID:        models.RevokedSession + ":" + sessionID,
+		Kind:      models.RevokedSession,
+		UserID:    userID,
+		SessionID: sessionID,
+		RevokedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+}
+
+func (s *Store) save(ctx context.Context, r models.Revocation) error {
+	if err := s.repo.SaveRevocation(ctx, r); err != nil {
+		return err
+	}
+	s.add(r)
+	return nil
+}
+
+// IsRevoked reports whether claims belong to a revoked token.
+func (s *Store) IsRevoked(claims *token.Claims) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.tokens[claims.ID]; ok {
+		return true
+	}
+	if _, ok := s.sessions[claims.SessionID]; ok && claims.SessionID != "" {
+		return true
+	}
+	if r, ok := s.users[claims.Subject]; ok && claims.IssuedAt != nil {
+		return claims.IssuedAt.Before(r.RevokedAt)
+	}
+	return false
+}
+
+func (s *Store) add(r models.Revocation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Kind {
+	case models.RevokedToken:
+		s.tokens[r.ID] = r.ExpiresAt
+	case models.RevokedUser:
+		if cur, ok := s.users[r.UserID]; !ok || r.RevokedAt.After(cur.RevokedAt) {
+			s.users[r.UserID] = r
+		}
+	case models.RevokedSession:
+		s.sessions[r.SessionID] = r.ExpiresAt
+	}
+}
+
+// Sync loads the revocations made since the previous sync and forgets the
+// expired ones. The first call loads everything still live.
+func (s *Store) Sync(ctx context.Context) error {
+	now := s.now()
+
+	s.mu.RLock()
+	since := s.synced
+	s.mu.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-syncOverlap)
+	}
+
+	revocations, err := s.repo.RevocationsSince(ctx, since, now)
+	if err != nil {
+		return err
+	}
+	for _, r := range revocations {
+		s.add(r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.synced = now
+	for jti, exp := range s.tokens {
+		if !exp.After(now) {
+			delete(s.tokens, jti)
+		}
+	}
+	for sid, exp := range s.sessions {
+		if !exp.After(now) {
+			delete(s.sessions, sid)
+		}
+	}
+	for id, r := range s.users {
+		if !r.ExpiresAt.After(now) {
+			delete(s.users, id)
+		}
+	}
+	return nil
+}
+
+// Run syncs every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+				log.Println("Couldn't sync token revocations:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package revocation
+
+import (
+	"context"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/token"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ── In-memory RevocationRepository ──────────────────────────────────────────
+
+type memRepo struct {
+	docs map[string]models.Revocation
+}
+
+func newMemRepo() *memRepo { return &memRepo{docs: map[string]models.Revocation{}} }
+
+func (m *memRepo) SaveRevocation(_ context.Context, r models.Revocation) error {
+	m.docs[r.ID] = r
+	return nil
+}
+
+func (m *memRepo) RevocationsSince(_ context.Context, since, now time.Time) ([]models.Revocation, error) {
+	var out []models.Revocation
+	for _, r := range m.docs {
+		if !r.RevokedAt.Before(since) && r.ExpiresAt.After(now) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+var ctx = context.Background()
+
+func claims(jti, sub string, issued time.Time) *token.Claims {
+	return &token.Claims{RegisteredClaims: jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   sub,
+		IssuedAt:  jwt.NewNumericDate(issued),
+		ExpiresAt: jwt.NewNumericDate(issued.Add(15 * time.Minute)),
+	}}
+}
+
+// ── Tests ────────────────────────────────────────────────────────────────────
+
+func TestRevokeToken_OnlyThatToken(t *testing.T) {
+	s := NewStore(newMemRepo())
+	now := time.Now()
+
+	if err := s.RevokeToken(ctx, claims("a", "u1", now)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.IsRevoked(claims("a", "u1", now)) {
+		t.Errorf("expected the revoked token to be rejected")
+	}
+	if s.IsRevoked(claims("b", "u1", now)) {
+		t.Errorf("expected the user's other tokens to stay valid")
+	}
+}
+
+func TestRevokeUser_CutsOffEarlierTokens(t *testing.T) {
+	s := NewStore(newMemRepo())
+	revokedAt := time.Now().Truncate(time.Second)
+	s.now = func() time.Time { return revokedAt }
+
+	s.RevokeUser(ctx, "u1", 15*time.Minute)
+
+	if !s.IsRevoked(claims("a", "u1", revokedAt.Add(-time.Minute))) {
+		t.Errorf("expected an earlier token to be revoked")
+	}
+	if s.IsRevoked(claims("b", "u1", revokedAt.Add(time.Second))) {
+		t.Errorf("expected a token issued after the revocation to be valid")
+	}
+	if s.IsRevoked(claims("c", "u2", revokedAt.Add(-time.Minute))) {
+		t.Errorf("expected other users to be unaffected")
+	}
+}
+
+func TestRevokeUser_KeepsTokensFromTheSameSecond(t *testing.T) {
+	s := NewStore(newMemRepo())
+	revokedAt := time.Now().Truncate(time.Second).Add(600 * time.Millisecond)
+	s.now = func() time.Time { return revokedAt }
+
+	s.RevokeUser(ctx, "u1", 15*time.Minute)
+
+	if s.IsRevoked(claims("a", "u1", revokedAt)) {
+		t.Errorf("expected a token issued in the second of the revocation to be valid")
+	}
+	if !s.IsRevoked(claims("b", "u1", revokedAt.Add(-time.Second))) {
+		t.Errorf("expected a token from the second before to be revoked")
+	}
+}
+
+func TestRevokeUser_TokenIssuedAtRevokedAtStaysValid(t *testing.T) {
+	s := NewStore(newMemRepo())
+	revokedAt := time.Now().Truncate(time.Second)
+	s.now = func() time.Time { return revokedAt }
+
+	s.RevokeUser(ctx, "u1", 15*time.Minute)
+
+	if r := s.users["u1"]; !r.RevokedAt.Equal(revokedAt) {
+		t.Fatalf("expected the revocation at %v, got %v", revokedAt, r.RevokedAt)
+	}
+	if s.IsRevoked(claims("a", "u1", revokedAt)) {
+		t.Errorf("expected a token issued at RevokedAt to be valid")
+	}
+	if !s.IsRevoked(claims("b", "u1", revokedAt.Add(-time.Second))) {
+		t.Errorf("expected a token issued a second before RevokedAt to be revoked")
+	}
+}
+
+func TestRevokeSession_OnlyThatSession(t *testing.T) {
+	s := NewStore(newMemRepo())
+	now := time.Now()
+	inSession := func(jti, sid string) *token.Claims {
+		c := claims(jti, "u1", now)
+		c.SessionID = sid
+		return c
+	}
+
+	if err := s.RevokeSession(ctx, "u1", "laptop", 15*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.IsRevoked(inSession("a", "laptop")) || !s.IsRevoked(inSession("b", "laptop")) {
+		t.Errorf("expected every token of the session to be revoked")
+	}
+	if s.IsRevoked(inSession("c", "phone")) || s.IsRevoked(claims("d", "u1", now)) {
+		t.Errorf("expected the user's other sessions to stay valid")
+	}
+}
+
+func TestSync_PicksUpRevocationsFromOtherReplicas(t *testing.T) {
+	repo := newMemRepo()
+	a, b := NewStore(repo), NewStore(repo)
+	b.Sync(ctx)
+	now := time.Now()
+
+	a.RevokeToken(ctx, claims("a", "u1", now))
+	if b.IsRevoked(claims("a", "u1", now)) {
+		t.Fatalf("expected replica b not to know before syncing")
+	}
+	if err := b.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !b.IsRevoked(claims("a", "u1", now)) {
+		t.Errorf("expected replica b to learn the revocation on sync")
+	}
+}
+
+func TestSync_ForgetsExpiredRevocations(t *testing.T) {
+	s := NewStore(newMemRepo())
+	issued := time.Now()
+	s.RevokeToken(ctx, claims("a", "u1", issued))
+	s.RevokeUser(ctx, "u1", time.Minute)
+	s.RevokeSession(ctx, "u1", "laptop", time.Minute)
+
+	s.now = func() time.Time { return issued.Add(time.Hour) }
+	s.Sync(ctx)
+
+	if len(s.tokens) != 0 || len(s.users) != 0 || len(s.sessions) != 0 {
+		t.Errorf("expected expired revocations to be dropped, got %v %v %v", s.tokens, s.users, s.sessions)
+	}
+}
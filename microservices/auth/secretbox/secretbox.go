@@ -0,0 +1,58 @@
+// Package secretbox encrypts the secrets the service keeps in Mongo with
+// AES-GCM, under a key derived from the service secret.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var ErrTruncated = errors.New("sealed value is truncated")
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New derives a key from secret for one purpose, e.g. "auth signing keys".
+// Boxes for different purposes can't open each other's values.
+func New(secret []byte, purpose string) (*Box, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte(purpose)), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext and binds it to aad, typically the ID of the
+// document it is stored in. The nonce is prepended to the result.
+func (b *Box) Seal(plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open decrypts a value from Seal. It fails if the value, the aad or the
+// service secret changed.
+func (b *Box) Open(sealed, aad []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrTruncated
+	}
+	return b.aead.Open(nil, sealed[:n], sealed[n:], aad)
+}
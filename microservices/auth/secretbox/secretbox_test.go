@@ -0,0 +1,46 @@
+package secretbox
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newBox(t *testing.T, secret, purpose string) *Box {
+	t.Helper()
+	b, err := New([]byte(secret), purpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestSealOpen_RoundTrip(t *testing.T) {
+	b := newBox(t, "secret", "test")
+
+	sealed, err := b.Seal([]byte("hello"), []byte("id-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := b.Open(sealed, []byte("id-1"))
+
+	if err != nil || !bytes.Equal(plain, []byte("hello")) {
+		t.Errorf("expected the plaintext back, got %q, %v", plain, err)
+	}
+}
+
+func TestOpen_RejectsTampering(t *testing.T) {
+	b := newBox(t, "secret", "test")
+	sealed, _ := b.Seal([]byte("hello"), []byte("id-1"))
+
+	cases := map[string]func() ([]byte, error){
+		"other aad":     func() ([]byte, error) { return b.Open(sealed, []byte("id-2")) },
+		"other secret":  func() ([]byte, error) { return newBox(t, "changed", "test").Open(sealed, []byte("id-1")) },
+		"other purpose": func() ([]byte, error) { return newBox(t, "secret", "other").Open(sealed, []byte("id-1")) },
+		"truncated":     func() ([]byte, error) { return b.Open(sealed[:4], []byte("id-1")) },
+	}
+	for name, open := range cases {
+		if _, err := open(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
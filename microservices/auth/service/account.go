@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/outbox"
+	"iLeon/microservices/auth/rbac"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/auth/validate"
+	"iLeon/microservices/natsrpc"
+	"log"
+)
+
+func errInvalidEmailChangeToken() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, "Invalid or expired email change token")
+}
+
+func errEmailInUse() *natsrpc.Error {
+	return natsrpc.Conflict("This email is already in use").
+		WithDetails(map[string]string{"field": "email"})
+}
+
+// GetMe returns the access token's user.
+func (s *Service) GetMe(ctx context.Context, accessToken string) (*models.Profile, error) {
+	_, user, err := s.currentUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return profile(user), nil
+}
+
+// UpdateProfile changes the username and display name, whichever are set.
+func (s *Service) UpdateProfile(ctx context.Context, accessToken string, body models.UpdateProfileBody) (*models.Profile, error) {
+	var errs validate.Errors
+	if body.Username == nil && body.DisplayName == nil {
+		errs.Add("username", "Set username or display_name")
+	}
+	if body.Username != nil {
+		username, err := validate.Username(*body.Username)
+		errs.Check("username", err)
+		body.Username = &username
+	}
+	if body.DisplayName != nil {
+		name, err := validate.DisplayName(*body.DisplayName)
+		errs.Check("display_name", err)
+		body.DisplayName = &name
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	_, userID, err := s.authenticate(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repository.UpdateProfile(ctx, userID, body.Username, body.DisplayName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidAccessToken()
+	}
+	if err != nil {
+		return nil, errUpdateUser(err)
+	}
+	return profile(user), nil
+}
+
+// ChangeEmail mails a confirmation token to the new address. The account
+// keeps its current email until ConfirmEmailChange spends the token, so a
+// typo can't lock the user out.
+func (s *Service) ChangeEmail(ctx context.Context, accessToken string, body models.ChangeEmailBody) (*models.CustomeResponse, error) {
+	var errs validate.Errors
+	email, err := validate.Email(body.Email)
+	errs.Check("email", err)
+	if body.Password == "" {
+		errs.Add("password", "Password is required")
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	_, user, err := s.currentUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.confirmPassword(ctx, user, body.Password); err != nil {
+		return nil, err
+	}
+	if email == user.Email {
+		return nil, natsrpc.Validation("This is already your email").
+			WithDetails(map[string]string{"field": "email"})
+	}
+	if _, err := s.repository.FindUserByEmail(ctx, email); err == nil {
+		return nil, errEmailInUse()
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, errReadUser(err)
+	}
+
+	if err := s.repository.SetPendingEmail(ctx, user.ID, email); err != nil {
+		return nil, errUpdateUser(err)
+	}
+	if err := s.mailToken(ctx, user, email, models.PurposeEmailChange, s.verifyTTL, models.EventEmailChangeRequested); err != nil {
+		return nil, err
+	}
+	return &models.CustomeResponse{Msg: "A confirmation link has been sent to " + email, Context: true}, nil
+}
+
+// ConfirmEmailChange spends an email change token and moves the account to
+// the new, now verified, address.
+func (s *Service) ConfirmEmailChange(ctx context.Context, body models.ConfirmEmailChangeBody) (*models.CustomeResponse, error) {
+	user, err := s.spendToken(ctx, body.Token, models.PurposeEmailChange, errInvalidEmailChangeToken)
+	if err != nil {
+		return nil, err
+	}
+	if user.PendingEmail == "" {
+		return nil, errInvalidEmailChangeToken()
+	}
+
+	// Another user may have taken the email since it was requested.
+	err = s.repository.ConfirmEmail(ctx, user.ID, user.PendingEmail)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidEmailChangeToken()
+	}
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, errEmailInUse()
+	}
+	if err != nil {
+		return nil, errUpdateUser(err)
+	}
+	return &models.CustomeResponse{Msg: "Your email address is now " + user.PendingEmail, Context: true}, nil
+}
+
+// ChangePassword sets a new password after checking the current one, and
+// signs out every other session; the caller's session stays signed in.
+func (s *Service) ChangePassword(ctx context.Context, accessToken string, body models.ChangePasswordBody) (*models.CustomeResponse, error) {
+	var errs validate.Errors
+	if body.CurrentPassword == "" {
+		errs.Add("current_password", "Current password is required")
+	}
+	errs.Check("new_password", s.policy.Check(body.NewPassword))
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	claims, user, err := s.currentUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.confirmPassword(ctx, user, body.CurrentPassword); err != nil {
+		return nil, err
+	}
+
+	hash, err := s.hashPassword(body.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return nil, errUpdateUser(err)
+	}
+
+	now := s.now()
+	tokens, err := s.sessions.ListActive(ctx, user.ID, now)
+	if err != nil {
+		return nil, errReadSession(err)
+	}
+	signedOut := map[string]bool{}
+	for _, t := range tokens {
+		if t.FamilyID == claims.SessionID || signedOut[t.FamilyID] {
+			continue
+		}
+		if err := s.sessions.RevokeFamily(ctx, t.FamilyID, now); err != nil {
+			return nil, errRevoke(err)
+		}
+		if err := s.revocations.RevokeSession(ctx, claims.Subject, t.FamilyID, s.tokens.AccessTTL()); err != nil {
+			return nil, errRevoke(err)
+		}
+		signedOut[t.FamilyID] = true
+	}
+	if err := s.oneTime.InvalidateOneTimeTokens(ctx, user.ID, models.PurposePasswordReset, now); err != nil {
+		log.Printf("couldn't invalidate the password reset tokens of %s: %v", user.ID.Hex(), err)
+	}
+
+	return &models.CustomeResponse{
+		Msg:     fmt.Sprintf("Password changed, %d other sessions were signed out", len(signedOut)),
+		Context: true,
+	}, nil
+}
+
+// DeleteAccount deletes the access token's user after checking the
+// password, and the second factor if MFA is enabled. Every session is
+// revoked and an auth.userDeleted event tells other services.
+func (s *Service) DeleteAccount(ctx context.Context, accessToken string, body models.DeleteAccountBody) (*models.CustomeResponse, error) {
+	var errs validate.Errors
+	if body.Password == "" {
+		errs.Add("password", "Password is required")
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	_, user, err := s.currentUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.confirmPassword(ctx, user, body.Password); err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		ok, err := s.checkSecondFactor(ctx, user, body.Code)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errInvalidMFACode()
+		}
+	}
+
+	if err := s.removeUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return &models.CustomeResponse{Msg: "Your account has been deleted", Context: true}, nil
+}
+
+// removeUser deletes the user, signs them out everywhere and tells other
+// services with the auth.userDeleted event.
+func (s *Service) removeUser(ctx context.Context, user *models.User) error {
+	if err := s.repository.DeleteUser(ctx, user.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return errUpdateUser(err)
+	}
+	if err := s.signOutEverywhere(ctx, user.ID); err != nil {
+		return err
+	}
+
+	// The account is gone either way; a lost event only leaves other
+	// services holding stale data.
+	now := s.now()
+	e, err := outbox.NewEvent(models.EventUserDeleted, models.UserDeleted{
+		UserID:    user.ID.Hex(),
+		Email:     user.Email,
+		DeletedAt: now,
+	}, now)
+	if err == nil {
+		err = s.outbox.EnqueueEvent(ctx, e)
+	}
+	if err != nil {
+		log.Printf("couldn't queue the deletion event for %s: %v", user.ID.Hex(), err)
+	}
+	return nil
+}
+
+// confirmPassword checks the password of a signed-in user before a
+// sensitive change. Wrong passwords count as failed logins, so a stolen
+// access token can't be used to guess it.
+func (s *Service) confirmPassword(ctx context.Context, user *models.User, password string) error {
+	ip := natsrpc.ClientIP(ctx)
+	wait, err := s.lockout.Locked(ctx, user.Email, ip)
+	if err != nil {
+		return errLockout(err)
+	}
+	if wait > 0 {
+		return errAccountLocked(wait)
+	}
+	ok, err := s.checkPassword(user, password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.loginFailed(ctx, user.Email, ip, "Password is incorrect")
+	}
+	return nil
+}
+
+// currentUser loads the access token's user.
+func (s *Service) currentUser(ctx context.Context, accessToken string) (*token.Claims, *models.User, error) {
+	claims, userID, err := s.authenticate(accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.repository.FindUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, errInvalidAccessToken()
+	}
+	if err != nil {
+		return nil, nil, errReadUser(err)
+	}
+	return claims, user, nil
+}
+
+func profile(user *models.User) *models.Profile {
+	return &models.Profile{
+		ID:            user.ID.Hex(),
+		Username:      user.Username,
+		DisplayName:   user.DisplayName,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		PendingEmail:  user.PendingEmail,
+		Roles:         rbac.Roles(user.Roles),
+		Permissions:   rbac.Permissions(user.Roles, user.Permissions),
+		MFAEnabled:    user.MFAEnabled(),
+	}
+}
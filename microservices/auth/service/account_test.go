@@ -0,0 +1,204 @@
+package service_test
+
+import (
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/natsrpc"
+	"reflect"
+	"testing"
+)
+
+// ── GetMe and UpdateProfile tests ────────────────────────────────────────────
+
+func TestGetMe_ReturnsTheCallersProfile(t *testing.T) {
+	f := newSessionFixture(t)
+	f.user.DisplayName = "Ada"
+
+	me, err := f.svc.GetMe(ctx, f.login(t, "laptop").Msg)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if me.ID != f.user.ID.Hex() || me.Email != f.user.Email || me.DisplayName != "Ada" || me.MFAEnabled {
+		t.Errorf("unexpected profile %+v", me)
+	}
+	if _, err := f.svc.GetMe(ctx, ""); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected UNAUTHENTICATED without a token, got %v", err)
+	}
+}
+
+func TestUpdateProfile_ChangesOnlyTheGivenFields(t *testing.T) {
+	f := newSessionFixture(t)
+	access := f.login(t, "laptop").Msg
+	name := "  Ada Lovelace "
+
+	me, err := f.svc.UpdateProfile(ctx, access, models.UpdateProfileBody{DisplayName: &name})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if me.DisplayName != "Ada Lovelace" || me.Username != "user" {
+		t.Errorf("unexpected profile %+v", me)
+	}
+
+	bad := "a b"
+	_, err = f.svc.UpdateProfile(ctx, access, models.UpdateProfileBody{Username: &bad})
+	if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, []string{"username"}) {
+		t.Errorf("expected the username to be refused, got %v", fields)
+	}
+}
+
+// ── ChangeEmail tests ────────────────────────────────────────────────────────
+
+func TestChangeEmail_SwitchesOnceConfirmed(t *testing.T) {
+	f := newSessionFixture(t)
+	access := f.login(t, "laptop").Msg
+
+	if _, err := f.svc.ChangeEmail(ctx, access, models.ChangeEmailBody{Email: "new@test.com", Password: "wrong"}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Fatalf("expected a wrong password to be refused, got %v", err)
+	}
+	if _, err := f.svc.ChangeEmail(ctx, access, models.ChangeEmailBody{Email: "New@Test.com", Password: "pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.user.Email != "user@test.com" || f.user.PendingEmail != "new@test.com" {
+		t.Fatalf("expected the email to wait for confirmation, got %q / %q", f.user.Email, f.user.PendingEmail)
+	}
+	mails := f.mailed(t, models.EventEmailChangeRequested)
+	if len(mails) != 1 || mails[0].Email != "new@test.com" {
+		t.Fatalf("expected a confirmation mail to the new address, got %+v", mails)
+	}
+
+	if _, err := f.svc.ConfirmEmailChange(ctx, models.ConfirmEmailChangeBody{Token: mails[0].Token}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if f.user.Email != "new@test.com" || !f.user.EmailVerified || f.user.PendingEmail != "" {
+		t.Errorf("expected the verified new email, got %+v", f.user)
+	}
+	if _, err := f.svc.ConfirmEmailChange(ctx, models.ConfirmEmailChangeBody{Token: mails[0].Token}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the token to be single-use, got %v", err)
+	}
+}
+
+func TestChangeEmail_RefusesAnEmailInUse(t *testing.T) {
+	user, other := newUser(t, "user@test.com", "pass"), newUser(t, "other@test.com", "pass")
+	svc := newService(usersRepo(user, other))
+	login, err := svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.ChangeEmail(ctx, login.Msg, models.ChangeEmailBody{Email: other.Email, Password: "pass"})
+
+	if rpcCode(err) != natsrpc.CodeConflict {
+		t.Errorf("expected CONFLICT, got %v", err)
+	}
+}
+
+func TestConfirmEmailChange_RefusesEmailTakenMeanwhile(t *testing.T) {
+	f := newSessionFixture(t)
+	access := f.login(t, "laptop").Msg
+	if _, err := f.svc.ChangeEmail(ctx, access, models.ChangeEmailBody{Email: "new@test.com", Password: "pass"}); err != nil {
+		t.Fatal(err)
+	}
+	f.users.users = append(f.users.users, newUser(t, "new@test.com", "pass"))
+
+	mails := f.mailed(t, models.EventEmailChangeRequested)
+	_, err := f.svc.ConfirmEmailChange(ctx, models.ConfirmEmailChangeBody{Token: mails[0].Token})
+
+	if rpcCode(err) != natsrpc.CodeConflict {
+		t.Errorf("expected CONFLICT, got %v", err)
+	}
+	if f.user.Email != "user@test.com" {
+		t.Errorf("expected the email to stay, got %q", f.user.Email)
+	}
+}
+
+// ── ChangePassword tests ─────────────────────────────────────────────────────
+
+func TestChangePassword_SignsOutOtherSessions(t *testing.T) {
+	f := newSessionFixture(t)
+	laptop, phone := f.login(t, "laptop"), f.login(t, "phone")
+
+	res, err := f.svc.ChangePassword(ctx, laptop.Msg, models.ChangePasswordBody{CurrentPassword: "pass", NewPassword: "correct horse"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Msg != "Password changed, 1 other sessions were signed out" {
+		t.Errorf("unexpected message %q", res.Msg)
+	}
+	if !f.active(t, laptop.Msg) {
+		t.Errorf("expected the caller's access token to stay valid")
+	}
+	if _, err := f.refresh(laptop.RefreshToken); err != nil {
+		t.Errorf("expected the caller's session to keep refreshing, got %v", err)
+	}
+	if f.active(t, phone.Msg) {
+		t.Errorf("expected the other session's access token to be revoked")
+	}
+	if _, err := f.refresh(phone.RefreshToken); err == nil {
+		t.Errorf("expected the other session's refresh token to be revoked")
+	}
+	if _, err := f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "correct horse"}); err != nil {
+		t.Errorf("expected the new password to work, got %v", err)
+	}
+}
+
+func TestChangePassword_ChecksBothPasswords(t *testing.T) {
+	f := newSessionFixture(t)
+	access := f.login(t, "laptop").Msg
+
+	_, err := f.svc.ChangePassword(ctx, access, models.ChangePasswordBody{NewPassword: "short"})
+	if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, []string{"current_password", "new_password"}) {
+		t.Errorf("expected both fields to be reported, got %v", fields)
+	}
+	_, err = f.svc.ChangePassword(ctx, access, models.ChangePasswordBody{CurrentPassword: "wrong", NewPassword: "correct horse"})
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected a wrong current password to be refused, got %v", err)
+	}
+}
+
+// ── DeleteAccount tests ──────────────────────────────────────────────────────
+
+func TestDeleteAccount_RemovesUserAndSignsOut(t *testing.T) {
+	f := newSessionFixture(t)
+	session := f.login(t, "laptop")
+
+	if _, err := f.svc.DeleteAccount(ctx, session.Msg, models.DeleteAccountBody{Password: "wrong"}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Fatalf("expected a wrong password to be refused, got %v", err)
+	}
+	if _, err := f.svc.DeleteAccount(ctx, session.Msg, models.DeleteAccountBody{Password: "pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if f.active(t, session.Msg) {
+		t.Errorf("expected the access token to be revoked")
+	}
+	if _, err := f.refresh(session.RefreshToken); err == nil {
+		t.Errorf("expected the refresh token to be revoked")
+	}
+	if _, err := f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "pass"}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the user to be gone, got %v", err)
+	}
+	var deleted int
+	for _, e := range f.outbox.events {
+		if e.Type == models.EventUserDeleted {
+			deleted++
+		}
+	}
+	if deleted != 1 {
+		t.Errorf("expected one %s event, got %d", models.EventUserDeleted, deleted)
+	}
+}
+
+func TestDeleteAccount_RequiresSecondFactorWithMFA(t *testing.T) {
+	f := newMFAFixture(t)
+	access, codes := f.enable(t)
+
+	if _, err := f.svc.DeleteAccount(ctx, access, models.DeleteAccountBody{Password: "pass"}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Fatalf("expected the missing code to be refused, got %v", err)
+	}
+	if _, err := f.svc.DeleteAccount(ctx, access, models.DeleteAccountBody{Password: "pass", Code: codes[0]}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
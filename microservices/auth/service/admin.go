@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/rbac"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/validate"
+	"iLeon/microservices/natsrpc"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultUserPageLimit = 20
+	MaxUserPageLimit     = 100
+
+	DefaultAuditLimit = 50
+	MaxAuditLimit     = 500
+)
+
+func errAccountDisabled() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeForbidden, "This account has been disabled").
+		WithDetails(map[string]string{"reason": "account_disabled"})
+}
+
+func errPasswordResetRequired() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeForbidden, "Please reset your password before logging in").
+		WithDetails(map[string]string{"reason": "password_reset_required"})
+}
+
+// loginRefused returns why an admin keeps the user from logging in, or nil.
+// It is checked after the password, so it doesn't tell strangers which
+// accounts are disabled.
+func loginRefused(user *models.User) error {
+	switch {
+	case user.Disabled():
+		return errAccountDisabled()
+	case user.MustResetPassword:
+		return errPasswordResetRequired()
+	}
+	return nil
+}
+
+// ListUsers pages through the users, oldest first.
+func (s *Service) ListUsers(ctx context.Context, query models.UserQuery) (*models.UserPage, error) {
+	var errs validate.Errors
+	if query.Page < 0 {
+		errs.Add("page", "Page must not be negative")
+	}
+	if query.Limit < 0 {
+		errs.Add("limit", "Limit must not be negative")
+	}
+	switch query.Status {
+	case "", models.StatusActive, models.StatusDisabled:
+	default:
+		errs.Add("status", "Status must be active or disabled")
+	}
+	if query.Role != "" && !rbac.ValidRole(query.Role) {
+		errs.Add("role", "Unknown role "+query.Role)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	if query.Limit == 0 {
+		query.Limit = DefaultUserPageLimit
+	}
+	query.Limit = min(query.Limit, MaxUserPageLimit)
+	query.Page = max(query.Page, 1)
+	query.Search = strings.TrimSpace(query.Search)
+
+	users, total, err := s.repository.ListUsers(ctx, query)
+	if err != nil {
+		return nil, errReadUser(err)
+	}
+
+	page := &models.UserPage{
+		Data:  make([]models.AdminUser, 0, len(users)),
+		Total: total,
+		Page:  query.Page,
+		Limit: query.Limit,
+		Pages: (total + query.Limit - 1) / query.Limit,
+	}
+	for i := range users {
+		page.Data = append(page.Data, *adminUser(&users[i]))
+	}
+	return page, nil
+}
+
+func (s *Service) GetUser(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error) {
+	id, err := parseUserID(body.UserID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return adminUser(user), nil
+}
+
+// DisableUser stops the user from logging in and signs them out everywhere.
+func (s *Service) DisableUser(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error) {
+	id, err := s.parseOtherUserID(ctx, body.UserID, "disable")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repository.SetStatus(ctx, id, models.StatusDisabled)
+	if err != nil {
+		return nil, errUpdateUser(err)
+	}
+	if err := s.signOutEverywhere(ctx, id); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, models.AuditUserDisabled, id.Hex(), nil)
+	return adminUser(user), nil
+}
+
+func (s *Service) EnableUser(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error) {
+	id, err := parseUserID(body.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repository.SetStatus(ctx, id, models.StatusActive)
+	if err != nil {
+		return nil, errUpdateUser(err)
+	}
+	s.audit(ctx, models.AuditUserEnabled, id.Hex(), nil)
+	return adminUser(user), nil
+}
+
+// ForcePasswordReset signs the user out everywhere and refuses their logins
+// until they set a new password, for which a reset link is mailed.
+func (s *Service) ForcePasswordReset(ctx context.Context, body models.UserIDBody) (*models.CustomeResponse, error) {
+	id, err := parseUserID(body.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repository.SetMustResetPassword(ctx, id, true)
+	if err != nil {
+		return nil, errUpdateUser(err)
+	}
+	if err := s.signOutEverywhere(ctx, id); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, models.AuditPasswordResetForced, id.Hex(), nil)
+
+	if err := s.mailToken(ctx, user, user.Email, models.PurposePasswordReset, s.resetTTL, models.EventPasswordResetRequested); err != nil {
+		return nil, err
+	}
+	return &models.CustomeResponse{Msg: "A password reset link has been sent to " + user.Email, Context: true}, nil
+}
+
+// DeleteUser deletes the user the way DeleteAccount does, without their
+// password.
+func (s *Service) DeleteUser(ctx context.Context, body models.UserIDBody) (*models.CustomeResponse, error) {
+	id, err := s.parseOtherUserID(ctx, body.UserID, "delete")
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.findUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.removeUser(ctx, user); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, models.AuditUserDeleted, id.Hex(), map[string]string{"email": user.Email})
+	return &models.CustomeResponse{Msg: "Deleted user " + id.Hex(), Context: true}, nil
+}
+
+// AuditLog lists the latest admin actions, newest first.
+func (s *Service) AuditLog(ctx context.Context, query models.AuditQuery) ([]models.AuditRecord, error) {
+	if query.Limit < 0 {
+		return nil, natsrpc.Validation("Limit must not be negative")
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultAuditLimit
+	}
+	query.Limit = min(query.Limit, MaxAuditLimit)
+
+	records := []models.AuditRecord{}
+	if s.auditLog == nil {
+		return records, nil
+	}
+	entries, err := s.auditLog.ListAudit(ctx, strings.TrimSpace(query.UserID), query.Limit)
+	if err != nil {
+		return nil, natsrpc.NewError(natsrpc.CodeUnavailable, "Couldn't read the audit log").WithRetryable(true).Wrap(err)
+	}
+	for _, e := range entries {
+		records = append(records, models.AuditRecord{
+			ID:       e.ID.Hex(),
+			ActorID:  e.ActorID,
+			Action:   e.Action,
+			TargetID: e.TargetID,
+			Details:  e.Details,
+			At:       e.At,
+		})
+	}
+	return records, nil
+}
+
+// audit records an admin action by the caller. The action has happened by
+// then, so a failure to record it is only logged.
+func (s *Service) audit(ctx context.Context, action, targetID string, details map[string]string) {
+	if s.auditLog == nil {
+		return
+	}
+	var actor string
+	if p := natsrpc.PrincipalFromContext(ctx); p != nil {
+		actor = p.Subject
+	}
+	e := &models.AuditEntry{
+		ActorID:  actor,
+		Action:   action,
+		TargetID: targetID,
+		Details:  details,
+		At:       s.now(),
+	}
+	if err := s.auditLog.RecordAudit(ctx, e); err != nil {
+		log.Printf("couldn't record %s of %s by %s in the audit log: %v", action, targetID, actor, err)
+	}
+}
+
+// parseOtherUserID parses a user ID and refuses the caller's own, so admins
+// can't lock themselves out.
+func (s *Service) parseOtherUserID(ctx context.Context, userID, action string) (primitive.ObjectID, error) {
+	id, err := parseUserID(userID)
+	if err != nil {
+		return id, err
+	}
+	if p := natsrpc.PrincipalFromContext(ctx); p != nil && p.Subject == id.Hex() {
+		return id, natsrpc.NewError(natsrpc.CodeForbidden, "You can't "+action+" your own account")
+	}
+	return id, nil
+}
+
+func parseUserID(userID string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return id, natsrpc.Validation("user_id must be a user ID")
+	}
+	return id, nil
+}
+
+func (s *Service) findUser(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	user, err := s.repository.FindUserByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, natsrpc.NotFound("User not found")
+	}
+	if err != nil {
+		return nil, errReadUser(err)
+	}
+	return user, nil
+}
+
+func adminUser(user *models.User) *models.AdminUser {
+	status := user.Status
+	if status == "" {
+		status = models.StatusActive
+	}
+	return &models.AdminUser{
+		Profile:           *profile(user),
+		Status:            status,
+		MustResetPassword: user.MustResetPassword,
+	}
+}
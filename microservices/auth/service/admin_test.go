@@ -0,0 +1,281 @@
+package service_test
+
+import (
+	"context"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/natsrpc"
+	"reflect"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ── In-memory AuditRepository ───────────────────────────────────────────────
+
+type memAudit struct {
+	entries []models.AuditEntry
+}
+
+func (m *memAudit) RecordAudit(_ context.Context, e *models.AuditEntry) error {
+	e.ID = primitive.NewObjectID()
+	m.entries = append(m.entries, *e)
+	return nil
+}
+
+func (m *memAudit) ListAudit(_ context.Context, targetID string, limit int) ([]models.AuditEntry, error) {
+	var out []models.AuditEntry
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		e := m.entries[i]
+		if (targetID == "" || e.TargetID == targetID) && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+// adminID is the operator the admin tests act as.
+var adminID = primitive.NewObjectID()
+
+var asAdmin = natsrpc.WithPrincipal(ctx, &natsrpc.Principal{Subject: adminID.Hex()})
+
+func (f *sessionFixture) target() models.UserIDBody {
+	return models.UserIDBody{UserID: f.user.ID.Hex()}
+}
+
+func (f *sessionFixture) actions() []string {
+	var actions []string
+	for _, e := range f.audit.entries {
+		actions = append(actions, e.Action)
+	}
+	return actions
+}
+
+func reason(err error) string {
+	rpcErr, _ := err.(*natsrpc.Error)
+	if rpcErr == nil {
+		return ""
+	}
+	details, _ := rpcErr.Details.(map[string]string)
+	return details["reason"]
+}
+
+// ── ListUsers and GetUser tests ──────────────────────────────────────────────
+
+func TestListUsers_SearchesAndFilters(t *testing.T) {
+	f := newSessionFixture(t)
+	alice := newUser(t, "alice@test.com", "pass")
+	alice.Username, alice.Roles = "alice", []string{"admin"}
+	bob := newUser(t, "bob@example.com", "pass")
+	bob.Username, bob.Status = "bob", models.StatusDisabled
+	f.users.users = append(f.users.users, alice, bob)
+
+	cases := []struct {
+		query models.UserQuery
+		want  []string
+	}{
+		{models.UserQuery{}, []string{"user@test.com", "alice@test.com", "bob@example.com"}},
+		{models.UserQuery{Search: " TEST.com "}, []string{"user@test.com", "alice@test.com"}},
+		{models.UserQuery{Search: "bob"}, []string{"bob@example.com"}},
+		{models.UserQuery{Status: models.StatusActive}, []string{"user@test.com", "alice@test.com"}},
+		{models.UserQuery{Status: models.StatusDisabled}, []string{"bob@example.com"}},
+		{models.UserQuery{Role: "admin"}, []string{"alice@test.com"}},
+		{models.UserQuery{Page: 2, Limit: 2}, []string{"bob@example.com"}},
+	}
+	for _, c := range cases {
+		page, err := f.svc.ListUsers(asAdmin, c.query)
+		if err != nil {
+			t.Fatalf("%+v: unexpected error: %v", c.query, err)
+		}
+		var emails []string
+		for _, u := range page.Data {
+			emails = append(emails, u.Email)
+		}
+		if !slices.Equal(emails, c.want) {
+			t.Errorf("%+v: expected %v, got %v", c.query, c.want, emails)
+		}
+	}
+}
+
+func TestListUsers_PagingDefaultsAndLimits(t *testing.T) {
+	f := newSessionFixture(t)
+
+	page, err := f.svc.ListUsers(asAdmin, models.UserQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.Page != 1 || page.Limit != 20 || page.Total != 1 || page.Pages != 1 {
+		t.Errorf("unexpected paging %+v", page)
+	}
+	if page.Data[0].Status != models.StatusActive {
+		t.Errorf("expected users without a status to be active, got %q", page.Data[0].Status)
+	}
+
+	page, _ = f.svc.ListUsers(asAdmin, models.UserQuery{Limit: 1000})
+	if page.Limit != 100 {
+		t.Errorf("expected the limit to be capped at 100, got %d", page.Limit)
+	}
+
+	_, err = f.svc.ListUsers(asAdmin, models.UserQuery{Page: -1, Status: "gone", Role: "root"})
+	if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, []string{"page", "status", "role"}) {
+		t.Errorf("expected page, status and role to be refused, got %v", fields)
+	}
+}
+
+func TestGetUser_ReturnsUserOrNotFound(t *testing.T) {
+	f := newSessionFixture(t)
+
+	user, err := f.svc.GetUser(asAdmin, f.target())
+	if err != nil || user.Email != f.user.Email {
+		t.Fatalf("expected the user, got %+v, %v", user, err)
+	}
+	if _, err := f.svc.GetUser(asAdmin, models.UserIDBody{UserID: primitive.NewObjectID().Hex()}); rpcCode(err) != natsrpc.CodeNotFound {
+		t.Errorf("expected NOT_FOUND, got %v", err)
+	}
+	if _, err := f.svc.GetUser(asAdmin, models.UserIDBody{UserID: "nope"}); rpcCode(err) != natsrpc.CodeValidation {
+		t.Errorf("expected VALIDATION for a bad ID, got %v", err)
+	}
+}
+
+// ── DisableUser / EnableUser tests ───────────────────────────────────────────
+
+func TestDisableUser_SignsOutAndRefusesLogins(t *testing.T) {
+	f := newSessionFixture(t)
+	pair := f.login(t, "laptop")
+
+	res, err := f.svc.DisableUser(asAdmin, f.target())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Status != models.StatusDisabled {
+		t.Errorf("expected the disabled user, got %+v", res)
+	}
+	if f.active(t, pair.Msg) {
+		t.Errorf("expected the access token to be revoked")
+	}
+	if _, err := f.refresh(pair.RefreshToken); err == nil {
+		t.Errorf("expected the refresh token to be revoked")
+	}
+	_, err = f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "pass"})
+	if rpcCode(err) != natsrpc.CodeForbidden || reason(err) != "account_disabled" {
+		t.Errorf("expected FORBIDDEN account_disabled, got %v", err)
+	}
+	_, err = f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "wrong"})
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected a wrong password not to reveal the status, got %v", err)
+	}
+
+	if _, err := f.svc.EnableUser(asAdmin, f.target()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.login(t, "laptop")
+
+	if got := f.actions(); !slices.Equal(got, []string{models.AuditUserDisabled, models.AuditUserEnabled}) {
+		t.Errorf("unexpected audit trail %v", got)
+	}
+	if e := f.audit.entries[0]; e.ActorID != adminID.Hex() || e.TargetID != f.user.ID.Hex() {
+		t.Errorf("unexpected audit entry %+v", e)
+	}
+}
+
+func TestDisableUser_RefusesOwnAccount(t *testing.T) {
+	f := newSessionFixture(t)
+	self := natsrpc.WithPrincipal(ctx, &natsrpc.Principal{Subject: f.user.ID.Hex()})
+
+	if _, err := f.svc.DisableUser(self, f.target()); rpcCode(err) != natsrpc.CodeForbidden {
+		t.Errorf("expected FORBIDDEN, got %v", err)
+	}
+	if _, err := f.svc.DeleteUser(self, f.target()); rpcCode(err) != natsrpc.CodeForbidden {
+		t.Errorf("expected FORBIDDEN, got %v", err)
+	}
+	if f.user.Disabled() || len(f.users.users) != 1 || len(f.audit.entries) != 0 {
+		t.Errorf("expected nothing to change")
+	}
+}
+
+// ── ForcePasswordReset tests ─────────────────────────────────────────────────
+
+func TestForcePasswordReset_RefusesLoginsUntilReset(t *testing.T) {
+	f := newSessionFixture(t)
+	pair := f.login(t, "laptop")
+
+	if _, err := f.svc.ForcePasswordReset(asAdmin, f.target()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if f.active(t, pair.Msg) {
+		t.Errorf("expected the access token to be revoked")
+	}
+	_, err := f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "pass"})
+	if rpcCode(err) != natsrpc.CodeForbidden || reason(err) != "password_reset_required" {
+		t.Fatalf("expected FORBIDDEN password_reset_required, got %v", err)
+	}
+	mails := f.mailed(t, models.EventPasswordResetRequested)
+	if len(mails) != 1 {
+		t.Fatalf("expected a reset mail, got %d", len(mails))
+	}
+
+	if _, err := f.svc.ResetPassword(ctx, models.ResetPasswordBody{Token: mails[0].Token, Password: "correct horse"}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "correct horse"}); err != nil {
+		t.Errorf("expected login after the reset, got %v", err)
+	}
+}
+
+// ── DeleteUser tests ─────────────────────────────────────────────────────────
+
+func TestDeleteUser_RemovesUserAndRecordsIt(t *testing.T) {
+	f := newSessionFixture(t)
+	pair := f.login(t, "laptop")
+
+	if _, err := f.svc.DeleteUser(asAdmin, f.target()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(f.users.users) != 0 {
+		t.Errorf("expected the user to be deleted")
+	}
+	if f.active(t, pair.Msg) {
+		t.Errorf("expected the access token to be revoked")
+	}
+	if len(f.outbox.events) != 1 || f.outbox.events[0].Type != models.EventUserDeleted {
+		t.Errorf("expected one %s event, got %+v", models.EventUserDeleted, f.outbox.events)
+	}
+	if e := f.audit.entries; len(e) != 1 || e[0].Action != models.AuditUserDeleted || e[0].Details["email"] != f.user.Email {
+		t.Errorf("unexpected audit trail %+v", e)
+	}
+	if _, err := f.svc.DeleteUser(asAdmin, f.target()); rpcCode(err) != natsrpc.CodeNotFound {
+		t.Errorf("expected NOT_FOUND the second time, got %v", err)
+	}
+}
+
+// ── AuditLog tests ───────────────────────────────────────────────────────────
+
+func TestAuditLog_ListsNewestFirst(t *testing.T) {
+	f := newSessionFixture(t)
+	f.svc.AssignRole(asAdmin, models.RoleBody{UserID: f.user.ID.Hex(), Role: "manager"})
+	f.svc.RevokeRole(asAdmin, models.RoleBody{UserID: f.user.ID.Hex(), Role: "manager"})
+	f.svc.UnlockAccount(asAdmin, models.UnlockAccountBody{Email: "other@test.com"})
+
+	all, err := f.svc.AuditLog(asAdmin, models.AuditQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, r := range all {
+		got = append(got, r.Action)
+	}
+	want := []string{models.AuditAccountUnlocked, models.AuditRoleRevoked, models.AuditRoleAssigned}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	mine, _ := f.svc.AuditLog(asAdmin, models.AuditQuery{UserID: f.user.ID.Hex(), Limit: 1})
+	if len(mine) != 1 || mine[0].Action != models.AuditRoleRevoked || mine[0].Details["role"] != "manager" {
+		t.Errorf("expected the latest entry about the user, got %+v", mine)
+	}
+}
@@ -0,0 +1,173 @@
+package service_test
+
+import (
+	"context"
+	"iLeon/microservices/auth/lockout"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/service"
+	"iLeon/microservices/natsrpc"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ── In-memory AttemptRepository ─────────────────────────────────────────────
+
+type memAttempts map[string]models.LoginAttempts
+
+func (m memAttempts) FindAttempts(_ context.Context, key string) (*models.LoginAttempts, error) {
+	a, ok := m[key]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &a, nil
+}
+
+func (m memAttempts) RecordFailure(_ context.Context, key string, at, expiresAt time.Time) (*models.LoginAttempts, error) {
+	a := m[key]
+	a.Key, a.Failures, a.LastFailureAt, a.ExpiresAt = key, a.Failures+1, at, expiresAt
+	m[key] = a
+	return &a, nil
+}
+
+func (m memAttempts) LockAttempts(_ context.Context, key string, until, expiresAt time.Time) error {
+	a := m[key]
+	a.LockedUntil, a.ExpiresAt = until, expiresAt
+	m[key] = a
+	return nil
+}
+
+func (m memAttempts) DeleteAttempts(_ context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+func newLockoutService(user *models.User) service.AuthService {
+	policy := lockout.Policy{Threshold: 3, Duration: 90 * time.Second, MaxDuration: time.Hour, Window: time.Hour}
+	return newService(usersRepo(user), func(d *service.Dependencies) {
+		d.Lockout = lockout.New(memAttempts{}, lockout.Config{Account: policy, IP: policy})
+	})
+}
+
+func fromIP(ip string) context.Context {
+	h := nats.Header{}
+	h.Set(natsrpc.ForwardedForHeader, ip)
+	return natsrpc.WithHeader(ctx, h)
+}
+
+func retryAfter(err error) any {
+	rpcErr, _ := err.(*natsrpc.Error)
+	if rpcErr == nil {
+		return nil
+	}
+	details, _ := rpcErr.Details.(map[string]int)
+	return details["retry_after"]
+}
+
+// ── Lockout tests ────────────────────────────────────────────────────────────
+
+func TestLoginUser_LocksAccountAfterRepeatedFailures(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	svc := newLockoutService(user)
+	wrong := models.LoginUserBody{Email: user.Email, Password: "wrong"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.LoginUser(ctx, wrong); rpcCode(err) != natsrpc.CodeUnauthenticated {
+			t.Fatalf("attempt %d: expected UNAUTHENTICATED, got %v", i+1, err)
+		}
+	}
+	_, err := svc.LoginUser(ctx, wrong)
+
+	if rpcCode(err) != natsrpc.CodeAccountLocked || retryAfter(err) != 90 {
+		t.Fatalf("expected ACCOUNT_LOCKED with retry_after 90, got %v (%v)", err, retryAfter(err))
+	}
+	_, err = svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"})
+	if rpcCode(err) != natsrpc.CodeAccountLocked {
+		t.Errorf("expected the right password to be refused while locked, got %v", err)
+	}
+}
+
+func TestLoginUser_CountsUnknownAccounts(t *testing.T) {
+	svc := newLockoutService(newUser(t, "user@test.com", "pass"))
+	body := models.LoginUserBody{Email: "ghost@test.com", Password: "x"}
+
+	var err error
+	for i := 0; i < 3; i++ {
+		_, err = svc.LoginUser(ctx, body)
+	}
+
+	if rpcCode(err) != natsrpc.CodeAccountLocked {
+		t.Errorf("expected unknown emails to lock like real ones, got %v", err)
+	}
+}
+
+func TestLoginUser_LocksClientAddress(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	svc := newLockoutService(user)
+	for _, email := range []string{"a@test.com", "b@test.com", "c@test.com"} {
+		svc.LoginUser(fromIP("203.0.113.7"), models.LoginUserBody{Email: email, Password: "x"})
+	}
+
+	_, err := svc.LoginUser(fromIP("203.0.113.7"), models.LoginUserBody{Email: user.Email, Password: "pass"})
+
+	if rpcCode(err) != natsrpc.CodeAccountLocked {
+		t.Errorf("expected the address to be locked, got %v", err)
+	}
+	if _, err := svc.LoginUser(fromIP("198.51.100.1"), models.LoginUserBody{Email: user.Email, Password: "pass"}); err != nil {
+		t.Errorf("expected other addresses to log in, got %v", err)
+	}
+}
+
+func TestLoginUser_SuccessResetsFailures(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	svc := newLockoutService(user)
+	wrong := models.LoginUserBody{Email: user.Email, Password: "wrong"}
+	svc.LoginUser(ctx, wrong)
+	svc.LoginUser(ctx, wrong)
+
+	svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"})
+
+	if _, err := svc.LoginUser(ctx, wrong); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the count to start over after a success, got %v", err)
+	}
+}
+
+func TestLoginUser_RefusedLoginKeepsFailures(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	user.Status = models.StatusDisabled
+	svc := newLockoutService(user)
+	wrong := models.LoginUserBody{Email: user.Email, Password: "wrong"}
+	svc.LoginUser(ctx, wrong)
+	svc.LoginUser(ctx, wrong)
+
+	if _, err := svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"}); rpcCode(err) != natsrpc.CodeForbidden {
+		t.Fatalf("expected FORBIDDEN, got %v", err)
+	}
+
+	if _, err := svc.LoginUser(ctx, wrong); rpcCode(err) != natsrpc.CodeAccountLocked {
+		t.Errorf("expected a refused login not to reset the count, got %v", err)
+	}
+}
+
+func TestUnlockAccount_AllowsLoginAgain(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	svc := newLockoutService(user)
+	for i := 0; i < 3; i++ {
+		svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "wrong"})
+	}
+
+	if _, err := svc.UnlockAccount(ctx, models.UnlockAccountBody{Email: user.Email}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"}); err != nil {
+		t.Errorf("expected login after unlocking, got %v", err)
+	}
+	if _, err := svc.UnlockAccount(ctx, models.UnlockAccountBody{}); rpcCode(err) != natsrpc.CodeValidation {
+		t.Errorf("expected VALIDATION without an email, got %v", err)
+	}
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/auth/totp"
+	"iLeon/microservices/natsrpc"
+	"log"
+	"strings"
+	"time"
+)
+
+// MFAChallengeTTL is how long the second step of a login can wait.
+const MFAChallengeTTL = 5 * time.Minute
+
+// recoveryCodeCount is how many recovery codes enabling MFA hands out.
+const recoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func errInvalidMFAChallenge() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, "Invalid or expired MFA challenge, please log in again")
+}
+
+func errInvalidMFACode() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, "Invalid MFA code")
+}
+
+func errMFASecret(err error) error {
+	return natsrpc.Internal("Couldn't read the MFA secret").Wrap(err)
+}
+
+// EnrollMFA generates an authenticator secret for the access token's user.
+// It only takes effect once ConfirmMFA sees a code generated from it;
+// enrolling again replaces an unconfirmed secret.
+func (s *Service) EnrollMFA(ctx context.Context, accessToken string) (*models.MFAEnrollment, error) {
+	_, user, err := s.currentUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, natsrpc.Conflict("MFA is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, natsrpc.Internal("Failed to create the MFA secret").Wrap(err)
+	}
+	sealed, err := s.secrets.Seal(secret, []byte(user.ID.Hex()))
+	if err != nil {
+		return nil, natsrpc.Internal("Failed to create the MFA secret").Wrap(err)
+	}
+	if err := s.repository.SetMFA(ctx, user.ID, &models.MFA{Secret: sealed}); err != nil {
+		return nil, errUpdateUser(err)
+	}
+
+	return &models.MFAEnrollment{
+		Secret: totp.EncodeSecret(secret),
+		URI:    s.totp.URI(s.mfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA enables MFA once the user proves their app generates codes
+// for the enrolled secret, and returns the recovery codes.
+func (s *Service) ConfirmMFA(ctx context.Context, accessToken string, body models.MFACodeBody) (*models.RecoveryCodes, error) {
+	_, user, err := s.currentUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case user.MFA == nil:
+		return nil, natsrpc.Conflict("Enroll in MFA before confirming it")
+	case user.MFA.Enabled:
+		return nil, natsrpc.Conflict("MFA is already enabled")
+	}
+
+	secret, err := s.openMFASecret(user)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := s.totp.Validate(secret, normalizeCode(body.Code), s.now())
+	if !ok {
+		return nil, errInvalidMFACode()
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, natsrpc.Internal("Failed to create recovery codes").Wrap(err)
+	}
+	now := s.now()
+	if err := s.repository.SetMFA(ctx, user.ID, &models.MFA{
+		Secret:        user.MFA.Secret,
+		Enabled:       true,
+		EnabledAt:     &now,
+		RecoveryCodes: hashes,
+		LastStep:      step,
+	}); err != nil {
+		return nil, errUpdateUser(err)
+	}
+
+	return &models.RecoveryCodes{
+		CustomeResponse: models.CustomeResponse{
+			Msg:     "MFA is enabled. Store these recovery codes safely, they are shown only once",
+			Context: true,
+		},
+		Codes: codes,
+	}, nil
+}
+
+// DisableMFA turns MFA off. With MFA enabled it takes a code from the app
+// or a recovery code, so a stolen access token alone can't do it.
+func (s *Service) DisableMFA(ctx context.Context, accessToken string, body models.MFACodeBody) (*models.CustomeResponse, error) {
+	_, user, err := s.currentUser(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFA == nil {
+		return nil, natsrpc.Conflict("MFA is not enabled")
+	}
+	if user.MFA.Enabled {
+		ok, err := s.checkSecondFactor(ctx, user, body.Code)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errInvalidMFACode()
+		}
+	}
+
+	if err := s.repository.ClearMFA(ctx, user.ID); err != nil {
+		return nil, errUpdateUser(err)
+	}
+	return &models.CustomeResponse{Msg: "MFA is disabled", Context: true}, nil
+}
+
+// challengeMFA answers a login whose password checked out with a challenge
+// for the second factor.
+func (s *Service) challengeMFA(ctx context.Context, user *models.User, device string) (*models.TokenResponse, error) {
+	secret, err := token.NewOpaque()
+	if err != nil {
+		return nil, natsrpc.Internal("Failed to create token").Wrap(err)
+	}
+	now := s.now()
+	if err := s.oneTime.CreateOneTimeToken(ctx, &models.OneTimeToken{
+		TokenHash: token.Hash(secret),
+		Purpose:   models.PurposeMFAChallenge,
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(MFAChallengeTTL),
+		Device:    device,
+	}); err != nil {
+		return nil, errStoreToken(err)
+	}
+
+	return &models.TokenResponse{
+		CustomeResponse: models.CustomeResponse{Msg: "Enter the code from your authenticator app", Context: true},
+		ExpiresIn:       int64(MFAChallengeTTL.Seconds()),
+		MFARequired:     true,
+		MFAToken:        secret,
+	}, nil
+}
+
+// VerifyMFA completes a login: it redeems the challenge with a code from
+// the app or a recovery code and opens the session. Wrong codes count as
+// failed logins, and the challenge stays valid until one is right.
+func (s *Service) VerifyMFA(ctx context.Context, body models.MFAVerifyBody) (*models.TokenResponse, error) {
+	if body.MFAToken == "" {
+		return nil, errInvalidMFAChallenge()
+	}
+	hash := token.Hash(body.MFAToken)
+	challenge, err := s.oneTime.FindOneTimeToken(ctx, hash, models.PurposeMFAChallenge, s.now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidMFAChallenge()
+	}
+	if err != nil {
+		return nil, errStoreToken(err)
+	}
+
+	user, err := s.repository.FindUserByID(ctx, challenge.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidMFAChallenge()
+	}
+	if err != nil {
+		return nil, errReadUser(err)
+	}
+	if !user.MFAEnabled() {
+		return nil, errInvalidMFAChallenge()
+	}
+	if err := loginRefused(user); err != nil {
+		return nil, err
+	}
+
+	ip := natsrpc.ClientIP(ctx)
+	wait, err := s.lockout.Locked(ctx, user.Email, ip)
+	if err != nil {
+		return nil, errLockout(err)
+	}
+	if wait > 0 {
+		return nil, errAccountLocked(wait)
+	}
+
+	ok, err := s.checkSecondFactor(ctx, user, body.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.loginFailed(ctx, user.Email, ip, "Invalid MFA code")
+	}
+
+	if _, err := s.oneTime.ConsumeOneTimeToken(ctx, hash, models.PurposeMFAChallenge, s.now()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, errInvalidMFAChallenge()
+		}
+		return nil, errStoreToken(err)
+	}
+	if err := s.lockout.Succeed(ctx, user.Email); err != nil {
+		log.Printf("couldn't reset failed logins for %s: %v", user.Email, err)
+	}
+	return s.startSession(ctx, user, challenge.Device)
+}
+
+// checkSecondFactor accepts a code from the app or one of the user's
+// recovery codes, and spends it.
+func (s *Service) checkSecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	code = normalizeCode(code)
+	if code == "" {
+		return false, nil
+	}
+
+	if len(code) != s.totp.Digits || !isDigits(code) {
+		used, err := s.repository.UseRecoveryCode(ctx, user.ID, token.Hash(code))
+		if err != nil {
+			return false, errUpdateUser(err)
+		}
+		return used, nil
+	}
+
+	secret, err := s.openMFASecret(user)
+	if err != nil {
+		return false, err
+	}
+	step, ok := s.totp.Validate(secret, code, s.now())
+	if !ok || step <= user.MFA.LastStep {
+		return false, nil
+	}
+	used, err := s.repository.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return false, errUpdateUser(err)
+	}
+	return used, nil
+}
+
+func (s *Service) openMFASecret(user *models.User) ([]byte, error) {
+	secret, err := s.secrets.Open(user.MFA.Secret, []byte(user.ID.Hex()))
+	if err != nil {
+		return nil, errMFASecret(err)
+	}
+	return secret, nil
+}
+
+// newRecoveryCodes returns codes formatted for the user, such as
+// "k3vq7-md2xa", and the hashes to store.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, token.Hash(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeCode drops the spaces and dashes users type codes with.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,249 @@
+package service_test
+
+import (
+	"encoding/base32"
+	"iLeon/microservices/auth/lockout"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/secretbox"
+	"iLeon/microservices/auth/service"
+	"iLeon/microservices/auth/totp"
+	"iLeon/microservices/natsrpc"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mfaFixture struct {
+	*sessionFixture
+	secret []byte
+}
+
+func newMFAFixture(t *testing.T) *mfaFixture {
+	box, err := secretbox.New([]byte("test secret"), "auth mfa secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := lockout.Policy{Threshold: 3, Duration: time.Minute, MaxDuration: time.Hour, Window: time.Hour}
+	return &mfaFixture{sessionFixture: newSessionFixture(t, func(d *service.Dependencies) {
+		d.Secrets = box
+		d.Lockout = lockout.New(memAttempts{}, lockout.Config{Account: policy, IP: policy, Clock: d.Clock})
+	})}
+}
+
+// enroll signs in and enrolls, returning the access token.
+func (f *mfaFixture) enroll(t *testing.T) string {
+	t.Helper()
+	access := f.login(t, "laptop").Msg
+	enrollment, err := f.svc.EnrollMFA(ctx, access)
+	if err != nil {
+		t.Fatalf("enroll failed: %v", err)
+	}
+	if f.secret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret); err != nil {
+		t.Fatal(err)
+	}
+	return access
+}
+
+// enable enrolls and confirms, returning the access token and recovery
+// codes. The clock moves on a period so the next code is fresh.
+func (f *mfaFixture) enable(t *testing.T) (string, []string) {
+	t.Helper()
+	access := f.enroll(t)
+	res, err := f.svc.ConfirmMFA(ctx, access, models.MFACodeBody{Code: f.code()})
+	if err != nil {
+		t.Fatalf("confirm failed: %v", err)
+	}
+	f.clock.Advance(30 * time.Second)
+	return access, res.Codes
+}
+
+func (f *mfaFixture) code() string {
+	return totp.DefaultParams.Code(f.secret, f.clock.Now())
+}
+
+func (f *mfaFixture) challenge(t *testing.T, device string) string {
+	t.Helper()
+	res := f.login(t, device)
+	if !res.MFARequired || res.MFAToken == "" || res.RefreshToken != "" {
+		t.Fatalf("expected an MFA challenge instead of tokens, got %+v", res)
+	}
+	return res.MFAToken
+}
+
+// ── Enrolment tests ──────────────────────────────────────────────────────────
+
+func TestEnrollMFA_ReturnsSecretAndURI(t *testing.T) {
+	f := newMFAFixture(t)
+	access := f.login(t, "laptop").Msg
+
+	res, err := f.svc.EnrollMFA(ctx, access)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(res.URI, "otpauth://totp/iLeon:user@test.com?") || !strings.Contains(res.URI, "secret="+res.Secret) {
+		t.Errorf("unexpected URI %s for secret %s", res.URI, res.Secret)
+	}
+	if f.user.MFA == nil || f.user.MFA.Enabled {
+		t.Fatalf("expected a pending enrolment, got %+v", f.user.MFA)
+	}
+	if strings.Contains(string(f.user.MFA.Secret), res.Secret) {
+		t.Errorf("expected the stored secret to be sealed")
+	}
+}
+
+func TestEnrollMFA_RequiresAccessToken(t *testing.T) {
+	f := newMFAFixture(t)
+
+	_, err := f.svc.EnrollMFA(ctx, "")
+
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected UNAUTHENTICATED, got %v", err)
+	}
+}
+
+func TestConfirmMFA_EnablesWithRecoveryCodes(t *testing.T) {
+	f := newMFAFixture(t)
+	access := f.enroll(t)
+
+	if _, err := f.svc.ConfirmMFA(ctx, access, models.MFACodeBody{Code: "000000"}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Fatalf("expected a wrong code to be refused, got %v", err)
+	}
+	res, err := f.svc.ConfirmMFA(ctx, access, models.MFACodeBody{Code: f.code()})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !f.user.MFAEnabled() {
+		t.Fatalf("expected MFA to be enabled")
+	}
+	if len(res.Codes) != 10 || len(f.user.MFA.RecoveryCodes) != 10 {
+		t.Errorf("expected 10 recovery codes, got %d", len(res.Codes))
+	}
+	for _, stored := range f.user.MFA.RecoveryCodes {
+		for _, code := range res.Codes {
+			if strings.Contains(stored, strings.ReplaceAll(code, "-", "")) {
+				t.Errorf("expected recovery codes to be stored hashed")
+			}
+		}
+	}
+	if _, err := f.svc.EnrollMFA(ctx, access); rpcCode(err) != natsrpc.CodeConflict {
+		t.Errorf("expected enrolling again to be a CONFLICT, got %v", err)
+	}
+}
+
+// ── Login tests ──────────────────────────────────────────────────────────────
+
+func TestLoginUser_ChallengesWhenMFAEnabled(t *testing.T) {
+	f := newMFAFixture(t)
+	f.enable(t)
+	mfaToken := f.challenge(t, "phone")
+
+	res, err := f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: mfaToken, Code: f.code()})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.RefreshToken == "" || !f.active(t, res.Msg) {
+		t.Fatalf("expected a token pair, got %+v", res)
+	}
+	sessions, _ := f.svc.ListSessions(ctx, res.Msg)
+	var devices []string
+	for _, s := range sessions {
+		devices = append(devices, s.Device)
+	}
+	if !strings.Contains(strings.Join(devices, ","), "phone") {
+		t.Errorf("expected the challenge's device on the session, got %v", devices)
+	}
+	if _, err := f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: mfaToken, Code: f.code()}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the challenge to be single-use, got %v", err)
+	}
+}
+
+func TestVerifyMFA_RefusesReplayedCode(t *testing.T) {
+	f := newMFAFixture(t)
+	f.enable(t)
+	code := f.code()
+	if _, err := f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: f.challenge(t, "a"), Code: code}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: f.challenge(t, "b"), Code: code})
+
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected a used code to be refused, got %v", err)
+	}
+}
+
+func TestVerifyMFA_RecoveryCodeWorksOnce(t *testing.T) {
+	f := newMFAFixture(t)
+	_, codes := f.enable(t)
+
+	if _, err := f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: f.challenge(t, "a"), Code: strings.ToUpper(codes[0])}); err != nil {
+		t.Fatalf("expected the recovery code to sign in, got %v", err)
+	}
+	_, err := f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: f.challenge(t, "b"), Code: codes[0]})
+
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected a used recovery code to be refused, got %v", err)
+	}
+	if len(f.user.MFA.RecoveryCodes) != 9 {
+		t.Errorf("expected 9 recovery codes left, got %d", len(f.user.MFA.RecoveryCodes))
+	}
+}
+
+func TestVerifyMFA_WrongCodesLockTheAccount(t *testing.T) {
+	f := newMFAFixture(t)
+	f.enable(t)
+	mfaToken := f.challenge(t, "laptop")
+	wrong := models.MFAVerifyBody{MFAToken: mfaToken, Code: "000000"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.svc.VerifyMFA(ctx, wrong); rpcCode(err) != natsrpc.CodeUnauthenticated {
+			t.Fatalf("attempt %d: expected UNAUTHENTICATED, got %v", i+1, err)
+		}
+	}
+	_, err := f.svc.VerifyMFA(ctx, wrong)
+	if rpcCode(err) != natsrpc.CodeAccountLocked {
+		t.Fatalf("expected the third wrong code to lock the account, got %v", err)
+	}
+	_, err = f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: mfaToken, Code: f.code()})
+	if rpcCode(err) != natsrpc.CodeAccountLocked {
+		t.Errorf("expected the right code to wait for the lock, got %v", err)
+	}
+}
+
+func TestVerifyMFA_RejectsUnknownAndExpiredChallenges(t *testing.T) {
+	f := newMFAFixture(t)
+	f.enable(t)
+	mfaToken := f.challenge(t, "laptop")
+
+	if _, err := f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: "nope", Code: f.code()}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected an unknown challenge to be refused, got %v", err)
+	}
+	f.clock.Advance(service.MFAChallengeTTL)
+	if _, err := f.svc.VerifyMFA(ctx, models.MFAVerifyBody{MFAToken: mfaToken, Code: f.code()}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected an expired challenge to be refused, got %v", err)
+	}
+}
+
+// ── Disable tests ────────────────────────────────────────────────────────────
+
+func TestDisableMFA_RequiresASecondFactor(t *testing.T) {
+	f := newMFAFixture(t)
+	access, _ := f.enable(t)
+
+	if _, err := f.svc.DisableMFA(ctx, access, models.MFACodeBody{}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Fatalf("expected a missing code to be refused, got %v", err)
+	}
+	if _, err := f.svc.DisableMFA(ctx, access, models.MFACodeBody{Code: f.code()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if f.user.MFA != nil {
+		t.Errorf("expected the enrolment to be removed")
+	}
+	if res := f.login(t, "laptop"); res.MFARequired || res.RefreshToken == "" {
+		t.Errorf("expected a password-only login again, got %+v", res)
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/outbox"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/natsrpc"
+	"time"
+)
+
+// mailToken issues a one-time token for purpose, valid for ttl, and queues
+// an event of type event asking the mailer to send it to the address to.
+// The user's earlier tokens for purpose stop working.
+func (s *Service) mailToken(ctx context.Context, user *models.User, to, purpose string, ttl time.Duration, event string) error {
+	secret, err := token.NewOpaque()
+	if err != nil {
+		return natsrpc.Internal("Failed to create token").Wrap(err)
+	}
+	now := s.now()
+	t := &models.OneTimeToken{
+		TokenHash: token.Hash(secret),
+		Purpose:   purpose,
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.oneTime.InvalidateOneTimeTokens(ctx, user.ID, purpose, now); err != nil {
+		return errStoreToken(err)
+	}
+	if err := s.oneTime.CreateOneTimeToken(ctx, t); err != nil {
+		return errStoreToken(err)
+	}
+
+	e, err := outbox.NewEvent(event, models.TokenMail{
+		UserID:    user.ID.Hex(),
+		Email:     to,
+		Token:     secret,
+		ExpiresAt: t.ExpiresAt,
+	}, now)
+	if err != nil {
+		return natsrpc.Internal("Failed to create the email").Wrap(err)
+	}
+	if err := s.outbox.EnqueueEvent(ctx, e); err != nil {
+		return errStoreToken(err)
+	}
+	return nil
+}
+
+// spendToken consumes a one-time token for purpose and returns its user.
+// invalid builds the error for unknown, spent or expired tokens.
+func (s *Service) spendToken(ctx context.Context, secret, purpose string, invalid func() *natsrpc.Error) (*models.User, error) {
+	if secret == "" {
+		return nil, invalid()
+	}
+
+	t, err := s.oneTime.ConsumeOneTimeToken(ctx, token.Hash(secret), purpose, s.now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, invalid()
+	}
+	if err != nil {
+		return nil, errStoreToken(err)
+	}
+
+	user, err := s.repository.FindUserByID(ctx, t.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, invalid()
+	}
+	if err != nil {
+		return nil, errReadUser(err)
+	}
+	return user, nil
+}
+
+func errStoreToken(err error) error {
+	return natsrpc.NewError(natsrpc.CodeUnavailable, "Couldn't store the token").WithRetryable(true).Wrap(err)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/natsrpc"
+	"log"
+)
+
+// resetRequested is the answer to every reset request, so it doesn't reveal
+// which emails are registered.
+const resetRequested = "If the email is registered, a password reset link has been sent"
+
+func errInvalidResetToken() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, "Invalid or expired password reset token")
+}
+
+// RequestPasswordReset issues a reset token and queues an event for the
+// mailer. Earlier tokens of the user stop working.
+func (s *Service) RequestPasswordReset(ctx context.Context, body models.PasswordResetRequestBody) (*models.CustomeResponse, error) {
+	email, err := validEmail(body.Email)
+	if err != nil {
+		return nil, err
+	}
+	done := &models.CustomeResponse{Msg: resetRequested, Context: true}
+
+	user, err := s.repository.FindUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, errReadUser(err)
+	}
+
+	if err := s.mailToken(ctx, user, user.Email, models.PurposePasswordReset, s.resetTTL, models.EventPasswordResetRequested); err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// ResetPassword spends a reset token and sets a new password. Every session
+// of the user is revoked, since the old password may have leaked.
+func (s *Service) ResetPassword(ctx context.Context, body models.ResetPasswordBody) (*models.CustomeResponse, error) {
+	if body.Token == "" {
+		return nil, errInvalidResetToken()
+	}
+	if err := s.validPassword("password", body.Password); err != nil {
+		return nil, err
+	}
+
+	user, err := s.spendToken(ctx, body.Token, models.PurposePasswordReset, errInvalidResetToken)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := s.hashPassword(body.Password)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return nil, errUpdateUser(err)
+	}
+
+	if err := s.signOutEverywhere(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.lockout.Unlock(ctx, user.Email); err != nil {
+		log.Printf("couldn't reset failed logins for %s: %v", user.Email, err)
+	}
+
+	return &models.CustomeResponse{Msg: "Your password has been reset, please log in again", Context: true}, nil
+}
@@ -0,0 +1,222 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/outbox"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/natsrpc"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ── In-memory OneTimeTokenRepository ────────────────────────────────────────
+
+type memOneTime struct {
+	tokens []*models.OneTimeToken
+}
+
+func (m *memOneTime) CreateOneTimeToken(_ context.Context, t *models.OneTimeToken) error {
+	t.ID = primitive.NewObjectID()
+	copy := *t
+	m.tokens = append(m.tokens, &copy)
+	return nil
+}
+
+func (m *memOneTime) FindOneTimeToken(_ context.Context, hash, purpose string, now time.Time) (*models.OneTimeToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			copy := *t
+			return &copy, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memOneTime) ConsumeOneTimeToken(_ context.Context, hash, purpose string, now time.Time) (*models.OneTimeToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			copy := *t
+			return &copy, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memOneTime) InvalidateOneTimeTokens(_ context.Context, userID primitive.ObjectID, purpose string, now time.Time) error {
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// ── In-memory OutboxRepository ──────────────────────────────────────────────
+
+type memOutbox struct {
+	events []*models.OutboxEvent
+}
+
+func (m *memOutbox) EnqueueEvent(_ context.Context, e *models.OutboxEvent) error {
+	e.ID = primitive.NewObjectID()
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *memOutbox) PendingEvents(_ context.Context, limit int) ([]models.OutboxEvent, error) {
+	var out []models.OutboxEvent
+	for _, e := range m.events {
+		if e.PublishedAt == nil && len(out) < limit {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (m *memOutbox) MarkPublished(_ context.Context, id primitive.ObjectID, at time.Time) error {
+	for _, e := range m.events {
+		if e.ID == id {
+			e.PublishedAt, e.Payload = &at, nil
+		}
+	}
+	return nil
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+// mailed relays the outbox to a fake mailer and returns the emails of type
+// event it received.
+func (f *sessionFixture) mailed(t *testing.T, event string) []models.TokenMail {
+	t.Helper()
+	sink := &outbox.Sink{}
+	if err := outbox.NewRelay(f.outbox, sink).Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var mails []models.TokenMail
+	for _, e := range sink.Events() {
+		if e.Type != event {
+			continue
+		}
+		var mail models.TokenMail
+		if err := json.Unmarshal(e.Payload, &mail); err != nil {
+			t.Fatal(err)
+		}
+		mails = append(mails, mail)
+	}
+	return mails
+}
+
+func (f *sessionFixture) requestReset(t *testing.T) string {
+	t.Helper()
+	if _, err := f.svc.RequestPasswordReset(ctx, models.PasswordResetRequestBody{Email: f.user.Email}); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	mails := f.mailed(t, models.EventPasswordResetRequested)
+	if len(mails) != 1 {
+		t.Fatalf("expected one reset email, got %d", len(mails))
+	}
+	return mails[0].Token
+}
+
+// ── RequestPasswordReset tests ───────────────────────────────────────────────
+
+func TestRequestPasswordReset_MailsAToken(t *testing.T) {
+	f := newSessionFixture(t)
+
+	f.svc.RequestPasswordReset(ctx, models.PasswordResetRequestBody{Email: f.user.Email})
+
+	mails := f.mailed(t, models.EventPasswordResetRequested)
+	if len(mails) != 1 || mails[0].Email != f.user.Email || mails[0].Token == "" {
+		t.Fatalf("expected a reset email to the user, got %+v", mails)
+	}
+	if want := f.clock.Now().Add(models.DefaultPasswordResetTTL); !mails[0].ExpiresAt.Equal(want) {
+		t.Errorf("expected the token to expire at %v, got %v", want, mails[0].ExpiresAt)
+	}
+}
+
+func TestRequestPasswordReset_SameAnswerForUnknownEmail(t *testing.T) {
+	f := newSessionFixture(t)
+
+	known, _ := f.svc.RequestPasswordReset(ctx, models.PasswordResetRequestBody{Email: f.user.Email})
+	unknown, err := f.svc.RequestPasswordReset(ctx, models.PasswordResetRequestBody{Email: "ghost@test.com"})
+
+	if err != nil || *known != *unknown {
+		t.Errorf("expected identical answers, got %+v and %+v, %v", known, unknown, err)
+	}
+	if mails := f.mailed(t, models.EventPasswordResetRequested); len(mails) != 1 {
+		t.Errorf("expected only the registered user to be mailed, got %d", len(mails))
+	}
+}
+
+// ── ResetPassword tests ──────────────────────────────────────────────────────
+
+func TestResetPassword_SetsPasswordAndRevokesSessions(t *testing.T) {
+	f := newSessionFixture(t)
+	pair := f.login(t, "laptop")
+	reset := f.requestReset(t)
+	f.clock.Advance(time.Second)
+
+	if _, err := f.svc.ResetPassword(ctx, models.ResetPasswordBody{Token: reset, Password: "a new password"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if f.active(t, pair.Msg) {
+		t.Errorf("expected existing access tokens to be revoked")
+	}
+	if _, err := f.refresh(pair.RefreshToken); err == nil {
+		t.Errorf("expected existing refresh tokens to be revoked")
+	}
+	if _, err := f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "pass"}); err == nil {
+		t.Errorf("expected the old password to stop working")
+	}
+	if _, err := f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "a new password"}); err != nil {
+		t.Errorf("expected the new password to work, got %v", err)
+	}
+}
+
+func TestResetPassword_TokenIsSingleUse(t *testing.T) {
+	f := newSessionFixture(t)
+	reset := f.requestReset(t)
+	f.svc.ResetPassword(ctx, models.ResetPasswordBody{Token: reset, Password: "a new password"})
+
+	_, err := f.svc.ResetPassword(ctx, models.ResetPasswordBody{Token: reset, Password: "another password"})
+
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected a spent token to be refused, got %v", err)
+	}
+}
+
+func TestResetPassword_RejectsExpiredAndSupersededTokens(t *testing.T) {
+	f := newSessionFixture(t)
+	first := f.requestReset(t)
+	second := f.requestReset(t)
+
+	if _, err := f.svc.ResetPassword(ctx, models.ResetPasswordBody{Token: first, Password: "a new password"}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the superseded token to be refused, got %v", err)
+	}
+
+	f.clock.Advance(models.DefaultPasswordResetTTL)
+	if _, err := f.svc.ResetPassword(ctx, models.ResetPasswordBody{Token: second, Password: "a new password"}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the expired token to be refused, got %v", err)
+	}
+}
+
+func TestResetPassword_EnforcesPolicy(t *testing.T) {
+	f := newSessionFixture(t)
+	reset := f.requestReset(t)
+
+	_, err := f.svc.ResetPassword(ctx, models.ResetPasswordBody{Token: reset, Password: "short"})
+
+	if rpcCode(err) != natsrpc.CodeValidation {
+		t.Fatalf("expected VALIDATION, got %v", err)
+	}
+	if _, err := f.svc.ResetPassword(ctx, models.ResetPasswordBody{Token: reset, Password: "long enough"}); err != nil {
+		t.Errorf("expected the token to survive a rejected password, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/rbac"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/natsrpc"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AssignRole grants a role. It shows up in the user's tokens from their next
+// login or refresh.
+func (s *Service) AssignRole(ctx context.Context, body models.RoleBody) (*models.UserRoles, error) {
+	id, err := parseRoleBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repository.AddRole(ctx, id, body.Role)
+	if err != nil {
+		return nil, errUpdateUser(err)
+	}
+	s.audit(ctx, models.AuditRoleAssigned, user.ID.Hex(), map[string]string{"role": body.Role})
+	return userRoles(user), nil
+}
+
+// RevokeRole takes a role away and signs the user out everywhere, so no
+// token carrying the role, not even one from a refresh racing this call,
+// stays valid.
+func (s *Service) RevokeRole(ctx context.Context, body models.RoleBody) (*models.UserRoles, error) {
+	id, err := parseRoleBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repository.RemoveRole(ctx, id, body.Role)
+	if err != nil {
+		return nil, errUpdateUser(err)
+	}
+	if err := s.signOutEverywhere(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	s.audit(ctx, models.AuditRoleRevoked, user.ID.Hex(), map[string]string{"role": body.Role})
+	return userRoles(user), nil
+}
+
+func parseRoleBody(body models.RoleBody) (primitive.ObjectID, error) {
+	id, err := parseUserID(body.UserID)
+	if err != nil {
+		return id, err
+	}
+	if !rbac.ValidRole(body.Role) {
+		return id, natsrpc.Validation("Unknown role " + body.Role)
+	}
+	return id, nil
+}
+
+func userRoles(user *models.User) *models.UserRoles {
+	return &models.UserRoles{
+		UserID:      user.ID.Hex(),
+		Roles:       rbac.Roles(user.Roles),
+		Permissions: rbac.Permissions(user.Roles, user.Permissions),
+	}
+}
+
+func errUpdateUser(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return natsrpc.NotFound("User not found")
+	}
+	return natsrpc.NewError(natsrpc.CodeUnavailable, "Couldn't update the user").WithRetryable(true).Wrap(err)
+}
@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/rbac"
+	"iLeon/microservices/natsrpc"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (f *sessionFixture) claims(t *testing.T, accessToken string) *models.TokenValidation {
+	t.Helper()
+	res, err := f.svc.ValidateToken(ctx, models.ValidateTokenBody{Token: accessToken})
+	if err != nil || !res.Active {
+		t.Fatalf("expected an active token, got %+v, %v", res, err)
+	}
+	return res
+}
+
+// ── Claims tests ─────────────────────────────────────────────────────────────
+
+func TestLoginUser_EmbedsDefaultRole(t *testing.T) {
+	f := newSessionFixture(t)
+
+	claims := f.claims(t, f.login(t, "laptop").Msg)
+
+	if !slices.Equal(claims.Roles, []string{rbac.DefaultRole}) {
+		t.Errorf("expected the default role, got %v", claims.Roles)
+	}
+	if !slices.Equal(claims.Scopes, []string{rbac.CustomersRead, rbac.ProductsRead}) {
+		t.Errorf("expected the default role's permissions, got %v", claims.Scopes)
+	}
+}
+
+func TestLoginUser_EmbedsDirectPermissions(t *testing.T) {
+	f := newSessionFixture(t)
+	f.user.Roles = []string{"manager"}
+	f.user.Permissions = []string{rbac.CustomersDelete}
+
+	claims := f.claims(t, f.login(t, "laptop").Msg)
+
+	if !slices.Contains(claims.Scopes, rbac.CustomersDelete) || !slices.Contains(claims.Scopes, rbac.CustomersWrite) {
+		t.Errorf("expected role and direct permissions, got %v", claims.Scopes)
+	}
+}
+
+func TestRegisterUser_AssignsDefaultRole(t *testing.T) {
+	var created *models.User
+	repo := usersRepo()
+	repo.createFn = func(user *models.User) error {
+		created = user
+		return nil
+	}
+
+	newService(repo).RegisterUser(ctx, models.CreateUserBody{Username: "user", Email: "u@test.com", Password: "correct horse"})
+
+	if created == nil || !slices.Equal(created.Roles, []string{rbac.DefaultRole}) {
+		t.Errorf("expected the new user to get the default role, got %+v", created)
+	}
+}
+
+// ── AssignRole / RevokeRole tests ────────────────────────────────────────────
+
+func TestAssignRole_ShowsUpAfterRefresh(t *testing.T) {
+	f := newSessionFixture(t)
+	pair := f.login(t, "laptop")
+
+	res, err := f.svc.AssignRole(ctx, models.RoleBody{UserID: f.user.ID.Hex(), Role: "admin"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Contains(res.Roles, "admin") || !slices.Equal(res.Permissions, []string{rbac.All}) {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	next, err := f.refresh(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if claims := f.claims(t, next.Msg); !slices.Contains(claims.Scopes, rbac.All) {
+		t.Errorf("expected the refreshed token to carry the new role, got %v", claims.Scopes)
+	}
+}
+
+func TestRevokeRole_SignsOutEverywhere(t *testing.T) {
+	f := newSessionFixture(t)
+	f.user.Roles = []string{rbac.DefaultRole, "admin"}
+	pair := f.login(t, "laptop")
+	f.clock.Advance(time.Second)
+
+	res, err := f.svc.RevokeRole(ctx, models.RoleBody{UserID: f.user.ID.Hex(), Role: "admin"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if slices.Contains(res.Roles, "admin") {
+		t.Errorf("expected the role to be gone, got %v", res.Roles)
+	}
+	if f.active(t, pair.Msg) {
+		t.Errorf("expected the old access token to be revoked")
+	}
+	if _, err := f.refresh(pair.RefreshToken); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the refresh token to be revoked, got %v", err)
+	}
+	next := f.login(t, "laptop")
+	if claims := f.claims(t, next.Msg); slices.Contains(claims.Roles, "admin") {
+		t.Errorf("expected a new login without the role, got %v", claims.Roles)
+	}
+}
+
+func TestRevokeRole_RevokesTokenFromTheSameSecond(t *testing.T) {
+	f := newSessionFixture(t)
+	f.user.Roles = []string{rbac.DefaultRole, "admin"}
+	pair := f.login(t, "laptop")
+
+	if _, err := f.svc.RevokeRole(ctx, models.RoleBody{UserID: f.user.ID.Hex(), Role: "admin"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if f.active(t, pair.Msg) {
+		t.Errorf("expected a token issued in the second of the revocation to be revoked")
+	}
+}
+
+func TestAssignRole_RejectsBadInput(t *testing.T) {
+	f := newSessionFixture(t)
+
+	cases := []struct {
+		body models.RoleBody
+		want natsrpc.Code
+	}{
+		{models.RoleBody{UserID: "nope", Role: "admin"}, natsrpc.CodeValidation},
+		{models.RoleBody{UserID: f.user.ID.Hex(), Role: "root"}, natsrpc.CodeValidation},
+		{models.RoleBody{UserID: primitive.NewObjectID().Hex(), Role: "admin"}, natsrpc.CodeNotFound},
+	}
+	for _, c := range cases {
+		if _, err := f.svc.AssignRole(ctx, c.body); rpcCode(err) != c.want {
+			t.Errorf("%+v: expected %s, got %v", c.body, c.want, err)
+		}
+	}
+}
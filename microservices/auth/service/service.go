@@ -1,29 +1,373 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"iLeon/microservices/auth/lockout"
+	"iLeon/microservices/auth/lru"
 	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/password"
+	"iLeon/microservices/auth/rbac"
 	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/revocation"
+	"iLeon/microservices/auth/secretbox"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/auth/totp"
+	"iLeon/microservices/natsrpc"
+	"log"
+	"math"
+	"time"
 )
 
+// errInvalidCredentials is the answer to a wrong password and an unknown
+// email alike, so logins don't tell which emails are registered.
+const errInvalidCredentials = "Invalid email or password"
+
 type AuthService interface {
-	LoginUser(body models.LoginUserBody) *models.CustomeResponse
-	RegisterUser(models.CreateUserBody) *models.CustomeResponse
+	LoginUser(ctx context.Context, body models.LoginUserBody) (*models.TokenResponse, error)
+	RegisterUser(ctx context.Context, body models.CreateUserBody) (*models.CustomeResponse, error)
+	RefreshToken(ctx context.Context, body models.RefreshTokenBody) (*models.TokenResponse, error)
+	ListSessions(ctx context.Context, accessToken string) ([]models.Session, error)
+	Logout(ctx context.Context, accessToken string) (*models.CustomeResponse, error)
+	RevokeAllSessions(ctx context.Context, accessToken string) (*models.CustomeResponse, error)
+	ValidateToken(ctx context.Context, body models.ValidateTokenBody) (*models.TokenValidation, error)
+	JWKS(ctx context.Context) (*models.JWKS, error)
+	AssignRole(ctx context.Context, body models.RoleBody) (*models.UserRoles, error)
+	RevokeRole(ctx context.Context, body models.RoleBody) (*models.UserRoles, error)
+	UnlockAccount(ctx context.Context, body models.UnlockAccountBody) (*models.CustomeResponse, error)
+	RequestPasswordReset(ctx context.Context, body models.PasswordResetRequestBody) (*models.CustomeResponse, error)
+	ResetPassword(ctx context.Context, body models.ResetPasswordBody) (*models.CustomeResponse, error)
+	VerifyEmail(ctx context.Context, body models.VerifyEmailBody) (*models.CustomeResponse, error)
+	ResendVerification(ctx context.Context, body models.ResendVerificationBody) (*models.CustomeResponse, error)
+	EnrollMFA(ctx context.Context, accessToken string) (*models.MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, accessToken string, body models.MFACodeBody) (*models.RecoveryCodes, error)
+	DisableMFA(ctx context.Context, accessToken string, body models.MFACodeBody) (*models.CustomeResponse, error)
+	VerifyMFA(ctx context.Context, body models.MFAVerifyBody) (*models.TokenResponse, error)
+	GetMe(ctx context.Context, accessToken string) (*models.Profile, error)
+	UpdateProfile(ctx context.Context, accessToken string, body models.UpdateProfileBody) (*models.Profile, error)
+	ChangeEmail(ctx context.Context, accessToken string, body models.ChangeEmailBody) (*models.CustomeResponse, error)
+	ConfirmEmailChange(ctx context.Context, body models.ConfirmEmailChangeBody) (*models.CustomeResponse, error)
+	ChangePassword(ctx context.Context, accessToken string, body models.ChangePasswordBody) (*models.CustomeResponse, error)
+	DeleteAccount(ctx context.Context, accessToken string, body models.DeleteAccountBody) (*models.CustomeResponse, error)
+	ListUsers(ctx context.Context, query models.UserQuery) (*models.UserPage, error)
+	GetUser(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error)
+	DisableUser(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error)
+	EnableUser(ctx context.Context, body models.UserIDBody) (*models.AdminUser, error)
+	ForcePasswordReset(ctx context.Context, body models.UserIDBody) (*models.CustomeResponse, error)
+	DeleteUser(ctx context.Context, body models.UserIDBody) (*models.CustomeResponse, error)
+	AuditLog(ctx context.Context, query models.AuditQuery) ([]models.AuditRecord, error)
+}
+
+// Dependencies are the stores and token issuer the service is built on.
+type Dependencies struct {
+	Users       repository.AuthRepository
+	Sessions    repository.SessionRepository
+	Revocations *revocation.Store
+	Tokens      *token.Manager
+	// OneTimeTokens stores password reset tokens; Outbox the events asking
+	// the mailer to send them.
+	OneTimeTokens repository.OneTimeTokenRepository
+	Outbox        repository.OutboxRepository
+	// PasswordResetTTL and EmailVerificationTTL default to
+	// models.DefaultPasswordResetTTL and models.DefaultEmailVerificationTTL.
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	// RequireVerifiedEmail refuses logins until the email is verified.
+	RequireVerifiedEmail bool
+	// PasswordPolicy defaults to password.DefaultPolicy, and PasswordHasher
+	// to password.DefaultHasher. LegacyHasher is what stored hashes may
+	// still have been made with, password.DefaultHasher unless set.
+	PasswordPolicy *password.Policy
+	PasswordHasher password.Hasher
+	LegacyHasher   password.Hasher
+	// Secrets seals the users' MFA secrets; MFAIssuer names the service in
+	// authenticator apps and defaults to totp.DefaultIssuer.
+	Secrets   *secretbox.Box
+	MFAIssuer string
+	// Lockout throttles failed logins; nil disables it.
+	Lockout *lockout.Guard
+	// Audit records what admins change; nil disables it.
+	Audit repository.AuditRepository
+	// ValidationCacheSize bounds the cache of verified access tokens used
+	// by ValidateToken; zero disables it.
+	ValidationCacheSize int
+	// Clock defaults to time.Now.
+	Clock func() time.Time
 }
 
 type Service struct {
-	repository repository.AuthRepository
+	repository  repository.AuthRepository
+	sessions    repository.SessionRepository
+	revocations *revocation.Store
+	tokens      *token.Manager
+	oneTime     repository.OneTimeTokenRepository
+	outbox      repository.OutboxRepository
+	resetTTL    time.Duration
+	verifyTTL   time.Duration
+	mustVerify  bool
+	policy      password.Policy
+	hasher      password.Hasher
+	secrets     *secretbox.Box
+	mfaIssuer   string
+	totp        totp.Params
+	lockout     *lockout.Guard
+	auditLog    repository.AuditRepository
+	verified    *lru.Cache[string, *token.Claims]
+	now         func() time.Time
+	// dummyHash is checked against for unknown emails, so they take as
+	// long to refuse as wrong passwords.
+	dummyHash string
 }
 
-func NewService(r repository.AuthRepository) AuthService {
+// NewService fails only if the hasher can't hash.
+func NewService(d Dependencies) (AuthService, error) {
+	now := d.Clock
+	if now == nil {
+		now = time.Now
+	}
+	resetTTL := d.PasswordResetTTL
+	if resetTTL <= 0 {
+		resetTTL = models.DefaultPasswordResetTTL
+	}
+	verifyTTL := d.EmailVerificationTTL
+	if verifyTTL <= 0 {
+		verifyTTL = models.DefaultEmailVerificationTTL
+	}
+	issuer := d.MFAIssuer
+	if issuer == "" {
+		issuer = totp.DefaultIssuer
+	}
+	policy := password.DefaultPolicy
+	if d.PasswordPolicy != nil {
+		policy = *d.PasswordPolicy
+	}
+	hasher := d.PasswordHasher
+	if hasher == nil {
+		hasher = password.DefaultHasher
+	}
+	legacy := d.LegacyHasher
+	if legacy == nil {
+		legacy = password.DefaultHasher
+	}
+	dummy, err := newDummyHash(hasher, legacy)
+	if err != nil {
+		return nil, fmt.Errorf("hashing the dummy password: %w", err)
+	}
+
 	return &Service{
-		repository: r,
+		repository:  d.Users,
+		sessions:    d.Sessions,
+		revocations: d.Revocations,
+		tokens:      d.Tokens,
+		oneTime:     d.OneTimeTokens,
+		outbox:      d.Outbox,
+		resetTTL:    resetTTL,
+		verifyTTL:   verifyTTL,
+		mustVerify:  d.RequireVerifiedEmail,
+		policy:      policy,
+		hasher:      hasher,
+		secrets:     d.Secrets,
+		mfaIssuer:   issuer,
+		totp:        totp.DefaultParams,
+		lockout:     d.Lockout,
+		auditLog:    d.Audit,
+		verified:    lru.New[string, *token.Claims](d.ValidationCacheSize),
+		now:         now,
+		dummyHash:   dummy,
+	}, nil
+}
+
+func (s *Service) LoginUser(ctx context.Context, body models.LoginUserBody) (*models.TokenResponse, error) {
+	body, err := validLogin(body)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := natsrpc.ClientIP(ctx)
+	wait, err := s.lockout.Locked(ctx, body.Email, ip)
+	if err != nil {
+		return nil, errLockout(err)
+	}
+	if wait > 0 {
+		return nil, errAccountLocked(wait)
+	}
+
+	user, err := s.repository.FindUserByEmail(ctx, body.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		s.checkDummyPassword(body.Password)
+		return nil, s.loginFailed(ctx, body.Email, ip, errInvalidCredentials)
+	}
+	if err != nil {
+		return nil, errReadUser(err)
+	}
+
+	ok, err := s.checkPassword(user, body.Password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.loginFailed(ctx, body.Email, ip, errInvalidCredentials)
+	}
+	// A refused login leaves the failure count and the stored hash alone.
+	if err := loginRefused(user); err != nil {
+		return nil, err
+	}
+	if s.mustVerify && !user.EmailVerified {
+		return nil, errEmailNotVerified()
+	}
+
+	// With MFA the failures are only forgotten once the code checks out
+	// too, so knowing the password doesn't reset the count of wrong codes.
+	if !user.MFAEnabled() {
+		if err := s.lockout.Succeed(ctx, body.Email); err != nil {
+			log.Printf("couldn't reset failed logins for %s: %v", body.Email, err)
+		}
+	}
+	s.upgradeHash(ctx, user, body.Password)
+	if user.MFAEnabled() {
+		return s.challengeMFA(ctx, user, body.Device)
+	}
+	return s.startSession(ctx, user, body.Device)
+}
+
+// loginFailed counts a failed login and returns the error for the caller:
+// ACCOUNT_LOCKED if this failure locked the account, otherwise message.
+func (s *Service) loginFailed(ctx context.Context, email, ip, message string) error {
+	wait, err := s.lockout.Fail(ctx, email, ip)
+	if err != nil {
+		log.Printf("couldn't record failed login for %s: %v", email, err)
+	}
+	if wait > 0 {
+		return errAccountLocked(wait)
+	}
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, message)
+}
+
+func (s *Service) hashPassword(pw string) (string, error) {
+	hash, err := s.hasher.Hash(pw)
+	if err != nil {
+		return "", natsrpc.Internal("Couldn't hash the password").Wrap(err)
+	}
+	return hash, nil
+}
+
+// checkPassword reports whether pw is the user's password.
+func (s *Service) checkPassword(user *models.User, pw string) (bool, error) {
+	ok, err := s.hasher.Verify(user.Password, pw)
+	if err != nil {
+		return false, natsrpc.Internal("Couldn't check the password").Wrap(err)
+	}
+	return ok, nil
+}
+
+// newDummyHash hashes a random password with whichever of hashers takes
+// longest to check, so an unknown email takes no less time than a user whose
+// hash is of the slower kind.
+func newDummyHash(hashers ...password.Hasher) (string, error) {
+	pw, err := token.NewOpaque()
+	if err != nil {
+		return "", err
+	}
+	var slowest string
+	var longest time.Duration
+	for _, h := range hashers {
+		hash, err := h.Hash(pw)
+		if err != nil {
+			return "", err
+		}
+		start := time.Now()
+		h.Verify(hash, pw)
+		if took := time.Since(start); slowest == "" || took > longest {
+			slowest, longest = hash, took
+		}
 	}
+	return slowest, nil
+}
+
+// checkDummyPassword spends the time checking a password takes.
+func (s *Service) checkDummyPassword(pw string) {
+	s.hasher.Verify(s.dummyHash, pw)
+}
+
+// upgradeHash rehashes a correct password whose stored hash is of another
+// algorithm or weaker than the hasher's, so raising the cost reaches users
+// as they log in. A failure only leaves the old hash in place.
+func (s *Service) upgradeHash(ctx context.Context, user *models.User, pw string) {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return
+	}
+	hash, err := s.hasher.Hash(pw)
+	if err == nil {
+		err = s.repository.ReplacePasswordHash(ctx, user.ID, user.Password, hash)
+	}
+	if err != nil {
+		log.Printf("couldn't upgrade the password hash of user %s: %v", user.ID.Hex(), err)
+	}
+}
+
+func (s *Service) UnlockAccount(ctx context.Context, body models.UnlockAccountBody) (*models.CustomeResponse, error) {
+	email, err := validEmail(body.Email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lockout.Unlock(ctx, email); err != nil {
+		return nil, errLockout(err)
+	}
+	s.audit(ctx, models.AuditAccountUnlocked, email, nil)
+	return &models.CustomeResponse{Msg: "Unlocked " + email, Context: true}, nil
+}
+
+func (s *Service) RegisterUser(ctx context.Context, body models.CreateUserBody) (*models.CustomeResponse, error) {
+	body, err := s.validRegistration(body)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := s.hashPassword(body.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	user := &models.User{
+		Username:  body.Username,
+		Email:     body.Email,
+		Password:  hash,
+		Roles:     []string{rbac.DefaultRole},
+		Status:    models.StatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	}
+	// The unique email index decides between concurrent registrations.
+	err = s.repository.CreateUser(ctx, user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, errEmailInUse()
+	}
+	if err != nil {
+		return nil, natsrpc.Internal("Couldn't insert the new user into the database").Wrap(err)
+	}
+	// The account exists either way; the user can ask for another mail.
+	if err := s.sendVerification(ctx, user); err != nil {
+		log.Printf("couldn't send the verification email to %s: %v", user.Email, err)
+	}
+
+	return &models.CustomeResponse{
+		Msg:     "Created the new user with ID " + user.ID.Hex(),
+		Context: true,
+	}, nil
+}
+
+func errAccountLocked(wait time.Duration) error {
+	return natsrpc.NewError(natsrpc.CodeAccountLocked, "Too many failed logins, try again later").
+		WithDetails(map[string]int{"retry_after": int(math.Ceil(wait.Seconds()))})
 }
 
-func (s *Service) LoginUser(body models.LoginUserBody) *models.CustomeResponse {
-	return s.repository.Login(body)
+func errLockout(err error) error {
+	return natsrpc.NewError(natsrpc.CodeUnavailable, "Couldn't check failed logins").WithRetryable(true).Wrap(err)
 }
 
-func (s *Service) RegisterUser(body models.CreateUserBody) *models.CustomeResponse {
-	return s.repository.Register(body)
+func errReadUser(err error) error {
+	return natsrpc.NewError(natsrpc.CodeUnavailable, "Couldn't read the user").WithRetryable(true).Wrap(err)
 }
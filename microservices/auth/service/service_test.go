@@ -1,168 +1,753 @@
 package service_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/password"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/revocation"
 	"iLeon/microservices/auth/service"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/natsrpc"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // ── Manual mock for AuthRepository ──────────────────────────────────────────
 
 type mockAuthRepo struct {
-	loginFn    func(body models.LoginUserBody) *models.CustomeResponse
-	registerFn func(body models.CreateUserBody) *models.CustomeResponse
+	// users backs the lookups of usersRepo and is what DeleteUser removes
+	// from.
+	users         []*models.User
+	findByEmailFn func(email string) (*models.User, error)
+	findByIDFn    func(id primitive.ObjectID) (*models.User, error)
+	createFn      func(user *models.User) error
+	lastCtx       context.Context
+}
+
+func (m *mockAuthRepo) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.lastCtx = ctx
+	return m.findByEmailFn(email)
+}
+
+func (m *mockAuthRepo) FindUserByID(_ context.Context, id primitive.ObjectID) (*models.User, error) {
+	return m.findByIDFn(id)
+}
+
+func (m *mockAuthRepo) CreateUser(_ context.Context, user *models.User) error {
+	return m.createFn(user)
+}
+
+func (m *mockAuthRepo) UpdatePassword(_ context.Context, id primitive.ObjectID, hash string) error {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return err
+	}
+	user.Password, user.MustResetPassword = hash, false
+	return nil
+}
+
+func (m *mockAuthRepo) ReplacePasswordHash(_ context.Context, id primitive.ObjectID, oldHash, newHash string) error {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return err
+	}
+	if user.Password == oldHash {
+		user.Password = newHash
+	}
+	return nil
+}
+
+func (m *mockAuthRepo) MarkEmailVerified(_ context.Context, id primitive.ObjectID) error {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return err
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+func (m *mockAuthRepo) AddRole(_ context.Context, id primitive.ObjectID, role string) (*models.User, error) {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(user.Roles, role) {
+		user.Roles = append(user.Roles, role)
+	}
+	return user, nil
+}
+
+func (m *mockAuthRepo) RemoveRole(_ context.Context, id primitive.ObjectID, role string) (*models.User, error) {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = slices.DeleteFunc(user.Roles, func(r string) bool { return r == role })
+	return user, nil
+}
+
+func (m *mockAuthRepo) UpdateProfile(_ context.Context, id primitive.ObjectID, username, displayName *string) (*models.User, error) {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return nil, err
+	}
+	if username != nil {
+		user.Username = *username
+	}
+	if displayName != nil {
+		user.DisplayName = *displayName
+	}
+	return user, nil
+}
+
+func (m *mockAuthRepo) SetPendingEmail(_ context.Context, id primitive.ObjectID, email string) error {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return err
+	}
+	user.PendingEmail = email
+	return nil
+}
+
+func (m *mockAuthRepo) ConfirmEmail(_ context.Context, id primitive.ObjectID, email string) error {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return err
+	}
+	if user.PendingEmail != email {
+		return repository.ErrNotFound
+	}
+	if other, err := m.findByEmailFn(email); err == nil && other.ID != id {
+		return repository.ErrDuplicate
+	}
+	user.Email, user.EmailVerified, user.PendingEmail = email, true, ""
+	return nil
+}
+
+func (m *mockAuthRepo) DeleteUser(_ context.Context, id primitive.ObjectID) error {
+	n := len(m.users)
+	m.users = slices.DeleteFunc(m.users, func(u *models.User) bool { return u.ID == id })
+	if len(m.users) == n {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (m *mockAuthRepo) ListUsers(_ context.Context, query models.UserQuery) ([]models.User, int, error) {
+	var matched []models.User
+	for _, u := range m.users {
+		search := strings.ToLower(query.Search)
+		switch {
+		case search != "" && !strings.Contains(strings.ToLower(u.Username), search) && !strings.Contains(strings.ToLower(u.Email), search):
+		case query.Status == models.StatusActive && u.Disabled():
+		case query.Status == models.StatusDisabled && !u.Disabled():
+		case query.Role != "" && !slices.Contains(u.Roles, query.Role):
+		default:
+			matched = append(matched, *u)
+		}
+	}
+	start := min((query.Page-1)*query.Limit, len(matched))
+	end := min(start+query.Limit, len(matched))
+	return matched[start:end], len(matched), nil
+}
+
+func (m *mockAuthRepo) SetStatus(_ context.Context, id primitive.ObjectID, status string) (*models.User, error) {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return nil, err
+	}
+	user.Status = status
+	return user, nil
+}
+
+func (m *mockAuthRepo) SetMustResetPassword(_ context.Context, id primitive.ObjectID, must bool) (*models.User, error) {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return nil, err
+	}
+	user.MustResetPassword = must
+	return user, nil
+}
+
+func (m *mockAuthRepo) SetMFA(_ context.Context, id primitive.ObjectID, mfa *models.MFA) error {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return err
+	}
+	copy := *mfa
+	user.MFA = &copy
+	return nil
+}
+
+func (m *mockAuthRepo) ClearMFA(_ context.Context, id primitive.ObjectID) error {
+	user, err := m.findByIDFn(id)
+	if err != nil {
+		return err
+	}
+	user.MFA = nil
+	return nil
+}
+
+func (m *mockAuthRepo) UseTOTPStep(_ context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	user, err := m.findByIDFn(id)
+	if err != nil || user.MFA == nil || user.MFA.LastStep >= step {
+		return false, err
+	}
+	user.MFA.LastStep = step
+	return true, nil
+}
+
+func (m *mockAuthRepo) UseRecoveryCode(_ context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	user, err := m.findByIDFn(id)
+	if err != nil || user.MFA == nil || !slices.Contains(user.MFA.RecoveryCodes, hash) {
+		return false, err
+	}
+	user.MFA.RecoveryCodes = slices.DeleteFunc(user.MFA.RecoveryCodes, func(h string) bool { return h == hash })
+	return true, nil
+}
+
+// ── In-memory SessionRepository ─────────────────────────────────────────────
+
+type memSessions struct {
+	tokens []*models.RefreshToken
+	// loseRace makes MarkRotated report that another request got there first.
+	loseRace bool
+}
+
+func (m *memSessions) CreateRefreshToken(_ context.Context, t *models.RefreshToken) error {
+	t.ID = primitive.NewObjectID()
+	copy := *t
+	m.tokens = append(m.tokens, &copy)
+	return nil
+}
+
+func (m *memSessions) FindRefreshToken(_ context.Context, hash string) (*models.RefreshToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == hash {
+			copy := *t
+			return &copy, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memSessions) MarkRotated(_ context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	for _, t := range m.tokens {
+		if t.ID == id && t.RotatedAt == nil && t.RevokedAt == nil && !m.loseRace {
+			t.RotatedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memSessions) RevokeFamily(_ context.Context, familyID string, at time.Time) error {
+	for _, t := range m.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (m *memSessions) RevokeUserSessions(_ context.Context, userID primitive.ObjectID, at time.Time) error {
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (m *memSessions) ListActive(_ context.Context, userID primitive.ObjectID, now time.Time) ([]models.RefreshToken, error) {
+	var out []models.RefreshToken
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RotatedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt) {
+			out = append(out, *t)
+		}
+	}
+	return out, nil
 }
 
-func (m *mockAuthRepo) Login(body models.LoginUserBody) *models.CustomeResponse {
-	return m.loginFn(body)
+// ── In-memory RevocationRepository ──────────────────────────────────────────
+
+type memRevocations struct {
+	docs map[string]models.Revocation
+}
+
+func newRevocations(opts ...revocation.Option) *revocation.Store {
+	return revocation.NewStore(&memRevocations{docs: map[string]models.Revocation{}}, opts...)
 }
 
-func (m *mockAuthRepo) Register(body models.CreateUserBody) *models.CustomeResponse {
-	return m.registerFn(body)
+func (m *memRevocations) SaveRevocation(_ context.Context, r models.Revocation) error {
+	m.docs[r.ID] = r
+	return nil
 }
 
-// ── Helper ───────────────────────────────────────────────────────────────────
+func (m *memRevocations) RevocationsSince(_ context.Context, since, now time.Time) ([]models.Revocation, error) {
+	var out []models.Revocation
+	for _, r := range m.docs {
+		if !r.RevokedAt.Before(since) && r.ExpiresAt.After(now) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+var ctx = context.Background()
 
-func newService(login func(models.LoginUserBody) *models.CustomeResponse,
-	register func(models.CreateUserBody) *models.CustomeResponse) service.AuthService {
-	return service.NewService(&mockAuthRepo{loginFn: login, registerFn: register})
+var testKeys = func() token.StaticKeySet {
+	k, err := token.GenerateKey("test", token.ES256)
+	if err != nil {
+		panic(err)
+	}
+	return token.StaticKeySet{k}
+}()
+
+func newTokens(opts ...token.Option) *token.Manager {
+	return token.NewManager(testKeys, 15*time.Minute, 24*time.Hour, opts...)
+}
+
+// clock is a settable time source shared by the service and the test.
+type clock struct{ now time.Time }
+
+func newClock() *clock                   { return &clock{now: time.Now()} }
+func (c *clock) Now() time.Time          { return c.now }
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newService builds the service on in-memory stores; opts adjust its
+// dependencies before it is built.
+func newService(repo *mockAuthRepo, opts ...func(*service.Dependencies)) service.AuthService {
+	d := service.Dependencies{
+		Users:          repo,
+		Sessions:       &memSessions{},
+		Revocations:    newRevocations(),
+		Tokens:         newTokens(),
+		OneTimeTokens:  &memOneTime{},
+		Outbox:         &memOutbox{},
+		PasswordHasher: testHasher,
+		LegacyHasher:   testHasher,
+	}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	svc, err := service.NewService(d)
+	if err != nil {
+		panic(err)
+	}
+	return svc
+}
+
+// testHasher keeps hashing cheap; the users of newUser are hashed with it,
+// so logins don't rehash.
+var testHasher = password.BcryptHasher{Cost: bcrypt.MinCost}
+
+func hashed(t *testing.T, pw string) string {
+	t.Helper()
+	h, err := testHasher.Hash(pw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func newUser(t *testing.T, email, password string) *models.User {
+	return &models.User{ID: primitive.NewObjectID(), Username: "user", Email: email, Password: hashed(t, password)}
+}
+
+// usersRepo serves the given users by email and ID.
+func usersRepo(users ...*models.User) *mockAuthRepo {
+	m := &mockAuthRepo{users: users}
+	m.findByEmailFn = func(email string) (*models.User, error) {
+		for _, u := range m.users {
+			if u.Email == email {
+				return u, nil
+			}
+		}
+		return nil, repository.ErrNotFound
+	}
+	m.findByIDFn = func(id primitive.ObjectID) (*models.User, error) {
+		for _, u := range m.users {
+			if u.ID == id {
+				return u, nil
+			}
+		}
+		return nil, repository.ErrNotFound
+	}
+	// Like the unique email index.
+	m.createFn = func(user *models.User) error {
+		if _, err := m.findByEmailFn(user.Email); err == nil {
+			return repository.ErrDuplicate
+		}
+		user.ID = primitive.NewObjectID()
+		m.users = append(m.users, user)
+		return nil
+	}
+	return m
+}
+
+func rpcCode(err error) natsrpc.Code {
+	var rpcErr *natsrpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code
+	}
+	return ""
 }
 
 // ── LoginUser tests ──────────────────────────────────────────────────────────
 
 func TestLoginUser_Success(t *testing.T) {
-	expectedToken := "jwt.token.string"
-	svc := newService(
-		func(_ models.LoginUserBody) *models.CustomeResponse {
-			return &models.CustomeResponse{Msg: expectedToken, Context: true}
-		},
-		nil,
-	)
+	user := newUser(t, "user@test.com", "pass")
+	svc := newService(usersRepo(user))
 
-	result := svc.LoginUser(models.LoginUserBody{Email: "user@test.com", Password: "pass"})
+	result, err := svc.LoginUser(ctx, models.LoginUserBody{Email: "user@test.com", Password: "pass"})
 
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if !result.Context {
 		t.Errorf("expected Context=true, got false")
 	}
-	if result.Msg != expectedToken {
-		t.Errorf("expected Msg=%q, got %q", expectedToken, result.Msg)
+	claims, err := newTokens().Parse(result.Msg)
+	if err != nil {
+		t.Fatalf("expected a valid access token in Msg: %v", err)
+	}
+	if claims.Subject != user.ID.Hex() || claims.Email != user.Email || claims.SessionID == "" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if result.RefreshToken == "" || result.TokenType != "Bearer" || result.ExpiresIn != 900 {
+		t.Errorf("unexpected token response: %+v", result)
 	}
 }
 
 func TestLoginUser_InvalidEmail(t *testing.T) {
-	svc := newService(
-		func(_ models.LoginUserBody) *models.CustomeResponse {
-			return &models.CustomeResponse{Msg: "Invalid user email or password", Context: false}
-		},
-		nil,
-	)
+	svc := newService(usersRepo())
 
-	result := svc.LoginUser(models.LoginUserBody{Email: "nobody@test.com", Password: "pass"})
+	result, err := svc.LoginUser(ctx, models.LoginUserBody{Email: "nobody@test.com", Password: "pass"})
 
-	if result.Context {
-		t.Errorf("expected Context=false for unknown email")
+	if result != nil {
+		t.Errorf("expected no response for unknown email")
 	}
-	if result.Msg != "Invalid user email or password" {
-		t.Errorf("unexpected message: %q", result.Msg)
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected UNAUTHENTICATED, got %v", err)
 	}
 }
 
 func TestLoginUser_WrongPassword(t *testing.T) {
-	svc := newService(
-		func(_ models.LoginUserBody) *models.CustomeResponse {
-			return &models.CustomeResponse{Msg: "Invalid password", Context: false}
-		},
-		nil,
-	)
+	svc := newService(usersRepo(newUser(t, "user@test.com", "pass")))
 
-	result := svc.LoginUser(models.LoginUserBody{Email: "user@test.com", Password: "wrong"})
+	result, err := svc.LoginUser(ctx, models.LoginUserBody{Email: "user@test.com", Password: "wrong"})
 
-	if result.Context {
-		t.Errorf("expected Context=false for wrong password")
+	if result != nil {
+		t.Errorf("expected no response for wrong password")
 	}
-	if result.Msg != "Invalid password" {
-		t.Errorf("unexpected message: %q", result.Msg)
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected UNAUTHENTICATED, got %v", err)
+	}
+}
+
+func TestLoginUser_UnknownEmailAnswersLikeWrongPassword(t *testing.T) {
+	svc := newService(usersRepo(newUser(t, "user@test.com", "pass")))
+
+	_, unknown := svc.LoginUser(ctx, models.LoginUserBody{Email: "nobody@test.com", Password: "pass"})
+	_, wrong := svc.LoginUser(ctx, models.LoginUserBody{Email: "user@test.com", Password: "wrong"})
+
+	a, err := json.Marshal(unknown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(wrong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Errorf("expected the same response, got %s for an unknown email and %s for a wrong password", a, b)
+	}
+}
+
+// countingHasher counts the passwords checked.
+type countingHasher struct {
+	password.Hasher
+	verified int
+}
+
+func (h *countingHasher) Verify(hash, pw string) (bool, error) {
+	h.verified++
+	return h.Hasher.Verify(hash, pw)
+}
+
+func TestLoginUser_UnknownEmailStillChecksAPassword(t *testing.T) {
+	hasher := &countingHasher{Hasher: testHasher}
+	svc := newService(usersRepo(), func(d *service.Dependencies) {
+		d.PasswordHasher = hasher
+	})
+	hasher.verified = 0
+
+	for range 2 {
+		svc.LoginUser(ctx, models.LoginUserBody{Email: "nobody@test.com", Password: "pass"})
+	}
+
+	if hasher.verified != 2 {
+		t.Errorf("expected a password check per login, got %d", hasher.verified)
+	}
+}
+
+// brokenHasher can't hash.
+type brokenHasher struct{ password.Hasher }
+
+func (brokenHasher) Hash(string) (string, error) { return "", errors.New("no entropy") }
+
+// recordingHasher remembers the hashes passwords are checked against.
+type recordingHasher struct {
+	password.Hasher
+	checked []string
+}
+
+func (h *recordingHasher) Verify(hash, pw string) (bool, error) {
+	h.checked = append(h.checked, hash)
+	return h.Hasher.Verify(hash, pw)
+}
+
+func TestLoginUser_UnknownEmailChecksTheSlowerHash(t *testing.T) {
+	fast := &recordingHasher{Hasher: password.Argon2idHasher{Params: password.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}}
+	slow := password.BcryptHasher{Cost: bcrypt.MinCost + 4}
+	svc := newService(usersRepo(), func(d *service.Dependencies) {
+		d.PasswordHasher = fast
+		d.LegacyHasher = slow
+	})
+	fast.checked = nil
+
+	svc.LoginUser(ctx, models.LoginUserBody{Email: "nobody@test.com", Password: "pass"})
+
+	if len(fast.checked) != 1 || password.Algorithm(fast.checked[0]) != password.Bcrypt {
+		t.Errorf("expected the unknown email to be checked against the slower bcrypt hash, got %q", fast.checked)
+	}
+}
+
+func TestNewService_FailsWithoutADummyHash(t *testing.T) {
+	_, err := service.NewService(service.Dependencies{PasswordHasher: brokenHasher{testHasher}, LegacyHasher: testHasher})
+
+	if err == nil {
+		t.Errorf("expected an error when the dummy password can't be hashed")
+	}
+}
+
+func TestLoginUser_LooksUpUserByEmail(t *testing.T) {
+	var captured string
+	svc := newService(&mockAuthRepo{findByEmailFn: func(email string) (*models.User, error) {
+		captured = email
+		return nil, repository.ErrNotFound
+	}})
+
+	svc.LoginUser(ctx, models.LoginUserBody{Email: "a@b.com", Password: "secret"})
+
+	if captured != "a@b.com" {
+		t.Errorf("repository received wrong email: %q", captured)
+	}
+}
+
+func TestLoginUser_PassesRequestContextToRepository(t *testing.T) {
+	repo := usersRepo()
+	svc := newService(repo)
+
+	type key struct{}
+	reqCtx := context.WithValue(ctx, key{}, "request")
+	svc.LoginUser(reqCtx, models.LoginUserBody{Email: "a@b.com", Password: "pw"})
+
+	if repo.lastCtx != reqCtx {
+		t.Errorf("expected the request context to reach the repository")
 	}
 }
 
-func TestLoginUser_DelegatesBodyToRepository(t *testing.T) {
-	captured := models.LoginUserBody{}
-	svc := newService(
-		func(body models.LoginUserBody) *models.CustomeResponse {
-			captured = body
-			return &models.CustomeResponse{Msg: "token", Context: true}
-		},
-		nil,
-	)
+func TestLoginUser_RepositoryFailureIsRetryable(t *testing.T) {
+	svc := newService(&mockAuthRepo{findByEmailFn: func(string) (*models.User, error) {
+		return nil, errors.New("connection reset")
+	}})
 
-	payload := models.LoginUserBody{Email: "a@b.com", Password: "secret"}
-	svc.LoginUser(payload)
+	_, err := svc.LoginUser(ctx, models.LoginUserBody{Email: "a@b.com", Password: "pw"})
 
-	if captured.Email != payload.Email || captured.Password != payload.Password {
-		t.Errorf("repository received wrong payload: got %+v, want %+v", captured, payload)
+	var rpcErr *natsrpc.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != natsrpc.CodeUnavailable || !rpcErr.Retryable {
+		t.Errorf("expected retryable UNAVAILABLE, got %v", err)
+	}
+}
+
+// ── Password hashing tests ──────────────────────────────────────────────────
+
+func newHashingService(user *models.User, hasher password.Hasher) service.AuthService {
+	return newService(usersRepo(user), func(d *service.Dependencies) {
+		d.PasswordHasher = hasher
+	})
+}
+
+func TestLoginUser_RehashesWeakerHash(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	stronger := password.BcryptHasher{Cost: bcrypt.MinCost + 1}
+	svc := newHashingService(user, stronger)
+
+	if _, err := svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cost, _ := bcrypt.Cost([]byte(user.Password)); cost != bcrypt.MinCost+1 {
+		t.Errorf("expected the hash to be upgraded to cost %d, got %d", bcrypt.MinCost+1, cost)
+	}
+	if _, err := svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"}); err != nil {
+		t.Errorf("expected the upgraded hash to work, got %v", err)
+	}
+}
+
+func TestLoginUser_MigratesToArgon2id(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	argon := password.Argon2idHasher{Params: password.Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}
+	svc := newHashingService(user, argon)
+	old := user.Password
+
+	if _, err := svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "wrong"}); err == nil {
+		t.Fatal("expected a wrong password to fail")
+	}
+	if user.Password != old {
+		t.Fatalf("expected a failed login to leave the hash alone")
+	}
+	if _, err := svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"}); err != nil {
+		t.Fatalf("expected the bcrypt hash to still work, got %v", err)
+	}
+
+	if password.Algorithm(user.Password) != password.Argon2id || !strings.HasPrefix(user.Password, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("expected an argon2id PHC hash, got %q", user.Password)
+	}
+	if _, err := svc.LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"}); err != nil {
+		t.Errorf("expected the argon2id hash to work, got %v", err)
+	}
+}
+
+func TestLoginUser_KeepsHashUpToPolicy(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	old := user.Password
+
+	newHashingService(user, testHasher).LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"})
+
+	if user.Password != old {
+		t.Errorf("expected a hash matching the policy to be kept")
+	}
+}
+
+func TestLoginUser_RefusedLoginKeepsHash(t *testing.T) {
+	user := newUser(t, "user@test.com", "pass")
+	user.Status = models.StatusDisabled
+	old := user.Password
+
+	newHashingService(user, password.BcryptHasher{Cost: bcrypt.MinCost + 1}).LoginUser(ctx, models.LoginUserBody{Email: user.Email, Password: "pass"})
+
+	if user.Password != old {
+		t.Errorf("expected a refused login to leave the hash alone")
 	}
 }
 
 // ── RegisterUser tests ───────────────────────────────────────────────────────
 
 func TestRegisterUser_Success(t *testing.T) {
-	svc := newService(
-		nil,
-		func(_ models.CreateUserBody) *models.CustomeResponse {
-			return &models.CustomeResponse{Msg: "Created the new user", Context: true}
-		},
-	)
-
-	result := svc.RegisterUser(models.CreateUserBody{
+	repo := usersRepo()
+	var stored *models.User
+	repo.createFn = func(user *models.User) error {
+		user.ID = primitive.NewObjectID()
+		stored = user
+		return nil
+	}
+	svc := newService(repo)
+
+	result, err := svc.RegisterUser(ctx, models.CreateUserBody{
 		Username: "alice",
 		Email:    "alice@test.com",
 		Password: "securepass",
 	})
 
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if !result.Context {
 		t.Errorf("expected Context=true for new user")
 	}
-	if result.Msg != "Created the new user" {
+	if result.Msg != "Created the new user with ID "+stored.ID.Hex() {
 		t.Errorf("unexpected message: %q", result.Msg)
 	}
 }
 
 func TestRegisterUser_DuplicateEmail(t *testing.T) {
-	svc := newService(
-		nil,
-		func(_ models.CreateUserBody) *models.CustomeResponse {
-			return &models.CustomeResponse{
-				Msg:     "This user with the current email already exists!",
-				Context: false,
-			}
-		},
-	)
+	svc := newService(usersRepo(newUser(t, "alice@test.com", "pass")))
 
-	result := svc.RegisterUser(models.CreateUserBody{
+	_, err := svc.RegisterUser(ctx, models.CreateUserBody{
 		Username: "alice2",
 		Email:    "alice@test.com",
-		Password: "pass",
+		Password: "securepass",
+	})
+
+	if rpcCode(err) != natsrpc.CodeConflict {
+		t.Errorf("expected CONFLICT for duplicate email, got %v", err)
+	}
+}
+
+func TestRegisterUser_DuplicateKeyIsConflict(t *testing.T) {
+	// A concurrent registration got the email in first; only the insert
+	// finds out.
+	repo := usersRepo()
+	repo.createFn = func(*models.User) error { return repository.ErrDuplicate }
+
+	_, err := newService(repo).RegisterUser(ctx, models.CreateUserBody{
+		Username: "alice",
+		Email:    "alice@test.com",
+		Password: "securepass",
 	})
 
-	if result.Context {
-		t.Errorf("expected Context=false for duplicate email")
+	if rpcCode(err) != natsrpc.CodeConflict {
+		t.Errorf("expected CONFLICT, got %v", err)
 	}
 }
 
-func TestRegisterUser_DelegatesBodyToRepository(t *testing.T) {
-	var captured models.CreateUserBody
-	svc := newService(
-		nil,
-		func(body models.CreateUserBody) *models.CustomeResponse {
-			captured = body
-			return &models.CustomeResponse{Msg: "ok", Context: true}
-		},
-	)
+func TestRegisterUser_StoresUserDocument(t *testing.T) {
+	repo := usersRepo()
+	svc := newService(repo)
 
-	payload := models.CreateUserBody{Username: "bob", Email: "bob@test.com", Password: "pw"}
-	svc.RegisterUser(payload)
+	before := time.Now()
+	if _, err := svc.RegisterUser(ctx, models.CreateUserBody{Username: "alice", Email: " Alice@Test.com", Password: "securepass"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
-	if captured.Username != payload.Username || captured.Email != payload.Email {
-		t.Errorf("repository received wrong payload: got %+v, want %+v", captured, payload)
+	stored := repo.users[0]
+	if stored.Email != "alice@test.com" || stored.Status != models.StatusActive || stored.Version != 1 {
+		t.Errorf("unexpected user %+v", stored)
+	}
+	if stored.CreatedAt.Before(before) || !stored.UpdatedAt.Equal(stored.CreatedAt) {
+		t.Errorf("expected creation timestamps, got %v / %v", stored.CreatedAt, stored.UpdatedAt)
+	}
+}
+
+func TestRegisterUser_StoresHashedPassword(t *testing.T) {
+	repo := usersRepo()
+	var stored *models.User
+	repo.createFn = func(user *models.User) error {
+		stored = user
+		return nil
+	}
+	svc := newService(repo)
+
+	payload := models.CreateUserBody{Username: "bob", Email: "bob@test.com", Password: "securepass"}
+	svc.RegisterUser(ctx, payload)
+
+	if stored.Username != payload.Username || stored.Email != payload.Email {
+		t.Errorf("repository received wrong user: got %+v, want %+v", stored, payload)
+	}
+	if strings.Contains(stored.Password, "securepass") || bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("securepass")) != nil {
+		t.Errorf("expected a bcrypt hash of the password, got %q", stored.Password)
 	}
 }
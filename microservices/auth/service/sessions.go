@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/rbac"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/natsrpc"
+	"log"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errInvalidRefreshToken and the constructors below return a fresh value on
+// every call because Wrap mutates its receiver.
+func errInvalidRefreshToken() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, "Invalid refresh token")
+}
+
+func errRefreshTokenReused() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, "Refresh token was already used; the session has been revoked")
+}
+
+func errInvalidAccessToken() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, "Invalid or missing access token")
+}
+
+// startSession opens a new token family for user and issues its first
+// access/refresh token pair.
+func (s *Service) startSession(ctx context.Context, user *models.User, device string) (*models.TokenResponse, error) {
+	now := s.now()
+	return s.issueTokens(ctx, user, &models.RefreshToken{
+		FamilyID:         primitive.NewObjectID().Hex(),
+		UserID:           user.ID,
+		Device:           device,
+		SessionStartedAt: now,
+	})
+}
+
+// issueTokens stores next with a fresh opaque token and signs an access
+// token bound to its family.
+func (s *Service) issueTokens(ctx context.Context, user *models.User, next *models.RefreshToken) (*models.TokenResponse, error) {
+	refresh, err := token.NewOpaque()
+	if err != nil {
+		return nil, natsrpc.Internal("Failed to create token").Wrap(err)
+	}
+
+	now := s.now()
+	next.TokenHash = token.Hash(refresh)
+	next.CreatedAt = now
+	next.ExpiresAt = now.Add(s.tokens.RefreshTTL())
+	if err := s.sessions.CreateRefreshToken(ctx, next); err != nil {
+		return nil, natsrpc.NewError(natsrpc.CodeUnavailable, "Couldn't store the session").WithRetryable(true).Wrap(err)
+	}
+
+	access, err := s.tokens.Issue(token.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID.Hex()},
+		Email:            user.Email,
+		SessionID:        next.FamilyID,
+		Roles:            rbac.Roles(user.Roles),
+		Scope:            strings.Join(rbac.Permissions(user.Roles, user.Permissions), " "),
+	})
+	if err != nil {
+		return nil, natsrpc.Internal("Failed to create token").Wrap(err)
+	}
+
+	return &models.TokenResponse{
+		CustomeResponse: models.CustomeResponse{Msg: access, Context: true},
+		RefreshToken:    refresh,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(s.tokens.AccessTTL().Seconds()),
+	}, nil
+}
+
+// RefreshToken spends a refresh token and returns a new pair. Presenting a
+// token that was already spent means it leaked, so the whole family is
+// revoked and every device holding it has to log in again.
+func (s *Service) RefreshToken(ctx context.Context, body models.RefreshTokenBody) (*models.TokenResponse, error) {
+	if body.RefreshToken == "" {
+		return nil, natsrpc.Validation("refresh_token is required")
+	}
+
+	stored, err := s.sessions.FindRefreshToken(ctx, token.Hash(body.RefreshToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidRefreshToken()
+	}
+	if err != nil {
+		return nil, errReadSession(err)
+	}
+
+	now := s.now()
+	switch {
+	case stored.RevokedAt != nil:
+		return nil, errInvalidRefreshToken()
+	case stored.RotatedAt != nil:
+		return nil, s.revokeReusedFamily(ctx, stored)
+	case !now.Before(stored.ExpiresAt):
+		return nil, errInvalidRefreshToken()
+	}
+
+	rotated, err := s.sessions.MarkRotated(ctx, stored.ID, now)
+	if err != nil {
+		return nil, errReadSession(err)
+	}
+	if !rotated {
+		// A concurrent refresh spent the token between our read and write.
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+
+	user, err := s.repository.FindUserByID(ctx, stored.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errInvalidRefreshToken()
+	}
+	if err != nil {
+		return nil, errReadUser(err)
+	}
+	// Disabling the account or forcing a reset revokes its sessions; this
+	// catches a refresh racing with that.
+	if loginRefused(user) != nil {
+		return nil, errInvalidRefreshToken()
+	}
+
+	return s.issueTokens(ctx, user, &models.RefreshToken{
+		FamilyID:         stored.FamilyID,
+		UserID:           stored.UserID,
+		Device:           stored.Device,
+		SessionStartedAt: stored.SessionStartedAt,
+	})
+}
+
+// revokeReusedFamily revokes the family's refresh tokens and the access
+// tokens already issued from them, which the thief may hold too.
+func (s *Service) revokeReusedFamily(ctx context.Context, stored *models.RefreshToken) error {
+	log.Printf("refresh token reuse detected for user %s, revoking session %s", stored.UserID.Hex(), stored.FamilyID)
+	if err := s.sessions.RevokeFamily(ctx, stored.FamilyID, s.now()); err != nil {
+		return errReadSession(err)
+	}
+	if err := s.revocations.RevokeSession(ctx, stored.UserID.Hex(), stored.FamilyID, s.tokens.AccessTTL()); err != nil {
+		return errRevoke(err)
+	}
+	return errRefreshTokenReused()
+}
+
+// ListSessions lists the devices signed in as the access token's user.
+func (s *Service) ListSessions(ctx context.Context, accessToken string) ([]models.Session, error) {
+	claims, userID, err := s.authenticate(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.sessions.ListActive(ctx, userID, s.now())
+	if err != nil {
+		return nil, errReadSession(err)
+	}
+
+	sessions := make([]models.Session, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, models.Session{
+			ID:         t.FamilyID,
+			Device:     t.Device,
+			CreatedAt:  t.SessionStartedAt,
+			LastUsedAt: t.CreatedAt,
+			ExpiresAt:  t.ExpiresAt,
+			Current:    t.FamilyID == claims.SessionID,
+		})
+	}
+	return sessions, nil
+}
+
+// Logout ends the access token's session: its access tokens are revoked at
+// once and its refresh token can no longer be used.
+func (s *Service) Logout(ctx context.Context, accessToken string) (*models.CustomeResponse, error) {
+	claims, _, err := s.authenticate(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revocations.RevokeToken(ctx, claims); err != nil {
+		return nil, errRevoke(err)
+	}
+	if claims.SessionID != "" {
+		if err := s.sessions.RevokeFamily(ctx, claims.SessionID, s.now()); err != nil {
+			return nil, errRevoke(err)
+		}
+		if err := s.revocations.RevokeSession(ctx, claims.Subject, claims.SessionID, s.tokens.AccessTTL()); err != nil {
+			return nil, errRevoke(err)
+		}
+	}
+
+	return &models.CustomeResponse{Msg: "Logged out", Context: true}, nil
+}
+
+// RevokeAllSessions signs the user out everywhere, including the session
+// making the request.
+func (s *Service) RevokeAllSessions(ctx context.Context, accessToken string) (*models.CustomeResponse, error) {
+	_, userID, err := s.authenticate(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.signOutEverywhere(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return &models.CustomeResponse{Msg: "Revoked all sessions", Context: true}, nil
+}
+
+// signOutEverywhere revokes every session of the user and every access
+// token issued to them so far. The user-wide revocation only reaches tokens
+// from before the current second, so each live session is revoked too.
+func (s *Service) signOutEverywhere(ctx context.Context, userID primitive.ObjectID) error {
+	now := s.now()
+	live, err := s.sessions.ListActive(ctx, userID, now)
+	if err != nil {
+		return errReadSession(err)
+	}
+	if err := s.sessions.RevokeUserSessions(ctx, userID, now); err != nil {
+		return errRevoke(err)
+	}
+	for _, t := range live {
+		if err := s.revocations.RevokeSession(ctx, userID.Hex(), t.FamilyID, s.tokens.AccessTTL()); err != nil {
+			return errRevoke(err)
+		}
+	}
+	if err := s.revocations.RevokeUser(ctx, userID.Hex(), s.tokens.AccessTTL()); err != nil {
+		return errRevoke(err)
+	}
+	return nil
+}
+
+// ValidateToken introspects an access token so other services can authorize
+// calls without holding the signing secret. Bad, expired and revoked tokens
+// are not errors; they come back as inactive.
+func (s *Service) ValidateToken(_ context.Context, body models.ValidateTokenBody) (*models.TokenValidation, error) {
+	if body.Token == "" {
+		return nil, natsrpc.Validation("token is required")
+	}
+
+	claims, err := s.verify(body.Token)
+	if err != nil {
+		return &models.TokenValidation{Active: false}, nil
+	}
+
+	exp := claims.ExpiresAt.Time
+	return &models.TokenValidation{
+		Active:    true,
+		UserID:    claims.Subject,
+		Email:     claims.Email,
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+		Roles:     claims.Roles,
+		Scopes:    claims.Scopes(),
+		ExpiresAt: &exp,
+	}, nil
+}
+
+// JWKS publishes the public keys access tokens are verified with.
+func (s *Service) JWKS(_ context.Context) (*models.JWKS, error) {
+	return s.tokens.PublicKeys(), nil
+}
+
+// verify checks an access token's signature, expiry and revocation. Tokens
+// that passed the signature check are cached until they expire; revocation
+// is in memory and checked every time.
+func (s *Service) verify(accessToken string) (*token.Claims, error) {
+	key := token.Hash(accessToken)
+	claims, ok := s.verified.Get(key)
+	if ok && !s.now().Before(claims.ExpiresAt.Time) {
+		s.verified.Remove(key)
+		ok = false
+	}
+	if !ok {
+		var err error
+		if claims, err = s.tokens.Parse(accessToken); err != nil {
+			return nil, err
+		}
+		s.verified.Add(key, claims)
+	}
+
+	if s.revocations.IsRevoked(claims) {
+		return nil, errors.New("token has been revoked")
+	}
+	return claims, nil
+}
+
+// authenticate verifies an access token and returns its claims and user ID.
+func (s *Service) authenticate(accessToken string) (*token.Claims, primitive.ObjectID, error) {
+	if accessToken == "" {
+		return nil, primitive.NilObjectID, errInvalidAccessToken()
+	}
+
+	claims, err := s.verify(accessToken)
+	if err != nil {
+		return nil, primitive.NilObjectID, errInvalidAccessToken().Wrap(err)
+	}
+
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return nil, primitive.NilObjectID, errInvalidAccessToken().Wrap(err)
+	}
+	return claims, userID, nil
+}
+
+func errRevoke(err error) error {
+	return natsrpc.NewError(natsrpc.CodeUnavailable, "Couldn't revoke the session").WithRetryable(true).Wrap(err)
+}
+
+func errReadSession(err error) error {
+	return natsrpc.NewError(natsrpc.CodeUnavailable, "Couldn't read the session").WithRetryable(true).Wrap(err)
+}
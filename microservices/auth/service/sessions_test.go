@@ -0,0 +1,353 @@
+package service_test
+
+import (
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/revocation"
+	"iLeon/microservices/auth/service"
+	"iLeon/microservices/auth/token"
+	"iLeon/microservices/natsrpc"
+	"testing"
+	"time"
+)
+
+type sessionFixture struct {
+	svc      service.AuthService
+	users    *mockAuthRepo
+	sessions *memSessions
+	outbox   *memOutbox
+	audit    *memAudit
+	clock    *clock
+	user     *models.User
+}
+
+// newSessionFixture builds the service on the fixture's stores and clock;
+// opts adjust its dependencies before it is built.
+func newSessionFixture(t *testing.T, opts ...func(*service.Dependencies)) *sessionFixture {
+	f := &sessionFixture{
+		sessions: &memSessions{},
+		outbox:   &memOutbox{},
+		audit:    &memAudit{},
+		clock:    newClock(),
+		user:     newUser(t, "user@test.com", "pass"),
+	}
+	f.users = usersRepo(f.user)
+	wire := func(d *service.Dependencies) {
+		d.Sessions = f.sessions
+		d.Revocations = newRevocations(revocation.WithClock(f.clock.Now))
+		d.Tokens = newTokens(token.WithClock(f.clock.Now))
+		d.Clock = f.clock.Now
+		d.Audit = f.audit
+		d.Outbox = f.outbox
+		d.ValidationCacheSize = 16
+	}
+	f.svc = newService(f.users, append([]func(*service.Dependencies){wire}, opts...)...)
+	return f
+}
+
+func (f *sessionFixture) login(t *testing.T, device string) *models.TokenResponse {
+	t.Helper()
+	res, err := f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "pass", Device: device})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	return res
+}
+
+func (f *sessionFixture) active(t *testing.T, accessToken string) bool {
+	t.Helper()
+	res, err := f.svc.ValidateToken(ctx, models.ValidateTokenBody{Token: accessToken})
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	return res.Active
+}
+
+func (f *sessionFixture) refresh(refreshToken string) (*models.TokenResponse, error) {
+	return f.svc.RefreshToken(ctx, models.RefreshTokenBody{RefreshToken: refreshToken})
+}
+
+// ── RefreshToken tests ───────────────────────────────────────────────────────
+
+func TestRefreshToken_RotatesWithinTheFamily(t *testing.T) {
+	f := newSessionFixture(t)
+	first := f.login(t, "laptop")
+
+	second, err := f.refresh(first.RefreshToken)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.Msg == "" {
+		t.Errorf("expected a new token pair, got %+v", second)
+	}
+	old, _ := f.sessions.FindRefreshToken(ctx, token.Hash(first.RefreshToken))
+	next, _ := f.sessions.FindRefreshToken(ctx, token.Hash(second.RefreshToken))
+	if old.RotatedAt == nil {
+		t.Errorf("expected the spent token to be marked rotated")
+	}
+	if next.FamilyID != old.FamilyID || next.Device != "laptop" {
+		t.Errorf("expected the successor to stay in the family, got %+v", next)
+	}
+}
+
+func TestRefreshToken_ReuseRevokesTheFamily(t *testing.T) {
+	f := newSessionFixture(t)
+	first := f.login(t, "laptop")
+	second, _ := f.refresh(first.RefreshToken)
+
+	_, err := f.refresh(first.RefreshToken)
+
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Fatalf("expected UNAUTHENTICATED on reuse, got %v", err)
+	}
+	if _, err := f.refresh(second.RefreshToken); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the legitimate successor to be revoked too, got %v", err)
+	}
+	if f.active(t, first.Msg) || f.active(t, second.Msg) {
+		t.Errorf("expected the family's access tokens to be revoked")
+	}
+}
+
+func TestRefreshToken_ReuseLeavesOtherDevicesAlone(t *testing.T) {
+	f := newSessionFixture(t)
+	laptop := f.login(t, "laptop")
+	phone := f.login(t, "phone")
+	f.refresh(laptop.RefreshToken)
+
+	f.refresh(laptop.RefreshToken)
+
+	if _, err := f.refresh(phone.RefreshToken); err != nil {
+		t.Errorf("expected the other device's session to survive, got %v", err)
+	}
+}
+
+func TestRefreshToken_LostRaceCountsAsReuse(t *testing.T) {
+	f := newSessionFixture(t)
+	first := f.login(t, "laptop")
+	f.sessions.loseRace = true
+
+	_, err := f.refresh(first.RefreshToken)
+
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Fatalf("expected UNAUTHENTICATED, got %v", err)
+	}
+	if stored, _ := f.sessions.FindRefreshToken(ctx, token.Hash(first.RefreshToken)); stored.RevokedAt == nil {
+		t.Errorf("expected the family to be revoked")
+	}
+}
+
+func TestRefreshToken_RejectsExpiredToken(t *testing.T) {
+	f := newSessionFixture(t)
+	first := f.login(t, "laptop")
+	f.clock.Advance(25 * time.Hour)
+
+	_, err := f.refresh(first.RefreshToken)
+
+	if rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected UNAUTHENTICATED, got %v", err)
+	}
+}
+
+func TestRefreshToken_RejectsUnknownAndMissingTokens(t *testing.T) {
+	f := newSessionFixture(t)
+
+	if _, err := f.refresh("not-a-token"); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected UNAUTHENTICATED for an unknown token, got %v", err)
+	}
+	if _, err := f.refresh(""); rpcCode(err) != natsrpc.CodeValidation {
+		t.Errorf("expected VALIDATION for a missing token, got %v", err)
+	}
+}
+
+// ── ListSessions tests ───────────────────────────────────────────────────────
+
+func TestListSessions_OnePerDeviceWithCurrentMarked(t *testing.T) {
+	f := newSessionFixture(t)
+	laptop := f.login(t, "laptop")
+	phone := f.login(t, "phone")
+	f.refresh(laptop.RefreshToken)
+
+	sessions, err := f.svc.ListSessions(ctx, phone.Msg)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+	for _, s := range sessions {
+		if s.Current != (s.Device == "phone") {
+			t.Errorf("expected only the phone session to be current, got %+v", s)
+		}
+	}
+}
+
+func TestListSessions_RequiresAccessToken(t *testing.T) {
+	f := newSessionFixture(t)
+
+	for _, accessToken := range []string{"", "garbage"} {
+		if _, err := f.svc.ListSessions(ctx, accessToken); rpcCode(err) != natsrpc.CodeUnauthenticated {
+			t.Errorf("%q: expected UNAUTHENTICATED, got %v", accessToken, err)
+		}
+	}
+}
+
+// ── Logout / RevokeAllSessions tests ────────────────────────────────────────
+
+func TestLogout_RevokesAccessAndRefreshToken(t *testing.T) {
+	f := newSessionFixture(t)
+	laptop := f.login(t, "laptop")
+	phone := f.login(t, "phone")
+
+	if _, err := f.svc.Logout(ctx, laptop.Msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if f.active(t, laptop.Msg) {
+		t.Errorf("expected the access token to be revoked immediately")
+	}
+	if _, err := f.refresh(laptop.RefreshToken); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected the refresh token to be revoked, got %v", err)
+	}
+	if !f.active(t, phone.Msg) {
+		t.Errorf("expected the other device to stay signed in")
+	}
+}
+
+func TestLogout_RevokesEarlierAccessTokensOfTheSession(t *testing.T) {
+	f := newSessionFixture(t)
+	first := f.login(t, "laptop")
+	second, err := f.refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	if _, err := f.svc.Logout(ctx, second.Msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if f.active(t, first.Msg) {
+		t.Errorf("expected the session's earlier access token to be revoked")
+	}
+}
+
+func TestLogout_RejectsRevokedToken(t *testing.T) {
+	f := newSessionFixture(t)
+	laptop := f.login(t, "laptop")
+	f.svc.Logout(ctx, laptop.Msg)
+
+	if _, err := f.svc.Logout(ctx, laptop.Msg); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected UNAUTHENTICATED, got %v", err)
+	}
+	if _, err := f.svc.ListSessions(ctx, laptop.Msg); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected a revoked token to be refused everywhere, got %v", err)
+	}
+}
+
+func TestRevokeAllSessions_SignsOutEveryDevice(t *testing.T) {
+	f := newSessionFixture(t)
+	laptop := f.login(t, "laptop")
+	phone := f.login(t, "phone")
+
+	if _, err := f.svc.RevokeAllSessions(ctx, laptop.Msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, pair := range map[string]*models.TokenResponse{"laptop": laptop, "phone": phone} {
+		if f.active(t, pair.Msg) {
+			t.Errorf("%s: expected the access token to be revoked", name)
+		}
+		if _, err := f.refresh(pair.RefreshToken); rpcCode(err) != natsrpc.CodeUnauthenticated {
+			t.Errorf("%s: expected the refresh token to be revoked, got %v", name, err)
+		}
+	}
+}
+
+func TestRevokeAllSessions_LoginInTheSameSecondStaysValid(t *testing.T) {
+	f := newSessionFixture(t)
+	old := f.login(t, "laptop")
+	if _, err := f.svc.RevokeAllSessions(ctx, old.Msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fresh := f.login(t, "laptop")
+
+	if !f.active(t, fresh.Msg) {
+		t.Errorf("expected the new session's access token to be valid")
+	}
+	if _, err := f.refresh(fresh.RefreshToken); err != nil {
+		t.Errorf("expected the new session to refresh, got %v", err)
+	}
+}
+
+// ── ValidateToken tests ──────────────────────────────────────────────────────
+
+func TestValidateToken_ActiveTokenClaims(t *testing.T) {
+	f := newSessionFixture(t)
+	pair := f.login(t, "laptop")
+
+	res, err := f.svc.ValidateToken(ctx, models.ValidateTokenBody{Token: pair.Msg})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.Active || res.UserID != f.user.ID.Hex() || res.Email != f.user.Email || res.TokenID == "" || res.ExpiresAt == nil {
+		t.Errorf("unexpected validation: %+v", res)
+	}
+}
+
+func TestValidateToken_InactiveAndMissingTokens(t *testing.T) {
+	f := newSessionFixture(t)
+	otherKey, _ := token.GenerateKey("test", token.ES256)
+	foreign, _ := token.NewManager(token.StaticKeySet{otherKey}, time.Minute, time.Hour).Issue(token.Claims{})
+
+	for _, tok := range []string{"garbage", foreign} {
+		if f.active(t, tok) {
+			t.Errorf("%q: expected inactive", tok)
+		}
+	}
+	if _, err := f.svc.ValidateToken(ctx, models.ValidateTokenBody{}); rpcCode(err) != natsrpc.CodeValidation {
+		t.Errorf("expected VALIDATION for a missing token, got %v", err)
+	}
+}
+
+func TestValidateToken_CachedTokenStillChecksRevocation(t *testing.T) {
+	f := newSessionFixture(t)
+	pair := f.login(t, "laptop")
+	if !f.active(t, pair.Msg) {
+		t.Fatal("expected a fresh token to be active")
+	}
+
+	f.svc.Logout(ctx, pair.Msg)
+
+	if f.active(t, pair.Msg) {
+		t.Errorf("expected a cached token to turn inactive once revoked")
+	}
+}
+
+func TestValidateToken_CachedTokenExpires(t *testing.T) {
+	f := newSessionFixture(t)
+	pair := f.login(t, "laptop")
+	f.active(t, pair.Msg)
+
+	f.clock.Advance(16 * time.Minute)
+
+	if f.active(t, pair.Msg) {
+		t.Errorf("expected a cached token to turn inactive once expired")
+	}
+}
+
+// ── JWKS tests ───────────────────────────────────────────────────────────────
+
+func TestJWKS_PublishesTheVerificationKeys(t *testing.T) {
+	f := newSessionFixture(t)
+
+	jwks, err := f.svc.JWKS(ctx)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "test" || jwks.Keys[0].KeyType != "EC" {
+		t.Errorf("unexpected key set: %+v", jwks)
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/validate"
+)
+
+// validRegistration checks a registration against the username rules and
+// the password policy, and returns it with the username trimmed and the
+// email normalized.
+func (s *Service) validRegistration(body models.CreateUserBody) (models.CreateUserBody, error) {
+	var errs validate.Errors
+	var err error
+	body.Username, err = validate.Username(body.Username)
+	errs.Check("username", err)
+	body.Email, err = validate.Email(body.Email)
+	errs.Check("email", err)
+	errs.Check("password", s.policy.Check(body.Password))
+	return body, errs.Err()
+}
+
+// validLogin checks that a login has an email and password, and normalizes
+// the email. The password isn't held to the policy, which may have changed
+// since it was set.
+func validLogin(body models.LoginUserBody) (models.LoginUserBody, error) {
+	var errs validate.Errors
+	var err error
+	body.Email, err = validate.Email(body.Email)
+	errs.Check("email", err)
+	if body.Password == "" {
+		errs.Add("password", "Password is required")
+	}
+	return body, errs.Err()
+}
+
+// validEmail checks the email of requests that carry only that.
+func validEmail(email string) (string, error) {
+	var errs validate.Errors
+	email, err := validate.Email(email)
+	errs.Check("email", err)
+	return email, errs.Err()
+}
+
+// validPassword checks a new password in field against the policy.
+func (s *Service) validPassword(field, password string) error {
+	var errs validate.Errors
+	errs.Check(field, s.policy.Check(password))
+	return errs.Err()
+}
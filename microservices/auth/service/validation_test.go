@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/password"
+	"iLeon/microservices/auth/service"
+	"iLeon/microservices/auth/validate"
+	"iLeon/microservices/natsrpc"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fieldErrors returns the fields a VALIDATION error complains about.
+func fieldErrors(t *testing.T, err error) []string {
+	t.Helper()
+	rpcErr, ok := err.(*natsrpc.Error)
+	if !ok || rpcErr.Code != natsrpc.CodeValidation {
+		t.Fatalf("expected a VALIDATION error, got %v", err)
+	}
+	details, _ := rpcErr.Details.(map[string][]validate.FieldError)
+	var fields []string
+	for _, f := range details["fields"] {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+// ── RegisterUser validation tests ────────────────────────────────────────────
+
+func TestRegisterUser_ReportsEveryInvalidField(t *testing.T) {
+	repo := usersRepo()
+	repo.createFn = func(*models.User) error {
+		t.Fatal("expected nothing to be stored")
+		return nil
+	}
+
+	_, err := newService(repo).RegisterUser(ctx, models.CreateUserBody{Username: "x", Email: "not-an-email", Password: "1"})
+
+	if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, []string{"username", "email", "password"}) {
+		t.Errorf("expected username, email and password to be reported, got %v", fields)
+	}
+}
+
+func TestRegisterUser_NormalizesEmail(t *testing.T) {
+	repo := usersRepo()
+	var stored *models.User
+	repo.createFn = func(user *models.User) error {
+		user.ID = primitive.NewObjectID()
+		stored = user
+		return nil
+	}
+
+	_, err := newService(repo).RegisterUser(ctx, models.CreateUserBody{Username: " alice ", Email: "  Alice@Test.COM ", Password: "securepass"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Email != "alice@test.com" || stored.Username != "alice" {
+		t.Errorf("expected a normalized user, got %q / %q", stored.Username, stored.Email)
+	}
+}
+
+func TestRegisterUser_AppliesConfiguredPolicy(t *testing.T) {
+	list, _ := password.ReadDenylist(strings.NewReader("Password123\n"))
+	svc := newService(usersRepo(), func(d *service.Dependencies) {
+		d.PasswordPolicy = &password.Policy{MinLength: 8, MaxLength: 72, MinClasses: 3, Denylist: list}
+	})
+
+	for _, pw := range []string{"password123", "Password123"} {
+		_, err := svc.RegisterUser(ctx, models.CreateUserBody{Username: "alice", Email: "alice@test.com", Password: pw})
+		if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, []string{"password"}) {
+			t.Errorf("%q: expected the password to be refused, got %v", pw, fields)
+		}
+	}
+}
+
+// ── LoginUser validation tests ───────────────────────────────────────────────
+
+func TestLoginUser_RequiresEmailAndPassword(t *testing.T) {
+	repo := usersRepo()
+	repo.findByEmailFn = func(string) (*models.User, error) {
+		t.Fatal("expected no lookup")
+		return nil, nil
+	}
+
+	_, err := newService(repo).LoginUser(ctx, models.LoginUserBody{Email: "bob@"})
+
+	if fields := fieldErrors(t, err); !reflect.DeepEqual(fields, []string{"email", "password"}) {
+		t.Errorf("expected email and password to be reported, got %v", fields)
+	}
+}
+
+func TestLoginUser_NormalizesEmail(t *testing.T) {
+	svc := newService(usersRepo(newUser(t, "user@test.com", "pass")))
+
+	if _, err := svc.LoginUser(ctx, models.LoginUserBody{Email: " USER@test.com", Password: "pass"}); err != nil {
+		t.Errorf("expected the email to match case-insensitively, got %v", err)
+	}
+}
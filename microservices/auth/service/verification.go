@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/repository"
+	"iLeon/microservices/natsrpc"
+)
+
+// verificationSent is the answer to every resend request, so it doesn't
+// reveal which emails are registered.
+const verificationSent = "If the email is registered and not verified yet, a verification link has been sent"
+
+func errInvalidVerificationToken() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeUnauthenticated, "Invalid or expired verification token")
+}
+
+func errEmailNotVerified() *natsrpc.Error {
+	return natsrpc.NewError(natsrpc.CodeForbidden, "Please verify your email address before logging in").
+		WithDetails(map[string]string{"reason": "email_unverified"})
+}
+
+func (s *Service) sendVerification(ctx context.Context, user *models.User) error {
+	return s.mailToken(ctx, user, user.Email, models.PurposeEmailVerification, s.verifyTTL, models.EventEmailVerificationRequested)
+}
+
+// VerifyEmail spends a verification token and marks its user's email as
+// verified.
+func (s *Service) VerifyEmail(ctx context.Context, body models.VerifyEmailBody) (*models.CustomeResponse, error) {
+	user, err := s.spendToken(ctx, body.Token, models.PurposeEmailVerification, errInvalidVerificationToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repository.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, errUpdateUser(err)
+	}
+	return &models.CustomeResponse{Msg: "Your email address has been verified", Context: true}, nil
+}
+
+// ResendVerification mails a new verification token to an unverified user.
+// Earlier tokens stop working.
+func (s *Service) ResendVerification(ctx context.Context, body models.ResendVerificationBody) (*models.CustomeResponse, error) {
+	email, err := validEmail(body.Email)
+	if err != nil {
+		return nil, err
+	}
+	done := &models.CustomeResponse{Msg: verificationSent, Context: true}
+
+	user, err := s.repository.FindUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && user.EmailVerified) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, errReadUser(err)
+	}
+
+	if err := s.sendVerification(ctx, user); err != nil {
+		return nil, err
+	}
+	return done, nil
+}
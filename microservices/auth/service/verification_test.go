@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"iLeon/microservices/auth/models"
+	"iLeon/microservices/auth/service"
+	"iLeon/microservices/natsrpc"
+	"testing"
+)
+
+// newVerifyingFixture is a session fixture whose service refuses logins
+// with unverified emails.
+func newVerifyingFixture(t *testing.T) *sessionFixture {
+	return newSessionFixture(t, func(d *service.Dependencies) {
+		d.RequireVerifiedEmail = true
+	})
+}
+
+func (f *sessionFixture) resendVerification(t *testing.T) string {
+	t.Helper()
+	if _, err := f.svc.ResendVerification(ctx, models.ResendVerificationBody{Email: f.user.Email}); err != nil {
+		t.Fatalf("resend failed: %v", err)
+	}
+	mails := f.mailed(t, models.EventEmailVerificationRequested)
+	if len(mails) != 1 {
+		t.Fatalf("expected one verification email, got %d", len(mails))
+	}
+	return mails[0].Token
+}
+
+// ── RegisterUser tests ───────────────────────────────────────────────────────
+
+func TestRegisterUser_CreatesUnverifiedUserAndMailsToken(t *testing.T) {
+	f := newSessionFixture(t)
+	var created *models.User
+	f.users.createFn = func(user *models.User) error {
+		created = user
+		return nil
+	}
+
+	f.svc.RegisterUser(ctx, models.CreateUserBody{Username: "user", Email: "new@test.com", Password: "correct horse"})
+
+	if created == nil || created.EmailVerified {
+		t.Fatalf("expected an unverified user, got %+v", created)
+	}
+	mails := f.mailed(t, models.EventEmailVerificationRequested)
+	if len(mails) != 1 || mails[0].Email != "new@test.com" || mails[0].Token == "" {
+		t.Errorf("expected a verification email, got %+v", mails)
+	}
+}
+
+// ── VerifyEmail tests ────────────────────────────────────────────────────────
+
+func TestVerifyEmail_MarksUserVerified(t *testing.T) {
+	f := newSessionFixture(t)
+	verification := f.resendVerification(t)
+
+	if _, err := f.svc.VerifyEmail(ctx, models.VerifyEmailBody{Token: verification}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !f.user.EmailVerified {
+		t.Errorf("expected the email to be verified")
+	}
+	if _, err := f.svc.VerifyEmail(ctx, models.VerifyEmailBody{Token: verification}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected a spent token to be refused, got %v", err)
+	}
+}
+
+func TestVerifyEmail_RejectsOtherPurposes(t *testing.T) {
+	f := newSessionFixture(t)
+	f.svc.RequestPasswordReset(ctx, models.PasswordResetRequestBody{Email: f.user.Email})
+	reset := f.mailed(t, models.EventPasswordResetRequested)[0].Token
+
+	_, err := f.svc.VerifyEmail(ctx, models.VerifyEmailBody{Token: reset})
+
+	if rpcCode(err) != natsrpc.CodeUnauthenticated || f.user.EmailVerified {
+		t.Errorf("expected a reset token not to verify the email, got %v", err)
+	}
+}
+
+// ── ResendVerification tests ─────────────────────────────────────────────────
+
+func TestResendVerification_SkipsVerifiedAndUnknownUsers(t *testing.T) {
+	f := newSessionFixture(t)
+	f.user.EmailVerified = true
+
+	verified, _ := f.svc.ResendVerification(ctx, models.ResendVerificationBody{Email: f.user.Email})
+	unknown, err := f.svc.ResendVerification(ctx, models.ResendVerificationBody{Email: "ghost@test.com"})
+
+	if err != nil || *verified != *unknown {
+		t.Errorf("expected identical answers, got %+v and %+v, %v", verified, unknown, err)
+	}
+	if mails := f.mailed(t, models.EventEmailVerificationRequested); len(mails) != 0 {
+		t.Errorf("expected no emails, got %d", len(mails))
+	}
+}
+
+// ── Login with RequireVerifiedEmail ──────────────────────────────────────────
+
+func TestLoginUser_RequiresVerifiedEmailWhenConfigured(t *testing.T) {
+	f := newVerifyingFixture(t)
+	body := models.LoginUserBody{Email: f.user.Email, Password: "pass"}
+
+	if _, err := f.svc.LoginUser(ctx, body); rpcCode(err) != natsrpc.CodeForbidden {
+		t.Fatalf("expected FORBIDDEN before verification, got %v", err)
+	}
+	if _, err := f.svc.LoginUser(ctx, models.LoginUserBody{Email: f.user.Email, Password: "wrong"}); rpcCode(err) != natsrpc.CodeUnauthenticated {
+		t.Errorf("expected a wrong password to fail as usual, got %v", err)
+	}
+
+	f.svc.VerifyEmail(ctx, models.VerifyEmailBody{Token: f.resendVerification(t)})
+
+	if _, err := f.svc.LoginUser(ctx, body); err != nil {
+		t.Errorf("expected login after verification, got %v", err)
+	}
+}
+
+func TestLoginUser_AllowsUnverifiedEmailByDefault(t *testing.T) {
+	f := newSessionFixture(t)
+
+	f.login(t, "laptop")
+}
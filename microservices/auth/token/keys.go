@@ -0,0 +1,129 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"iLeon/microservices/auth/models"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// Key is one signing key pair, identified in token headers by its kid.
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	// NotBefore is when the key starts signing; it is published earlier so
+	// verifiers that cache the key set already know it.
+	NotBefore time.Time
+	// ExpiresAt is when the key is dropped from the key set, once every
+	// token it signed has expired.
+	ExpiresAt time.Time
+}
+
+// KeySet is where the Manager gets its keys from.
+type KeySet interface {
+	// Signing returns the key new tokens are signed with.
+	Signing() (*Key, error)
+	// Lookup returns the key named by a token's kid header.
+	Lookup(kid string) (*Key, bool)
+	// Published lists the keys verifiers should accept.
+	Published() []*Key
+}
+
+// GenerateKey creates a key pair for alg.
+func GenerateKey(id, alg string) (*Key, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch alg {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case ES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generating %s key: %w", alg, err)
+	}
+
+	return &Key{ID: id, Algorithm: alg, Private: private}, nil
+}
+
+// ValidAlgorithm reports whether alg is one of the supported algorithms.
+func ValidAlgorithm(alg string) bool {
+	return alg == RS256 || alg == ES256 || alg == EdDSA
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// JWK renders the public half of the key (RFC 7517).
+func (k *Key) JWK() models.JWK {
+	jwk := models.JWK{KeyID: k.ID, Algorithm: k.Algorithm, Use: "sig"}
+
+	switch pub := k.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(pub)
+	}
+	return jwk
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// StaticKeySet is a fixed KeySet signing with its first key.
+type StaticKeySet []*Key
+
+func (s StaticKeySet) Signing() (*Key, error) {
+	if len(s) == 0 {
+		return nil, fmt.Errorf("no signing key")
+	}
+	return s[0], nil
+}
+
+func (s StaticKeySet) Lookup(kid string) (*Key, bool) {
+	for _, k := range s {
+		if k.ID == kid {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func (s StaticKeySet) Published() []*Key { return s }
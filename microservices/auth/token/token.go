@@ -0,0 +1,144 @@
+// Package token issues and verifies the auth service's credentials: signed
+// JWT access tokens and opaque refresh tokens.
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iLeon/microservices/auth/models"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the access token claims. Subject is the user ID.
+type Claims struct {
+	jwt.RegisteredClaims
+	Email     string   `json:"email,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	// Scope is the space-separated list of granted scopes (RFC 9068).
+	Scope string `json:"scope,omitempty"`
+}
+
+// Scopes splits Scope into its entries.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+type Manager struct {
+	keys       KeySet
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+type Option func(*Manager)
+
+// WithClock replaces time.Now when stamping and checking token times.
+func WithClock(now func() time.Time) Option {
+	return func(m *Manager) { m.now = now }
+}
+
+func NewManager(keys KeySet, accessTTL, refreshTTL time.Duration, opts ...Option) *Manager {
+	m := &Manager{
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Manager) AccessTTL() time.Duration  { return m.accessTTL }
+func (m *Manager) RefreshTTL() time.Duration { return m.refreshTTL }
+
+// Issue signs an access token for claims with the current signing key,
+// stamping a fresh ID (jti) and the issue and expiry times.
+func (m *Manager) Issue(claims Claims) (string, error) {
+	key, err := m.keys.Signing()
+	if err != nil {
+		return "", fmt.Errorf("signing access token: %w", err)
+	}
+
+	id, err := NewOpaque()
+	if err != nil {
+		return "", fmt.Errorf("generating token ID: %w", err)
+	}
+
+	now := m.now()
+	claims.ID = id
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(m.accessTTL))
+
+	t := jwt.NewWithClaims(key.method(), claims)
+	t.Header["kid"] = key.ID
+	signed, err := t.SignedString(key.Private)
+	if err != nil {
+		return "", fmt.Errorf("signing access token: %w", err)
+	}
+	return signed, nil
+}
+
+// Parse verifies the signature and expiry of an access token against the
+// key named by its kid header. Every failure is reported as ErrInvalidToken
+// wrapping the cause.
+func (m *Manager) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, m.verificationKey,
+		jwt.WithValidMethods([]string{RS256, ES256, EdDSA}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(m.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (m *Manager) verificationKey(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, ok := m.keys.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if t.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("key %q is for %s, token uses %s", kid, key.Algorithm, t.Method.Alg())
+	}
+	return key.Private.Public(), nil
+}
+
+// PublicKeys returns the JSON Web Key Set verifiers should accept.
+func (m *Manager) PublicKeys() *models.JWKS {
+	set := &models.JWKS{Keys: []models.JWK{}}
+	for _, k := range m.keys.Published() {
+		set.Keys = append(set.Keys, k.JWK())
+	}
+	return set
+}
+
+// NewOpaque returns a random URL-safe token with 256 bits of entropy.
+func NewOpaque() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash is the form opaque tokens are stored and looked up in, so a database
+// leak doesn't hand out usable tokens.
+func Hash(opaque string) string {
+	sum := sha256.Sum256([]byte(opaque))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,155 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testKeys(t *testing.T, alg string, ids ...string) StaticKeySet {
+	t.Helper()
+	if len(ids) == 0 {
+		ids = []string{"k1"}
+	}
+	var set StaticKeySet
+	for _, id := range ids {
+		k, err := GenerateKey(id, alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set = append(set, k)
+	}
+	return set
+}
+
+func TestIssueAndParse_RoundTrip(t *testing.T) {
+	for _, alg := range []string{RS256, ES256, EdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			m := NewManager(testKeys(t, alg), time.Minute, time.Hour)
+
+			signed, err := m.Issue(Claims{
+				RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"},
+				Email:            "a@b.com",
+				SessionID:        "s-1",
+				Roles:            []string{"admin"},
+				Scope:            "customers:read customers:write",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := m.Parse(signed)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if claims.Subject != "user-1" || claims.Email != "a@b.com" || claims.SessionID != "s-1" {
+				t.Errorf("unexpected claims: %+v", claims)
+			}
+			if len(claims.Roles) != 1 || len(claims.Scopes()) != 2 {
+				t.Errorf("expected roles and scopes to survive, got %v %v", claims.Roles, claims.Scopes())
+			}
+			if claims.ID == "" {
+				t.Errorf("expected a jti claim")
+			}
+			if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != time.Minute {
+				t.Errorf("expected a one minute lifetime, got %v", got)
+			}
+		})
+	}
+}
+
+func TestIssue_SetsKidHeader(t *testing.T) {
+	m := NewManager(testKeys(t, ES256, "2024-01"), time.Minute, time.Hour)
+	signed, _ := m.Issue(Claims{})
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(signed, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "2024-01" || parsed.Header["alg"] != ES256 {
+		t.Errorf("unexpected header: %v", parsed.Header)
+	}
+}
+
+func TestParse_VerifiesWithOlderPublishedKey(t *testing.T) {
+	keys := testKeys(t, ES256, "new", "old")
+	signed, _ := NewManager(keys[1:], time.Minute, time.Hour).Issue(Claims{})
+
+	if _, err := NewManager(keys, time.Minute, time.Hour).Parse(signed); err != nil {
+		t.Errorf("expected a token from a retiring key to verify, got %v", err)
+	}
+}
+
+func TestParse_RejectsExpiredToken(t *testing.T) {
+	m := NewManager(testKeys(t, ES256), time.Minute, time.Hour)
+	signed, _ := m.Issue(Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u"}})
+
+	m.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, err := m.Parse(signed)
+
+	if !errors.Is(err, ErrInvalidToken) || !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("expected an expired token error, got %v", err)
+	}
+}
+
+func TestParse_RejectsUnknownKeyAndForeignSignature(t *testing.T) {
+	m := NewManager(testKeys(t, ES256), time.Minute, time.Hour)
+
+	unknown, _ := NewManager(testKeys(t, ES256, "other"), time.Minute, time.Hour).Issue(Claims{})
+	forged, _ := NewManager(testKeys(t, ES256), time.Minute, time.Hour).Issue(Claims{})
+
+	for name, signed := range map[string]string{"unknown kid": unknown, "same kid, other key": forged} {
+		if _, err := m.Parse(signed); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestParse_RejectsHMACTokens(t *testing.T) {
+	m := NewManager(testKeys(t, ES256), time.Minute, time.Hour)
+	t1 := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{})
+	t1.Header["kid"] = "k1"
+	signed, _ := t1.SignedString([]byte("guessable"))
+
+	if _, err := m.Parse(signed); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected an HS256 token to be rejected, got %v", err)
+	}
+}
+
+func TestPublicKeys_JWKForms(t *testing.T) {
+	cases := map[string]func(kty, crv, n, e, x, y string) bool{
+		RS256: func(kty, crv, n, e, x, y string) bool { return kty == "RSA" && n != "" && e == "AQAB" },
+		ES256: func(kty, crv, n, e, x, y string) bool {
+			return kty == "EC" && crv == "P-256" && len(x) == 43 && len(y) == 43
+		},
+		EdDSA: func(kty, crv, n, e, x, y string) bool { return kty == "OKP" && crv == "Ed25519" && len(x) == 43 },
+	}
+	for alg, ok := range cases {
+		jwks := NewManager(testKeys(t, alg), time.Minute, time.Hour).PublicKeys()
+
+		k := jwks.Keys[0]
+		if k.KeyID != "k1" || k.Algorithm != alg || k.Use != "sig" || !ok(k.KeyType, k.Curve, k.N, k.E, k.X, k.Y) {
+			t.Errorf("%s: unexpected JWK %+v", alg, k)
+		}
+	}
+}
+
+func TestGenerateKey_RejectsUnknownAlgorithm(t *testing.T) {
+	if _, err := GenerateKey("k", "HS256"); err == nil {
+		t.Errorf("expected HS256 to be refused")
+	}
+}
+
+func TestNewOpaque_IsRandomAndHashStable(t *testing.T) {
+	a, _ := NewOpaque()
+	b, _ := NewOpaque()
+
+	if a == b || len(a) != 43 {
+		t.Errorf("expected distinct 43 character tokens, got %q and %q", a, b)
+	}
+	if Hash(a) != Hash(a) || Hash(a) == Hash(b) {
+		t.Errorf("expected a stable, collision-free hash")
+	}
+}
@@ -0,0 +1,132 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as
+// used by authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Algorithms for the HMAC. Authenticator apps widely support only SHA1.
+const (
+	SHA1   = "SHA1"
+	SHA256 = "SHA256"
+	SHA512 = "SHA512"
+)
+
+// DefaultIssuer names the service in authenticator apps.
+const DefaultIssuer = "iLeon"
+
+// SecretSize is the length of generated secrets in bytes, the HMAC-SHA1
+// output size recommended by RFC 4226.
+const SecretSize = 20
+
+// Params describe how codes are derived from the secret.
+type Params struct {
+	Algorithm string
+	Digits    int
+	Period    time.Duration
+	// Skew is the number of periods before and after the current one whose
+	// codes are also accepted, to allow for clock drift.
+	Skew int
+}
+
+var DefaultParams = Params{Algorithm: SHA1, Digits: 6, Period: 30 * time.Second, Skew: 1}
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random secret.
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeSecret renders secret the way users type it into an app.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// Step returns the counter of the period t falls in.
+func (p Params) Step(t time.Time) int64 {
+	return t.Unix() / int64(p.Period/time.Second)
+}
+
+// Code returns the code for the period t falls in.
+func (p Params) Code(secret []byte, t time.Time) string {
+	return p.codeAt(secret, p.Step(t))
+}
+
+// codeAt is the HOTP value (RFC 4226) for counter.
+func (p Params) codeAt(secret []byte, counter int64) string {
+	mac := hmac.New(p.hash(), secret)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < p.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", p.Digits, bin%mod)
+}
+
+func (p Params) hash() func() hash.Hash {
+	switch p.Algorithm {
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+// Validate checks code against the periods around t and returns the step it
+// matched. Callers should refuse steps at or before the last one accepted,
+// so a code can't be replayed.
+func (p Params) Validate(secret []byte, code string, t time.Time) (int64, bool) {
+	if len(code) != p.Digits {
+		return 0, false
+	}
+	now := p.Step(t)
+	for step := now - int64(p.Skew); step <= now+int64(p.Skew); step++ {
+		if subtle.ConstantTimeCompare([]byte(p.codeAt(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth:// URI authenticator apps read from a QR code.
+func (p Params) URI(issuer, account string, secret []byte) string {
+	q := url.Values{}
+	q.Set("secret", EncodeSecret(secret))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", p.Algorithm)
+	q.Set("digits", strconv.Itoa(p.Digits))
+	q.Set("period", strconv.Itoa(int(p.Period/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B. The seeds are the ASCII digits repeated to the
+// hash's output size.
+var (
+	seed20 = []byte("12345678901234567890")
+	seed32 = []byte("12345678901234567890123456789012")
+	seed64 = []byte("1234567890123456789012345678901234567890123456789012345678901234")
+)
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	vectors := []struct {
+		unix                 int64
+		sha1, sha256, sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+	for _, v := range vectors {
+		at := time.Unix(v.unix, 0)
+		for _, c := range []struct {
+			alg, want string
+			seed      []byte
+		}{{SHA1, v.sha1, seed20}, {SHA256, v.sha256, seed32}, {SHA512, v.sha512, seed64}} {
+			p := Params{Algorithm: c.alg, Digits: 8, Period: 30 * time.Second}
+			if got := p.Code(c.seed, at); got != c.want {
+				t.Errorf("%s at %d: expected %s, got %s", c.alg, v.unix, c.want, got)
+			}
+		}
+	}
+}
+
+func TestValidate_AcceptsSkewAndReportsStep(t *testing.T) {
+	p := DefaultParams
+	at := time.Unix(1111111111, 0)
+	previous := p.Code(seed20, at.Add(-30*time.Second))
+
+	step, ok := p.Validate(seed20, previous, at)
+
+	if !ok || step != p.Step(at)-1 {
+		t.Errorf("expected the previous period's code to match step %d, got %d, %v", p.Step(at)-1, step, ok)
+	}
+	if _, ok := p.Validate(seed20, p.Code(seed20, at.Add(-90*time.Second)), at); ok {
+		t.Errorf("expected a code three periods old to be refused")
+	}
+	if _, ok := p.Validate(seed20, "12345", at); ok {
+		t.Errorf("expected a code of the wrong length to be refused")
+	}
+}
+
+func TestURI_ForAuthenticatorApps(t *testing.T) {
+	uri := DefaultParams.URI("iLeon", "user@test.com", seed20)
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/iLeon:user@test.com" {
+		t.Errorf("unexpected URI %s", uri)
+	}
+	q := u.Query()
+	if q.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("unexpected parameters %v", q)
+	}
+	if strings.Contains(q.Get("secret"), "=") {
+		t.Errorf("expected an unpadded secret, got %s", q.Get("secret"))
+	}
+}
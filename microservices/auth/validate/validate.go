@@ -0,0 +1,116 @@
+// Package validate checks and normalizes the fields of incoming requests,
+// collecting every problem so the caller can show them next to the fields.
+package validate
+
+import (
+	"errors"
+	"iLeon/microservices/natsrpc"
+	"net/mail"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Username limits.
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 32
+)
+
+// DisplayNameMaxLength is the longest display name, in characters.
+const DisplayNameMaxLength = 64
+
+// maxEmailLength is the longest address SMTP can deliver to (RFC 5321).
+const maxEmailLength = 254
+
+// FieldError is one problem with one field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects the problems found in a request.
+type Errors []FieldError
+
+// Add records a problem with field.
+func (e *Errors) Add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Check records err, if any, as a problem with field.
+func (e *Errors) Check(field string, err error) {
+	if err != nil {
+		e.Add(field, err.Error())
+	}
+}
+
+// Err returns nil if nothing was recorded, otherwise a VALIDATION error
+// carrying the first message and every problem in details.fields.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return natsrpc.Validation(e[0].Message).
+		WithDetails(map[string][]FieldError{"fields": e})
+}
+
+// Email checks the syntax of an email address and returns it trimmed and in
+// lower case, the form it is stored and looked up in.
+func Email(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", errors.New("Email is required")
+	}
+	if len(email) > maxEmailLength {
+		return "", errors.New("Email is too long")
+	}
+	// ParseAddress also accepts display names and comments; only a bare
+	// address that comes back unchanged is allowed.
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.New("Email is not a valid address")
+	}
+	domain := email[strings.LastIndexByte(email, '@')+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", errors.New("Email is not a valid address")
+	}
+	return email, nil
+}
+
+// Username checks a username and returns it trimmed. Usernames are 3 to 32
+// letters, digits, dots, dashes and underscores, starting with a letter or
+// digit.
+func Username(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return "", errors.New("Username is required")
+	}
+	n := utf8.RuneCountInString(username)
+	if n < UsernameMinLength || n > UsernameMaxLength {
+		return "", errors.New("Username must be 3 to 32 characters long")
+	}
+	for i, r := range username {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case i > 0 && (r == '.' || r == '-' || r == '_'):
+		default:
+			return "", errors.New("Username may only contain letters, digits, dots, dashes and underscores, and must start with a letter or digit")
+		}
+	}
+	return username, nil
+}
+
+// DisplayName checks a display name and returns it trimmed. It is optional,
+// so an empty one is valid and clears it.
+func DisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > DisplayNameMaxLength {
+		return "", errors.New("Display name must be at most 64 characters long")
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", errors.New("Display name must not contain control characters")
+		}
+	}
+	return name, nil
+}
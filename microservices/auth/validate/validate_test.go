@@ -0,0 +1,91 @@
+package validate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"iLeon/microservices/auth/validate"
+	"iLeon/microservices/natsrpc"
+	"strings"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	valid := map[string]string{
+		"user@test.com":             "user@test.com",
+		"  User.Name@Test.COM ":     "user.name@test.com",
+		"first+tag@mail.example.io": "first+tag@mail.example.io",
+	}
+	for in, want := range valid {
+		got, err := validate.Email(in)
+		if err != nil || got != want {
+			t.Errorf("%q: expected %q, got %q, %v", in, want, got, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"   ",
+		"user",
+		"user@",
+		"@test.com",
+		"user@localhost",
+		"user@test.",
+		"user@.test.com",
+		"Bob <bob@test.com>",
+		"a b@test.com",
+		strings.Repeat("a", 250) + "@test.com",
+	}
+	for _, in := range invalid {
+		if _, err := validate.Email(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestUsername(t *testing.T) {
+	for _, ok := range []string{"bob", "alice_99", "j.doe-smith", " padded "} {
+		if _, err := validate.Username(ok); err != nil {
+			t.Errorf("%q: unexpected error %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "ab", "_bob", "bob smith", "bob@home", strings.Repeat("a", 33)} {
+		if _, err := validate.Username(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	if got, err := validate.DisplayName("  Ada Lovelace "); err != nil || got != "Ada Lovelace" {
+		t.Errorf("expected a trimmed name, got %q, %v", got, err)
+	}
+	if got, err := validate.DisplayName(""); err != nil || got != "" {
+		t.Errorf("expected an empty name to be allowed, got %q, %v", got, err)
+	}
+	for _, bad := range []string{"tab\there", strings.Repeat("é", 65)} {
+		if _, err := validate.DisplayName(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestErrors_Err(t *testing.T) {
+	var errs validate.Errors
+	if errs.Err() != nil {
+		t.Fatalf("expected no error when nothing was recorded")
+	}
+
+	errs.Check("email", nil)
+	errs.Check("email", errors.New("Email is required"))
+	errs.Add("password", "Password is required")
+
+	var rpcErr *natsrpc.Error
+	if !errors.As(errs.Err(), &rpcErr) || rpcErr.Code != natsrpc.CodeValidation || rpcErr.Message != "Email is required" {
+		t.Fatalf("expected a VALIDATION error with the first message, got %v", errs.Err())
+	}
+	details, _ := json.Marshal(rpcErr.Details)
+	want := `{"fields":[{"field":"email","message":"Email is required"},{"field":"password","message":"Password is required"}]}`
+	if string(details) != want {
+		t.Errorf("unexpected details %s", details)
+	}
+}
@@ -0,0 +1,183 @@
+// Package config loads the customers service settings. Later sources override
+// earlier ones: defaults, the optional .env file, environment variables and
+// finally command-line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"iLeon/microservices/natsrpc"
+	"io/fs"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type Database struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+}
+
+// DSN is the lib/pq connection string.
+func (d Database) DSN() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     fmt.Sprintf("%s:%d", d.Host, d.Port),
+		Path:     d.Name,
+		RawQuery: url.Values{"sslmode": {d.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+type Config struct {
+	NATS     natsrpc.Config
+	Database Database
+
+	ShutdownTimeout time.Duration
+}
+
+func defaults() *Config {
+	cfg := &Config{
+		NATS: natsrpc.DefaultConfig("customers"),
+		Database: Database{
+			Port:    5432,
+			SSLMode: "disable",
+		},
+		ShutdownTimeout: 15 * time.Second,
+	}
+	// Handlers mostly wait on Postgres, so run more of them than there are
+	// cores.
+	cfg.NATS.MaxInFlight = 16
+	return cfg
+}
+
+// Load builds the effective configuration from args (usually os.Args[1:]).
+// ENV_FILE points at the .env file; a missing file is not an error.
+func Load(args []string) (*Config, error) {
+	envFile := os.Getenv("ENV_FILE")
+	if envFile == "" {
+		envFile = ".env"
+	}
+	// godotenv never overrides variables that are already set, so the real
+	// environment wins over the file.
+	if err := godotenv.Load(envFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("loading %s: %w", envFile, err)
+	}
+
+	return load(os.Getenv, args)
+}
+
+func load(getenv func(string) string, args []string) (*Config, error) {
+	cfg := defaults()
+	if err := cfg.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	flags := flag.NewFlagSet("customers", flag.ContinueOnError)
+	cfg.registerFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	errs := []error{c.NATS.ApplyEnv(getenv)}
+
+	for key, dst := range map[string]*string{
+		"DATABASE_HOST":     &c.Database.Host,
+		"DATABASE_USER":     &c.Database.User,
+		"DATABASE_PASSWORD": &c.Database.Password,
+		"DATABASE_NAME":     &c.Database.Name,
+		"DATABASE_SSLMODE":  &c.Database.SSLMode,
+	} {
+		if v := strings.TrimSpace(getenv(key)); v != "" {
+			*dst = v
+		}
+	}
+	if v := strings.TrimSpace(getenv("DATABASE_PORT")); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("DATABASE_PORT: %w", err))
+		} else {
+			c.Database.Port = port
+		}
+	}
+	if v := getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SHUTDOWN_TIMEOUT: %w", err))
+		} else {
+			c.ShutdownTimeout = d
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) registerFlags(fs *flag.FlagSet) {
+	c.NATS.RegisterFlags(fs)
+	fs.StringVar(&c.Database.Host, "database-host", c.Database.Host, "Postgres host")
+	fs.IntVar(&c.Database.Port, "database-port", c.Database.Port, "Postgres port")
+	fs.StringVar(&c.Database.User, "database-user", c.Database.User, "Postgres user")
+	fs.StringVar(&c.Database.Password, "database-password", c.Database.Password, "Postgres password")
+	fs.StringVar(&c.Database.Name, "database-name", c.Database.Name, "Postgres database")
+	fs.StringVar(&c.Database.SSLMode, "database-sslmode", c.Database.SSLMode, "Postgres sslmode")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time in-flight requests get to finish on shutdown")
+}
+
+func (c *Config) Validate() error {
+	errs := []error{c.NATS.Validate()}
+
+	if c.Database.Host == "" {
+		errs = append(errs, errors.New("DATABASE_HOST is required"))
+	}
+	if c.Database.Port <= 0 || c.Database.Port > 65535 {
+		errs = append(errs, errors.New("DATABASE_PORT must be a valid port"))
+	}
+	if c.Database.User == "" {
+		errs = append(errs, errors.New("DATABASE_USER is required"))
+	}
+	if c.Database.Name == "" {
+		errs = append(errs, errors.New("DATABASE_NAME is required"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// String renders the effective configuration with secrets masked, for the
+// startup log.
+func (c *Config) String() string {
+	password := ""
+	if c.Database.Password != "" {
+		password = "xxxxx"
+	}
+
+	lines := append(c.NATS.Redacted(),
+		"DATABASE_HOST="+c.Database.Host,
+		"DATABASE_PORT="+strconv.Itoa(c.Database.Port),
+		"DATABASE_USER="+c.Database.User,
+		"DATABASE_PASSWORD="+password,
+		"DATABASE_NAME="+c.Database.Name,
+		"DATABASE_SSLMODE="+c.Database.SSLMode,
+		"SHUTDOWN_TIMEOUT="+c.ShutdownTimeout.String(),
+	)
+	return strings.Join(lines, "\n")
+}
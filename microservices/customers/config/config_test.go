@@ -0,0 +1,83 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+var validEnv = map[string]string{
+	"DATABASE_HOST":     "db",
+	"DATABASE_USER":     "northwind",
+	"DATABASE_PASSWORD": "p@ss word",
+	"DATABASE_NAME":     "northwind",
+}
+
+func TestLoad_DefaultsEnvAndFlags(t *testing.T) {
+	cfg, err := load(env(validEnv), []string{"-database-port", "6543", "-nats-max-in-flight", "4"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Database.Port != 6543 || cfg.NATS.MaxInFlight != 4 {
+		t.Errorf("expected flags to override defaults, got %+v", cfg)
+	}
+	if cfg.Database.SSLMode != "disable" || cfg.NATS.QueueGroup != "customers" {
+		t.Errorf("expected defaults to survive, got %+v", cfg)
+	}
+}
+
+func TestLoad_RequiresDatabaseSettings(t *testing.T) {
+	_, err := load(env(nil), nil)
+
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"DATABASE_HOST", "DATABASE_USER", "DATABASE_NAME"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestLoad_InvalidPort(t *testing.T) {
+	vars := map[string]string{"DATABASE_PORT": "postgres"}
+	for k, v := range validEnv {
+		vars[k] = v
+	}
+
+	if _, err := load(env(vars), nil); err == nil {
+		t.Error("expected an error for a non-numeric port")
+	}
+}
+
+func TestApplyEnv_BadValuesKeepDefaults(t *testing.T) {
+	cfg := defaults()
+
+	err := cfg.applyEnv(env(map[string]string{"DATABASE_PORT": "postgres", "SHUTDOWN_TIMEOUT": "soon"}))
+
+	if err == nil {
+		t.Error("expected parse errors")
+	}
+	if want := defaults(); cfg.Database.Port != want.Database.Port || cfg.ShutdownTimeout != want.ShutdownTimeout {
+		t.Errorf("expected bad values to keep the defaults, got %d and %v", cfg.Database.Port, cfg.ShutdownTimeout)
+	}
+}
+
+func TestDSN_EscapesCredentials(t *testing.T) {
+	cfg, err := load(env(validEnv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "postgres://northwind:p%40ss%20word@db:5432/northwind?sslmode=disable"
+	if got := cfg.Database.DSN(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if strings.Contains(cfg.String(), "p@ss") {
+		t.Errorf("password leaked into output:\n%s", cfg.String())
+	}
+}
@@ -1,113 +1,88 @@
 package controller
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
 	"iLeon/microservices/models"
+	"iLeon/microservices/natsrpc"
 	"iLeon/microservices/service"
-
-	"github.com/nats-io/nats.go"
+	"strings"
 )
 
-// NestJS ClientProxy expects responses as {"response":..., "id":..., "isDisposed":true, "err":null}
-type natsResponse struct {
-	Response   any    `json:"response"`
-	ID         string `json:"id"`
-	IsDisposed bool   `json:"isDisposed"`
-	Err        any    `json:"err"`
-}
-
-func reply(nc *nats.Conn, msg *nats.Msg, id string, response any) {
-	data, _ := json.Marshal(natsResponse{
-		Response:   response,
-		ID:         id,
-		IsDisposed: true,
-		Err:        nil,
-	})
-	nc.Publish(msg.Reply, data)
-}
+// Permissions checked against the caller's access token. The auth service
+// grants them through roles.
+const (
+	permRead   = "customers:read"
+	permWrite  = "customers:write"
+	permDelete = "customers:delete"
+)
 
-func GetAllCustomers(nc *nats.Conn, s service.CustomerService) {
-	nc.Subscribe("customers.findCustomers", func(msg *nats.Msg) {
-		// Extract id from NestJS message
-		var req struct {
-			ID string `json:"id"`
+func GetAllCustomers(srv *natsrpc.Server, s service.CustomerService) error {
+	return natsrpc.Handle(srv, "customers.findCustomers", func(ctx context.Context, query models.CustomerQuery) (*models.CustomerPage, error) {
+		switch strings.ToLower(query.Order) {
+		case "", "asc", "desc":
+		default:
+			return nil, natsrpc.Validation("Order must be asc or desc").
+				WithDetails(map[string]string{"field": "order"})
 		}
-		json.Unmarshal(msg.Data, &req)
-
-		customers, err := s.FetchCustomers()
-		if err != nil {
-			fmt.Println("Something bad occured while trying to fetch customers")
-			reply(nc, msg, req.ID, nil)
-			return
+		if query.Page < 0 || query.Limit < 0 {
+			return nil, natsrpc.Validation("Page and limit must not be negative")
 		}
+		customers, err := s.FetchCustomers(ctx, query)
+		return customers, rpcError(err)
+	}, natsrpc.RequirePermission(permRead))
+}
 
-		reply(nc, msg, req.ID, customers)
-	})
-
-	nc.Flush()
+// ExportCustomers streams every matching customer in chunks instead of a
+// single reply, so the result isn't capped by the NATS max payload. Exports
+// are heavy, so only two run at a time.
+func ExportCustomers(srv *natsrpc.Server, s service.CustomerService) error {
+	return natsrpc.HandleStream(srv, "customers.exportCustomers", func(ctx context.Context, query models.CustomerQuery, stream *natsrpc.Stream[models.Customer]) error {
+		return rpcError(s.StreamCustomers(ctx, query, stream.Send))
+	}, natsrpc.MaxInFlight(2), natsrpc.RequirePermission(permRead))
 }
 
-func GetCustomer(nc *nats.Conn, s service.CustomerService) {
-	nc.Subscribe("customers.findCustomer", func(msg *nats.Msg) {
-		var payload models.Payload
-		if err := json.Unmarshal(msg.Data, &payload); err != nil {
-			fmt.Println("GetCustomer unmarshal error:", err)
-			reply(nc, msg, "", map[string]string{"error": err.Error()})
-			return
+func GetCustomer(srv *natsrpc.Server, s service.CustomerService) error {
+	return natsrpc.Handle(srv, "customers.findCustomer", func(ctx context.Context, id string) (*models.Customer, error) {
+		if id == "" {
+			return nil, natsrpc.Validation("Customer ID is required")
 		}
+		customer, err := s.FetchCustomer(ctx, id)
+		return customer, rpcError(err)
+	}, natsrpc.RequirePermission(permRead))
+}
 
-		customer, err := s.FetchCustomer(payload.Data)
-		if err != nil {
-			fmt.Println("GetCustomer fetch error:", err)
-			reply(nc, msg, payload.Id, nil)
-			return
+func CreateCustomer(srv *natsrpc.Server, s service.CustomerService) error {
+	return natsrpc.Handle(srv, "customers.createCustomer", func(ctx context.Context, body *models.Customer) (*models.Customer, error) {
+		if body == nil || body.CustomerID == "" {
+			return nil, natsrpc.Validation("Customer ID is required").
+				WithDetails(map[string]string{"field": "customer_id"})
 		}
-
-		reply(nc, msg, payload.Id, customer)
-	})
-
-	nc.Flush()
+		customer, err := s.InsertCustomer(ctx, body)
+		return customer, rpcError(err)
+	}, natsrpc.RequirePermission(permWrite))
 }
 
-func CreateCustomer(nc *nats.Conn, s service.CustomerService) {
-	nc.Subscribe("customers.createCustomer", func(msg *nats.Msg) {
-		var payload models.CreateCustomerPayload
-		if err := json.Unmarshal(msg.Data, &payload); err != nil {
-			fmt.Println("CreateCustomer unmarshal error:", err)
-			reply(nc, msg, "", map[string]string{"error": err.Error()})
-			return
+func UpdateCustomer(srv *natsrpc.Server, s service.CustomerService) error {
+	return natsrpc.Handle(srv, "customers.updateCustomer", func(ctx context.Context, body models.UpdatePayload) (*models.Customer, error) {
+		if body.Id == "" {
+			return nil, natsrpc.Validation("Customer ID is required")
 		}
-
-		createdCustomer, err := s.InsertCustomer(payload.Data)
-		if err != nil {
-			fmt.Println("CreateCustomer insert error:", err)
-			reply(nc, msg, payload.Id, map[string]string{"error": err.Error()})
-			return
+		if body.Customer == nil || (body.Customer.ContactName == nil && body.Customer.City == nil && body.Customer.Country == nil) {
+			return nil, natsrpc.Validation("Nothing to update")
 		}
-
-		reply(nc, msg, payload.Id, createdCustomer)
-	})
+		customer, err := s.ChangeCustomer(ctx, body.Customer, body.Id)
+		return customer, rpcError(err)
+	}, natsrpc.RequirePermission(permWrite))
 }
 
-func UpdateCustomer(nc *nats.Conn, s service.CustomerService) {
-	nc.Subscribe("customers.updateCustomer", func(msg *nats.Msg) {
-		var payload models.UpdateCustomerPayload
-		if err := json.Unmarshal(msg.Data, &payload); err != nil {
-			fmt.Println("UpdateCustomer unmarshal error:", err)
-			reply(nc, msg, "", map[string]string{"error": err.Error()})
-			return
+func DeleteCustomer(srv *natsrpc.Server, s service.CustomerService) error {
+	return natsrpc.Handle(srv, "customers.deleteCustomer", func(ctx context.Context, id string) (*models.Customer, error) {
+		if id == "" {
+			return nil, natsrpc.Validation("Customer ID is required")
 		}
-
-		updatedCustomer, err := s.ChangeCustomer(payload.Data.Customer, payload.Data.Id)
-		if err != nil {
-			fmt.Println("UpdateCustomer change error:", err)
-			reply(nc, msg, payload.Id, map[string]string{"error": err.Error()})
-			return
+		if err := s.RemoveCustomer(ctx, id); err != nil {
+			return nil, rpcError(err)
 		}
-
-		reply(nc, msg, payload.Id, updatedCustomer)
-	})
+		return &models.Customer{CustomerID: id}, nil
+	}, natsrpc.RequirePermission(permDelete))
 }
-
-func DeleteCustomer() {}
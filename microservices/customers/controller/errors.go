@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"iLeon/microservices/natsrpc"
+	"iLeon/microservices/repository"
+
+	"github.com/lib/pq"
+)
+
+// Postgres SQLSTATE codes we report as something other than INTERNAL.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	notNullViolation    = "23502"
+	stringDataTruncated = "22001"
+)
+
+// rpcError translates repository errors into typed RPC errors so the gateway
+// can answer with the right HTTP status.
+func rpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return natsrpc.NotFound("Customer not found").Wrap(err)
+	}
+
+	if errors.Is(err, repository.ErrInvalidSort) {
+		return natsrpc.Validation("Sort must be one of customer_id, contact_name, city, country").
+			WithDetails(map[string]string{"field": "sort"}).Wrap(err)
+	}
+
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return natsrpc.Validation("Invalid cursor").
+			WithDetails(map[string]string{"field": "cursor"}).Wrap(err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case uniqueViolation:
+			return natsrpc.Conflict("A customer with this ID already exists").Wrap(err)
+		case foreignKeyViolation:
+			// Northwind orders reference customers, so a customer with
+			// orders can't be removed.
+			return natsrpc.Conflict("Customer is still referenced by other records").
+				WithDetails(map[string]string{"reason": "REFERENCED", "constraint": pqErr.Constraint}).Wrap(err)
+		case notNullViolation:
+			return natsrpc.Validation("Missing required field").
+				WithDetails(map[string]string{"field": pqErr.Column}).Wrap(err)
+		case stringDataTruncated:
+			return natsrpc.Validation("Value too long").Wrap(err)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"iLeon/microservices/natsrpc"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func asRPC(t *testing.T, err error) *natsrpc.Error {
+	t.Helper()
+	var rpcErr *natsrpc.Error
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected *natsrpc.Error, got %T (%v)", err, err)
+	}
+	return rpcErr
+}
+
+func TestRPCError_NoRowsIsNotFound(t *testing.T) {
+	got := asRPC(t, rpcError(fmt.Errorf("delete: %w", sql.ErrNoRows)))
+
+	if got.Code != natsrpc.CodeNotFound {
+		t.Errorf("expected NOT_FOUND, got %s", got.Code)
+	}
+}
+
+func TestRPCError_ForeignKeyIsDistinctConflict(t *testing.T) {
+	got := asRPC(t, rpcError(&pq.Error{Code: "23503", Constraint: "fk_orders_customers"}))
+
+	if got.Code != natsrpc.CodeConflict {
+		t.Fatalf("expected CONFLICT, got %s", got.Code)
+	}
+	details, _ := got.Details.(map[string]string)
+	if details["reason"] != "REFERENCED" || details["constraint"] != "fk_orders_customers" {
+		t.Errorf("unexpected details: %v", got.Details)
+	}
+}
+
+func TestRPCError_UniqueViolationIsConflict(t *testing.T) {
+	got := asRPC(t, rpcError(&pq.Error{Code: "23505"}))
+
+	if got.Code != natsrpc.CodeConflict || got.Details != nil {
+		t.Errorf("expected plain CONFLICT, got %+v", got)
+	}
+}
+
+func TestRPCError_OtherErrorsPassThrough(t *testing.T) {
+	original := errors.New("connection refused")
+
+	if got := rpcError(original); got != original {
+		t.Errorf("expected error to pass through unchanged, got %v", got)
+	}
+	if rpcError(nil) != nil {
+		t.Error("expected nil for nil error")
+	}
+}
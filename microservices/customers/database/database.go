@@ -1,40 +1,27 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"github.com/joho/godotenv"
+
 	_ "github.com/lib/pq"
-	"log"
-	"os"
-	"strconv"
 )
 
-func Connect() (*sql.DB, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		log.Fatal("Can't reload env variables")
-	}
-
-	host := os.Getenv("DATABASE_HOST")
-	portStr := os.Getenv("DATABASE_PORT")
-	port, err := strconv.Atoi(portStr)
-	if err != nil || port == 0 {
-		port = 5432
-	}
-	user := os.Getenv("DATABASE_USER")
-	password := os.Getenv("DATABASE_PASSWORD")
-	dbname := os.Getenv("DATABASE_NAME")
-
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-
-	db, err := sql.Open("postgres", connStr)
+// Connect opens the pool and pings the server so bad settings fail at
+// startup rather than on the first request.
+func Connect(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
 
 	if err != nil {
-		log.Fatal(err)
 		return nil, fmt.Errorf("Something went wrong while tring to attempt the connection: %w", err)
 	}
 
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Can't reach the database: %w", err)
+	}
+
 	fmt.Println("Connected successfully to the database")
 	return db, nil
 }
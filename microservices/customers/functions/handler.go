@@ -1,18 +1,19 @@
 package functions
 
 import (
+	"errors"
 	"iLeon/microservices/controller"
+	"iLeon/microservices/natsrpc"
 	"iLeon/microservices/service"
-
-	"github.com/nats-io/nats.go"
 )
 
-func Handler(n *nats.Conn, service service.CustomerService) {
-
-	controller.GetAllCustomers(n, service)
-	controller.GetCustomer(n, service)
-	controller.CreateCustomer(n, service)
-	controller.UpdateCustomer(n, service)
-	controller.DeleteCustomer()
-
+func Handler(srv *natsrpc.Server, service service.CustomerService) error {
+	return errors.Join(
+		controller.GetAllCustomers(srv, service),
+		controller.ExportCustomers(srv, service),
+		controller.GetCustomer(srv, service),
+		controller.CreateCustomer(srv, service),
+		controller.UpdateCustomer(srv, service),
+		controller.DeleteCustomer(srv, service),
+	)
 }
@@ -3,15 +3,22 @@ module iLeon/microservices
 go 1.22.5
 
 require (
-	github.com/Masterminds/squirrel v1.5.4 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/Masterminds/squirrel v1.5.4
+	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	github.com/nats-io/nats.go v1.37.0
+)
+
+require (
 	github.com/klauspost/compress v1.17.2 // indirect
 	github.com/lann/builder v0.0.0-20180802200727-47ae307949d0 // indirect
 	github.com/lann/ps v0.0.0-20150810152359-62de8c46ede0 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/nats-io/nats.go v1.37.0 // indirect
 	github.com/nats-io/nkeys v0.4.7 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
 	golang.org/x/crypto v0.18.0 // indirect
 	golang.org/x/sys v0.16.0 // indirect
 )
+
+require iLeon/microservices/natsrpc v0.0.0
+
+replace iLeon/microservices/natsrpc => ../natsrpc
@@ -1,35 +1,86 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"iLeon/microservices/config"
 	"iLeon/microservices/database"
 	"iLeon/microservices/functions"
+	"iLeon/microservices/natsrpc"
 	repository "iLeon/microservices/repository"
 	service "iLeon/microservices/service"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
-
-	"github.com/nats-io/nats.go"
 )
 
+// connectTimeout bounds the startup database connection.
+const connectTimeout = 10 * time.Second
+
 func main() {
 
-	natsUrl := nats.DefaultURL
-	nc, err := nats.Connect(natsUrl)
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:\n", err)
+	}
+	fmt.Println("Effective configuration:\n" + cfg.String())
+
+	nc, err := cfg.NATS.Connect()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer nc.Close()
 
-	fmt.Println("Connected to NATS server at", natsUrl)
+	fmt.Println("Connected to NATS server at", nc.ConnectedUrlRedacted())
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	db, err := database.Connect(ctx, cfg.Database.DSN())
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	db, _ := database.Connect()
 	repo := repository.NewRepo(db)
 	service := service.NewService(repo)
 
-	functions.Handler(nc, service)
+	// Tokens are checked by the auth service, so customers never holds
+	// signing secrets.
+	srv := natsrpc.NewServer(nc, append(cfg.NATS.ServerOptions(),
+		natsrpc.WithAuthenticator(natsrpc.Introspect(nc, "auth.validateToken")))...)
+	if err := functions.Handler(srv, service); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	os.Exit(shutdown(srv, db, cfg.ShutdownTimeout))
+}
+
+// shutdown drains NATS so in-flight requests are answered, then closes the
+// database. It returns the process exit status.
+func shutdown(srv *natsrpc.Server, db *sql.DB, timeout time.Duration) int {
+	fmt.Println("Shutting down, draining NATS subscriptions")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	status := 0
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Couldn't drain NATS connection:", err)
+		status = 1
+	}
+	// Draining has answered every request, so nothing is using the pool.
+	if err := db.Close(); err != nil {
+		log.Println("Couldn't close the database connection:", err)
+		status = 1
+	}
 
-	for {
-		time.Sleep(10 * time.Second)
+	if status == 0 {
+		fmt.Println("Shutdown complete")
 	}
+	return status
 }
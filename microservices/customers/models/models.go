@@ -7,25 +7,33 @@ type Customer struct {
 	Country     *string `json:"country,omitempty"`
 }
 
-type Payload struct {
-	Pattern string `json:"pattern"`
-	Data    string `json:"data"`
-	Id      string `json:"id"`
-}
-
-type CreateCustomerPayload struct {
-	Pattern string    `json:"pattern"`
-	Data    *Customer `json:"data"`
-	Id      string    `json:"id"`
-}
-
 type UpdatePayload struct {
 	Customer *Customer `json:"customer"`
 	Id       string    `json:"id"`
 }
 
-type UpdateCustomerPayload struct {
-	Pattern string        `json:"pattern"`
-	Data    UpdatePayload `json:"data"`
-	Id      string        `json:"id"`
+// CustomerQuery is the customers.findCustomers payload. Filters without the
+// _prefix suffix match exactly; Cursor, when set, takes precedence over Page.
+type CustomerQuery struct {
+	Page   int    `json:"page"`
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+	Sort   string `json:"sort"`
+	Order  string `json:"order"`
+
+	City              string `json:"city"`
+	CityPrefix        string `json:"city_prefix"`
+	Country           string `json:"country"`
+	CountryPrefix     string `json:"country_prefix"`
+	ContactName       string `json:"contact_name"`
+	ContactNamePrefix string `json:"contact_name_prefix"`
+}
+
+type CustomerPage struct {
+	Data       []Customer `json:"data"`
+	Total      int        `json:"total"`
+	Page       int        `json:"page,omitempty"`
+	Limit      int        `json:"limit"`
+	Pages      int        `json:"pages"`
+	NextCursor string     `json:"next_cursor,omitempty"`
 }
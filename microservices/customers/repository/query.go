@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	models "iLeon/microservices/models"
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// sortColumns maps the sort keys accepted by customers.findCustomers to the
+// expression used to order and compare on. Nullable columns are coalesced so
+// keyset comparisons never hit NULL.
+var sortColumns = map[string]string{
+	"customer_id":  "customer_id",
+	"contact_name": "coalesce(contact_name, '')",
+	"city":         "coalesce(city, '')",
+	"country":      "coalesce(country, '')",
+}
+
+// cursor is the last row of a page, base64-encoded into next_cursor.
+type cursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func customerFilters(query models.CustomerQuery) sq.And {
+	where := sq.And{}
+
+	exact := map[string]string{
+		"city":         query.City,
+		"country":      query.Country,
+		"contact_name": query.ContactName,
+	}
+	prefix := map[string]string{
+		"city":         query.CityPrefix,
+		"country":      query.CountryPrefix,
+		"contact_name": query.ContactNamePrefix,
+	}
+
+	// Iterate in a fixed order so the generated SQL is stable.
+	for _, column := range []string{"contact_name", "city", "country"} {
+		if v := exact[column]; v != "" {
+			where = append(where, sq.Eq{column: v})
+		}
+		if v := prefix[column]; v != "" {
+			where = append(where, sq.Like{column: likeEscaper.Replace(v) + "%"})
+		}
+	}
+
+	return where
+}
+
+// findAllQuery builds the page select. It asks for Limit+1 rows so the caller
+// can tell whether a next page exists.
+func findAllQuery(query models.CustomerQuery, where sq.And) (sq.SelectBuilder, error) {
+	column, ok := sortColumns[query.Sort]
+	if !ok {
+		return sq.SelectBuilder{}, ErrInvalidSort
+	}
+
+	direction, cmp := "ASC", ">"
+	if query.Order == "desc" {
+		direction, cmp = "DESC", "<"
+	}
+
+	builder := customersSelect()
+
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return sq.SelectBuilder{}, err
+		}
+		if query.Sort == "customer_id" {
+			where = append(where, sq.Expr("customer_id "+cmp+" ?", after.ID))
+		} else {
+			where = append(where, sq.Expr(
+				"("+column+" "+cmp+" ? OR ("+column+" = ? AND customer_id "+cmp+" ?))",
+				after.Value, after.Value, after.ID,
+			))
+		}
+	} else if query.Page > 1 {
+		builder = builder.Offset(uint64((query.Page - 1) * query.Limit))
+	}
+
+	if len(where) > 0 {
+		builder = builder.Where(where)
+	}
+
+	orderBy := []string{column + " " + direction}
+	if query.Sort != "customer_id" {
+		orderBy = append(orderBy, "customer_id "+direction)
+	}
+
+	return builder.OrderBy(orderBy...).Limit(uint64(query.Limit + 1)), nil
+}
+
+// streamQuery selects every customer matching the filters, in sort order.
+// Paging fields are ignored.
+func streamQuery(query models.CustomerQuery) (sq.SelectBuilder, error) {
+	column, ok := sortColumns[query.Sort]
+	if !ok {
+		return sq.SelectBuilder{}, ErrInvalidSort
+	}
+
+	direction := "ASC"
+	if query.Order == "desc" {
+		direction = "DESC"
+	}
+
+	builder := customersSelect()
+	if where := customerFilters(query); len(where) > 0 {
+		builder = builder.Where(where)
+	}
+
+	orderBy := []string{column + " " + direction}
+	if query.Sort != "customer_id" {
+		orderBy = append(orderBy, "customer_id "+direction)
+	}
+
+	return builder.OrderBy(orderBy...), nil
+}
+
+func customersSelect() sq.SelectBuilder {
+	return sq.Select("customer_id", "contact_name", "city", "country").
+		From("customers").
+		PlaceholderFormat(sq.Dollar)
+}
+
+func sortValue(c models.Customer, sort string) string {
+	var v *string
+	switch sort {
+	case "customer_id":
+		return c.CustomerID
+	case "contact_name":
+		v = c.ContactName
+	case "city":
+		v = c.City
+	case "country":
+		v = c.Country
+	}
+	if v == nil {
+		return ""
+	}
+	return *v
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"errors"
+	models "iLeon/microservices/models"
+	"reflect"
+	"testing"
+)
+
+func toSql(t *testing.T, query models.CustomerQuery) (string, []any) {
+	t.Helper()
+	builder, err := findAllQuery(query, customerFilters(query))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sqlStr, args, err := builder.ToSql()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return sqlStr, args
+}
+
+func TestFindAllQuery_OffsetPage(t *testing.T) {
+	sqlStr, args := toSql(t, models.CustomerQuery{Page: 3, Limit: 20, Sort: "customer_id"})
+
+	want := "SELECT customer_id, contact_name, city, country FROM customers ORDER BY customer_id ASC LIMIT 21 OFFSET 40"
+	if sqlStr != want {
+		t.Errorf("got  %s\nwant %s", sqlStr, want)
+	}
+	if len(args) != 0 {
+		t.Errorf("expected no args, got %v", args)
+	}
+}
+
+func TestFindAllQuery_ExactAndPrefixFilters(t *testing.T) {
+	sqlStr, args := toSql(t, models.CustomerQuery{
+		Page: 1, Limit: 10, Sort: "city", Order: "desc",
+		Country: "Germany", ContactNamePrefix: "Mar_a%",
+	})
+
+	want := "SELECT customer_id, contact_name, city, country FROM customers " +
+		"WHERE (contact_name LIKE $1 AND country = $2) " +
+		"ORDER BY coalesce(city, '') DESC, customer_id DESC LIMIT 11"
+	if sqlStr != want {
+		t.Errorf("got  %s\nwant %s", sqlStr, want)
+	}
+	if !reflect.DeepEqual(args, []any{`Mar\_a\%%`, "Germany"}) {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestFindAllQuery_KeysetCursorIgnoresPage(t *testing.T) {
+	c := encodeCursor(cursor{Value: "Berlin", ID: "ALFKI"})
+	sqlStr, args := toSql(t, models.CustomerQuery{Page: 5, Limit: 10, Sort: "city", Cursor: c})
+
+	want := "SELECT customer_id, contact_name, city, country FROM customers " +
+		"WHERE ((coalesce(city, '') > $1 OR (coalesce(city, '') = $2 AND customer_id > $3))) " +
+		"ORDER BY coalesce(city, '') ASC, customer_id ASC LIMIT 11"
+	if sqlStr != want {
+		t.Errorf("got  %s\nwant %s", sqlStr, want)
+	}
+	if !reflect.DeepEqual(args, []any{"Berlin", "Berlin", "ALFKI"}) {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestFindAllQuery_RejectsUnknownSort(t *testing.T) {
+	_, err := findAllQuery(models.CustomerQuery{Limit: 10, Sort: "password"}, nil)
+
+	if !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("expected ErrInvalidSort, got %v", err)
+	}
+}
+
+func TestFindAllQuery_RejectsGarbageCursor(t *testing.T) {
+	_, err := findAllQuery(models.CustomerQuery{Limit: 10, Sort: "customer_id", Cursor: "!!not-base64"}, nil)
+
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
@@ -1,18 +1,19 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	sq "github.com/Masterminds/squirrel"
 	models "iLeon/microservices/models"
 )
 
 type CustomersRepository interface {
-	FindAll() (*[]models.Customer, error)
-	FindOne(id string) (*models.Customer, error)
-	Create(body *models.Customer) (*models.Customer, error)
-	Update(body *models.Customer, customerId string) (*models.Customer, error)
-	Delete(customerId string) error
+	FindAll(ctx context.Context, query models.CustomerQuery) (*models.CustomerPage, error)
+	Stream(ctx context.Context, query models.CustomerQuery, fn func(models.Customer) error) error
+	FindOne(ctx context.Context, id string) (*models.Customer, error)
+	Create(ctx context.Context, body *models.Customer) (*models.Customer, error)
+	Update(ctx context.Context, body *models.Customer, customerId string) (*models.Customer, error)
+	Delete(ctx context.Context, customerId string) error
 }
 
 type Repository struct {
@@ -25,30 +26,105 @@ func NewRepo(db *sql.DB) CustomersRepository {
 	}
 }
 
-func (r *Repository) FindAll() (*[]models.Customer, error) {
-	var customers []models.Customer
-	rows, err := r.DB.Query("select customer_id, contact_name, city, country from customers")
+func (r *Repository) FindAll(ctx context.Context, query models.CustomerQuery) (*models.CustomerPage, error) {
+	where := customerFilters(query)
 
+	countQuery := sq.Select("count(*)").From("customers").PlaceholderFormat(sq.Dollar)
+	if len(where) > 0 {
+		countQuery = countQuery.Where(where)
+	}
+	countSql, countArgs, err := countQuery.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := r.DB.QueryRowContext(ctx, countSql, countArgs...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	selectQuery, err := findAllQuery(query, where)
+	if err != nil {
+		return nil, err
+	}
+	sqlStr, args, err := selectQuery.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.DB.QueryContext(ctx, sqlStr, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
+	customers := []models.Customer{}
 	for rows.Next() {
 		customer := models.Customer{}
-		err := rows.Scan(&customer.CustomerID, &customer.ContactName, &customer.City, &customer.Country)
-		if err != nil {
-			fmt.Println(err)
+		if err := rows.Scan(&customer.CustomerID, &customer.ContactName, &customer.City, &customer.Country); err != nil {
+			return nil, err
 		}
 		customers = append(customers, customer)
 	}
-	return &customers, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &models.CustomerPage{
+		Total: total,
+		Limit: query.Limit,
+		Pages: (total + query.Limit - 1) / query.Limit,
+	}
+	if query.Cursor == "" {
+		page.Page = query.Page
+	}
+
+	// One extra row was fetched to find out whether another page exists.
+	if len(customers) > query.Limit {
+		customers = customers[:query.Limit]
+		last := customers[len(customers)-1]
+		page.NextCursor = encodeCursor(cursor{Value: sortValue(last, query.Sort), ID: last.CustomerID})
+	}
+	page.Data = customers
+
+	return page, nil
+}
+
+// Stream calls fn for every matching row as it is read, so large exports
+// never hold the whole table in memory. It stops at the first error from fn.
+func (r *Repository) Stream(ctx context.Context, query models.CustomerQuery, fn func(models.Customer) error) error {
+	selectQuery, err := streamQuery(query)
+	if err != nil {
+		return err
+	}
+	sqlStr, args, err := selectQuery.ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		customer := models.Customer{}
+		if err := rows.Scan(&customer.CustomerID, &customer.ContactName, &customer.City, &customer.Country); err != nil {
+			return err
+		}
+		if err := fn(customer); err != nil {
+			return err
+		}
+	}
 
+	return rows.Err()
 }
 
-func (r *Repository) FindOne(customerId string) (*models.Customer, error) {
+func (r *Repository) FindOne(ctx context.Context, customerId string) (*models.Customer, error) {
 	customer := &models.Customer{}
 
-	data := r.DB.QueryRow("select customer_id, contact_name, city, country from customers where customer_id = $1", customerId)
+	data := r.DB.QueryRowContext(ctx, "select customer_id, contact_name, city, country from customers where customer_id = $1", customerId)
 
 	err := data.Scan(
 		&customer.CustomerID,
@@ -64,9 +140,9 @@ func (r *Repository) FindOne(customerId string) (*models.Customer, error) {
 	return customer, nil
 }
 
-func (r *Repository) Create(body *models.Customer) (*models.Customer, error) {
+func (r *Repository) Create(ctx context.Context, body *models.Customer) (*models.Customer, error) {
 
-	_, err := r.DB.Query("insert into customers (customer_id, contact_name, city, country) values ($1, $2, $3, $4)",
+	_, err := r.DB.ExecContext(ctx, "insert into customers (customer_id, contact_name, city, country) values ($1, $2, $3, $4)",
 		body.CustomerID,
 		body.ContactName,
 		body.City,
@@ -80,8 +156,7 @@ func (r *Repository) Create(body *models.Customer) (*models.Customer, error) {
 	return body, nil
 }
 
-func (r *Repository) Update(body *models.Customer, customerId string) (*models.Customer, error) {
-	fmt.Println(body.ContactName)
+func (r *Repository) Update(ctx context.Context, body *models.Customer, customerId string) (*models.Customer, error) {
 	query := sq.Update("customers").PlaceholderFormat(sq.Dollar).Where(sq.Eq{"customer_id": customerId})
 
 	if body.ContactName != nil {
@@ -102,21 +177,19 @@ func (r *Repository) Update(body *models.Customer, customerId string) (*models.C
 		return nil, err
 	}
 
-	_, err = r.DB.Exec(sqlStr, args...)
+	result, err := r.DB.ExecContext(ctx, sqlStr, args...)
 
-	if err != nil || err == sql.ErrNoRows {
+	if err != nil {
 		return nil, err
 	}
 
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+
 	return body, nil
 }
 
-func (r *Repository) Delete(customerId string) error {
-	err := r.DB.QueryRow("delete from customers WHERE customer_id=$1 RETURNING customer_id", customerId).Scan(&customerId)
-
-	if err == sql.ErrNoRows {
-		return err
-	}
-
-	return nil
+func (r *Repository) Delete(ctx context.Context, customerId string) error {
+	return r.DB.QueryRowContext(ctx, "delete from customers WHERE customer_id=$1 RETURNING customer_id", customerId).Scan(&customerId)
 }
@@ -1,16 +1,20 @@
 package service
 
 import (
+	"context"
+	"strings"
+
 	models "iLeon/microservices/models"
 	repo "iLeon/microservices/repository"
 )
 
 type CustomerService interface {
-	FetchCustomers() (*[]models.Customer, error)
-	FetchCustomer(id string) (*models.Customer, error)
-	InsertCustomer(body *models.Customer) (*models.Customer, error)
-	ChangeCustomer(body *models.Customer, customerId string) (*models.Customer, error)
-	RemoveCustomer(customerId string) error
+	FetchCustomers(ctx context.Context, query models.CustomerQuery) (*models.CustomerPage, error)
+	StreamCustomers(ctx context.Context, query models.CustomerQuery, fn func(models.Customer) error) error
+	FetchCustomer(ctx context.Context, id string) (*models.Customer, error)
+	InsertCustomer(ctx context.Context, body *models.Customer) (*models.Customer, error)
+	ChangeCustomer(ctx context.Context, body *models.Customer, customerId string) (*models.Customer, error)
+	RemoveCustomer(ctx context.Context, customerId string) error
 }
 
 type Service struct {
@@ -23,22 +27,52 @@ func NewService(r repo.CustomersRepository) CustomerService {
 	}
 }
 
-func (s *Service) FetchCustomers() (*[]models.Customer, error) {
-	return s.repository.FindAll()
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// FetchCustomers applies the paging defaults before querying, so the
+// repository never runs an unbounded select.
+func (s *Service) FetchCustomers(ctx context.Context, query models.CustomerQuery) (*models.CustomerPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultPageLimit
+	}
+	if query.Limit > MaxPageLimit {
+		query.Limit = MaxPageLimit
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.Sort == "" {
+		query.Sort = "customer_id"
+	}
+	query.Order = strings.ToLower(query.Order)
+
+	return s.repository.FindAll(ctx, query)
+}
+
+func (s *Service) StreamCustomers(ctx context.Context, query models.CustomerQuery, fn func(models.Customer) error) error {
+	if query.Sort == "" {
+		query.Sort = "customer_id"
+	}
+	query.Order = strings.ToLower(query.Order)
+
+	return s.repository.Stream(ctx, query, fn)
 }
 
-func (s *Service) FetchCustomer(id string) (*models.Customer, error) {
-	return s.repository.FindOne(id)
+func (s *Service) FetchCustomer(ctx context.Context, id string) (*models.Customer, error) {
+	return s.repository.FindOne(ctx, id)
 }
 
-func (s *Service) InsertCustomer(body *models.Customer) (*models.Customer, error) {
-	return s.repository.Create(body)
+func (s *Service) InsertCustomer(ctx context.Context, body *models.Customer) (*models.Customer, error) {
+	return s.repository.Create(ctx, body)
 }
 
-func (s *Service) ChangeCustomer(body *models.Customer, customerId string) (*models.Customer, error) {
-	return s.repository.Update(body, customerId)
+func (s *Service) ChangeCustomer(ctx context.Context, body *models.Customer, customerId string) (*models.Customer, error) {
+	return s.repository.Update(ctx, body, customerId)
 }
 
-func (s *Service) RemoveCustomer(customerId string) error {
-	return s.repository.Delete(customerId)
+func (s *Service) RemoveCustomer(ctx context.Context, customerId string) error {
+	return s.repository.Delete(ctx, customerId)
 }
@@ -1,6 +1,9 @@
 package service_test
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	models "iLeon/microservices/models"
 	repo "iLeon/microservices/repository"
 	"iLeon/microservices/service"
@@ -10,62 +13,133 @@ import (
 // ── Manual mock for CustomersRepository ─────────────────────────────────────
 
 type mockCustomerRepo struct {
-	findAllFn  func() (*[]models.Customer, error)
-	findOneFn  func(id string) (*models.Customer, error)
-	createFn   func(body *models.Customer) (*models.Customer, error)
-	updateFn   func(body *models.Customer, id string) (*models.Customer, error)
-	deleteFn   func(id string) error
+	findAllFn func(query models.CustomerQuery) (*models.CustomerPage, error)
+	streamFn  func(query models.CustomerQuery, fn func(models.Customer) error) error
+	findOneFn func(id string) (*models.Customer, error)
+	createFn  func(body *models.Customer) (*models.Customer, error)
+	updateFn  func(body *models.Customer, id string) (*models.Customer, error)
+	deleteFn  func(id string) error
 }
 
-func (m *mockCustomerRepo) FindAll() (*[]models.Customer, error)  { return m.findAllFn() }
-func (m *mockCustomerRepo) FindOne(id string) (*models.Customer, error) { return m.findOneFn(id) }
-func (m *mockCustomerRepo) Create(b *models.Customer) (*models.Customer, error) { return m.createFn(b) }
-func (m *mockCustomerRepo) Update(b *models.Customer, id string) (*models.Customer, error) {
+func (m *mockCustomerRepo) FindAll(_ context.Context, q models.CustomerQuery) (*models.CustomerPage, error) {
+	return m.findAllFn(q)
+}
+func (m *mockCustomerRepo) Stream(_ context.Context, q models.CustomerQuery, fn func(models.Customer) error) error {
+	return m.streamFn(q, fn)
+}
+func (m *mockCustomerRepo) FindOne(_ context.Context, id string) (*models.Customer, error) {
+	return m.findOneFn(id)
+}
+func (m *mockCustomerRepo) Create(_ context.Context, b *models.Customer) (*models.Customer, error) {
+	return m.createFn(b)
+}
+func (m *mockCustomerRepo) Update(_ context.Context, b *models.Customer, id string) (*models.Customer, error) {
 	return m.updateFn(b, id)
 }
-func (m *mockCustomerRepo) Delete(id string) error { return m.deleteFn(id) }
+func (m *mockCustomerRepo) Delete(_ context.Context, id string) error { return m.deleteFn(id) }
 
 // Compile-time check that mockCustomerRepo satisfies the interface
 var _ repo.CustomersRepository = (*mockCustomerRepo)(nil)
 
 func strPtr(s string) *string { return &s }
 
+var ctx = context.Background()
+
 // ── FetchCustomers ───────────────────────────────────────────────────────────
 
-func TestFetchCustomers_ReturnsAllCustomers(t *testing.T) {
-	expected := &[]models.Customer{
-		{CustomerID: "ABCD", ContactName: strPtr("Alice"), City: strPtr("Cairo"), Country: strPtr("Egypt")},
-		{CustomerID: "EFGH", ContactName: strPtr("Bob"),   City: strPtr("London"), Country: strPtr("UK")},
+func TestFetchCustomers_ReturnsPage(t *testing.T) {
+	expected := &models.CustomerPage{
+		Data: []models.Customer{
+			{CustomerID: "ABCD", ContactName: strPtr("Alice"), City: strPtr("Cairo"), Country: strPtr("Egypt")},
+			{CustomerID: "EFGH", ContactName: strPtr("Bob"), City: strPtr("London"), Country: strPtr("UK")},
+		},
+		Total: 2,
 	}
 	svc := service.NewService(&mockCustomerRepo{
-		findAllFn: func() (*[]models.Customer, error) { return expected, nil },
+		findAllFn: func(_ models.CustomerQuery) (*models.CustomerPage, error) { return expected, nil },
 	})
 
-	result, err := svc.FetchCustomers()
+	result, err := svc.FetchCustomers(ctx, models.CustomerQuery{})
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(*result) != 2 {
-		t.Errorf("expected 2 customers, got %d", len(*result))
+	if len(result.Data) != 2 {
+		t.Errorf("expected 2 customers, got %d", len(result.Data))
 	}
-	if (*result)[0].CustomerID != "ABCD" {
-		t.Errorf("expected first customer ABCD, got %s", (*result)[0].CustomerID)
+	if result.Data[0].CustomerID != "ABCD" {
+		t.Errorf("expected first customer ABCD, got %s", result.Data[0].CustomerID)
 	}
 }
 
-func TestFetchCustomers_ReturnsEmptySlice(t *testing.T) {
+func TestFetchCustomers_AppliesDefaults(t *testing.T) {
+	var captured models.CustomerQuery
 	svc := service.NewService(&mockCustomerRepo{
-		findAllFn: func() (*[]models.Customer, error) { return &[]models.Customer{}, nil },
+		findAllFn: func(q models.CustomerQuery) (*models.CustomerPage, error) {
+			captured = q
+			return &models.CustomerPage{}, nil
+		},
 	})
 
-	result, err := svc.FetchCustomers()
+	svc.FetchCustomers(ctx, models.CustomerQuery{Order: "DESC"})
+
+	if captured.Limit != service.DefaultPageLimit || captured.Page != 1 {
+		t.Errorf("expected default page 1 / limit %d, got %d / %d", service.DefaultPageLimit, captured.Page, captured.Limit)
+	}
+	if captured.Sort != "customer_id" || captured.Order != "desc" {
+		t.Errorf("expected sort customer_id desc, got %s %s", captured.Sort, captured.Order)
+	}
+}
+
+func TestFetchCustomers_CapsLimit(t *testing.T) {
+	var captured models.CustomerQuery
+	svc := service.NewService(&mockCustomerRepo{
+		findAllFn: func(q models.CustomerQuery) (*models.CustomerPage, error) {
+			captured = q
+			return &models.CustomerPage{}, nil
+		},
+	})
+
+	svc.FetchCustomers(ctx, models.CustomerQuery{Limit: 100000, City: "Berlin"})
+
+	if captured.Limit != service.MaxPageLimit {
+		t.Errorf("expected limit to be capped at %d, got %d", service.MaxPageLimit, captured.Limit)
+	}
+	if captured.City != "Berlin" {
+		t.Errorf("expected filters to be passed through, got %+v", captured)
+	}
+}
+
+// ── StreamCustomers ──────────────────────────────────────────────────────────
+
+func TestStreamCustomers_ForwardsRowsAndDefaultsSort(t *testing.T) {
+	var captured models.CustomerQuery
+	svc := service.NewService(&mockCustomerRepo{
+		streamFn: func(q models.CustomerQuery, fn func(models.Customer) error) error {
+			captured = q
+			for _, id := range []string{"ABCD", "EFGH"} {
+				if err := fn(models.Customer{CustomerID: id}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+
+	var ids []string
+	err := svc.StreamCustomers(ctx, models.CustomerQuery{Country: "Egypt"}, func(c models.Customer) error {
+		ids = append(ids, c.CustomerID)
+		return nil
+	})
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(*result) != 0 {
-		t.Errorf("expected empty slice, got %d items", len(*result))
+	if len(ids) != 2 || ids[0] != "ABCD" {
+		t.Errorf("expected rows in order, got %v", ids)
+	}
+	if captured.Sort != "customer_id" || captured.Country != "Egypt" {
+		t.Errorf("unexpected query: %+v", captured)
 	}
 }
 
@@ -82,7 +156,7 @@ func TestFetchCustomer_ReturnsCustomerById(t *testing.T) {
 		},
 	})
 
-	result, err := svc.FetchCustomer("ABCD")
+	result, err := svc.FetchCustomer(ctx, "ABCD")
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -101,7 +175,7 @@ func TestFetchCustomer_DelegatesIdToRepository(t *testing.T) {
 		},
 	})
 
-	svc.FetchCustomer("WXYZ")
+	svc.FetchCustomer(ctx, "WXYZ")
 
 	if capturedID != "WXYZ" {
 		t.Errorf("expected repository to receive id WXYZ, got %q", capturedID)
@@ -121,7 +195,7 @@ func TestInsertCustomer_ReturnsCreatedCustomer(t *testing.T) {
 		createFn: func(b *models.Customer) (*models.Customer, error) { return b, nil },
 	})
 
-	result, err := svc.InsertCustomer(input)
+	result, err := svc.InsertCustomer(ctx, input)
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -141,7 +215,7 @@ func TestInsertCustomer_DelegatesBodyToRepository(t *testing.T) {
 	})
 
 	payload := &models.Customer{CustomerID: "TSTR", ContactName: strPtr("Test")}
-	svc.InsertCustomer(payload)
+	svc.InsertCustomer(ctx, payload)
 
 	if captured == nil || captured.CustomerID != "TSTR" {
 		t.Errorf("repository received wrong payload")
@@ -158,7 +232,7 @@ func TestChangeCustomer_UpdatesAndReturnsCustomer(t *testing.T) {
 		},
 	})
 
-	result, err := svc.ChangeCustomer(&models.Customer{City: strPtr("Alexandria")}, "ABCD")
+	result, err := svc.ChangeCustomer(ctx, &models.Customer{City: strPtr("Alexandria")}, "ABCD")
 
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -177,9 +251,38 @@ func TestChangeCustomer_DelegatesIdToRepository(t *testing.T) {
 		},
 	})
 
-	svc.ChangeCustomer(&models.Customer{}, "ABCD")
+	svc.ChangeCustomer(ctx, &models.Customer{}, "ABCD")
+
+	if capturedID != "ABCD" {
+		t.Errorf("expected id ABCD to be passed to repository, got %q", capturedID)
+	}
+}
+
+// ── RemoveCustomer ───────────────────────────────────────────────────────────
+
+func TestRemoveCustomer_DelegatesIdToRepository(t *testing.T) {
+	capturedID := ""
+	svc := service.NewService(&mockCustomerRepo{
+		deleteFn: func(id string) error {
+			capturedID = id
+			return nil
+		},
+	})
 
+	if err := svc.RemoveCustomer(ctx, "ABCD"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if capturedID != "ABCD" {
 		t.Errorf("expected id ABCD to be passed to repository, got %q", capturedID)
 	}
 }
+
+func TestRemoveCustomer_PropagatesNotFound(t *testing.T) {
+	svc := service.NewService(&mockCustomerRepo{
+		deleteFn: func(_ string) error { return sql.ErrNoRows },
+	})
+
+	if err := svc.RemoveCustomer(ctx, "NONE"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
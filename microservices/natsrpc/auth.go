@@ -0,0 +1,105 @@
+package natsrpc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject     string
+	Email       string
+	Roles       []string
+	Permissions []string
+}
+
+// HasPermission reports whether p was granted permission, either exactly,
+// through "*" or through a "resource:*" wildcard.
+func (p *Principal) HasPermission(permission string) bool {
+	resource, _, _ := strings.Cut(permission, ":")
+	for _, granted := range p.Permissions {
+		if granted == permission || granted == "*" || granted == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator resolves a bearer token to the caller. It returns an
+// UNAUTHENTICATED *Error for tokens that are invalid, expired or revoked.
+type Authenticator func(ctx context.Context, token string) (*Principal, error)
+
+// WithAuthenticator sets how handlers registered with Authenticated or
+// RequirePermission identify the caller.
+func WithAuthenticator(a Authenticator) Option {
+	return func(s *Server) {
+		s.authenticator = a
+	}
+}
+
+// Authenticated rejects requests without a valid bearer token in the
+// AuthorizationHeader. The caller is available through PrincipalFromContext.
+func Authenticated() HandlerOption {
+	return func(c *handlerConfig) {
+		c.authenticate = true
+	}
+}
+
+// RequirePermission rejects requests whose caller lacks any of permissions,
+// e.g. RequirePermission("customers:delete").
+func RequirePermission(permissions ...string) HandlerOption {
+	return func(c *handlerConfig) {
+		c.authenticate = true
+		c.permissions = append(c.permissions, permissions...)
+	}
+}
+
+var errNoAuthenticator = errors.New("natsrpc: handler requires authentication but the server has no Authenticator")
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the caller authenticated for the request, or
+// nil for handlers registered without Authenticated or RequirePermission.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// WithPrincipal returns a copy of ctx carrying p as the caller, as the
+// server does for authenticated handlers. It lets handlers be called
+// directly, e.g. from tests.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// authorize wraps h so it only runs for callers holding every permission in
+// cfg. It runs before the payload is decoded.
+func (s *Server) authorize(h handler, cfg handlerConfig) handler {
+	permissions := slices.Clone(cfg.permissions)
+
+	return func(ctx context.Context, c *call) (any, error) {
+		token := BearerToken(ctx)
+		if token == "" {
+			return nil, NewError(CodeUnauthenticated, "Missing access token")
+		}
+
+		p, err := s.authenticator(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, NewError(CodeUnauthenticated, "Invalid access token")
+		}
+
+		for _, permission := range permissions {
+			if !p.HasPermission(permission) {
+				return nil, NewError(CodeForbidden, "Missing permission "+permission).
+					WithDetails(map[string]string{"permission": permission})
+			}
+		}
+
+		return h(WithPrincipal(ctx, p), c)
+	}
+}
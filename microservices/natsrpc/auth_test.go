@@ -0,0 +1,135 @@
+package natsrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func authRequest(t *testing.T, token string) *nats.Msg {
+	msg := request(t, "1", body{Email: "a@b.com"})
+	msg.Header = nats.Header{}
+	if token != "" {
+		msg.Header.Set(AuthorizationHeader, "Bearer "+token)
+	}
+	return msg
+}
+
+// tokenAuthenticator knows one token, granting permissions.
+func tokenAuthenticator(permissions ...string) Authenticator {
+	return func(_ context.Context, token string) (*Principal, error) {
+		if token != "good" {
+			return nil, NewError(CodeUnauthenticated, "Invalid or expired access token")
+		}
+		return &Principal{Subject: "u1", Permissions: permissions}, nil
+	}
+}
+
+func serveAuthorized(s *Server, msg *nats.Msg, opts []HandlerOption, h HandlerFunc[body, string]) {
+	s.serve("test.pattern", msg, s.authorize(unaryHandler(h), s.handlerConfig(opts)))
+}
+
+func errCode(t *testing.T, p published) any {
+	t.Helper()
+	rpcErr, _ := decodeResponse(t, p)["err"].(map[string]any)
+	return rpcErr["code"]
+}
+
+// ── Principal ────────────────────────────────────────────────────────────────
+
+func TestPrincipal_HasPermission(t *testing.T) {
+	cases := []struct {
+		granted []string
+		want    bool
+	}{
+		{[]string{"customers:delete"}, true},
+		{[]string{"customers:*"}, true},
+		{[]string{"*"}, true},
+		{[]string{"customers:read"}, false},
+		{[]string{"products:*"}, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		p := &Principal{Permissions: c.granted}
+		if got := p.HasPermission("customers:delete"); got != c.want {
+			t.Errorf("%v: expected %v, got %v", c.granted, c.want, got)
+		}
+	}
+}
+
+// ── authorize ────────────────────────────────────────────────────────────────
+
+func TestAuthorize_PassesPrincipalToHandler(t *testing.T) {
+	var out []published
+	s := newTestServer(&out, WithAuthenticator(tokenAuthenticator("customers:*")))
+
+	var subject string
+	serveAuthorized(s, authRequest(t, "good"), []HandlerOption{RequirePermission("customers:delete")},
+		func(ctx context.Context, _ body) (string, error) {
+			subject = PrincipalFromContext(ctx).Subject
+			return "deleted", nil
+		})
+
+	if subject != "u1" || decodeResponse(t, out[0])["response"] != "deleted" {
+		t.Errorf("expected the handler to run as u1, got %q / %v", subject, decodeResponse(t, out[0]))
+	}
+}
+
+func TestAuthorize_RejectsMissingAndInvalidTokens(t *testing.T) {
+	for _, token := range []string{"", "bad"} {
+		var out []published
+		s := newTestServer(&out, WithAuthenticator(tokenAuthenticator("*")))
+		called := false
+
+		serveAuthorized(s, authRequest(t, token), []HandlerOption{Authenticated()},
+			func(context.Context, body) (string, error) { called = true; return "", nil })
+
+		if called || errCode(t, out[0]) != "UNAUTHENTICATED" {
+			t.Errorf("%q: expected UNAUTHENTICATED without running the handler, got %v", token, decodeResponse(t, out[0]))
+		}
+	}
+}
+
+func TestAuthorize_ForbidsMissingPermission(t *testing.T) {
+	var out []published
+	s := newTestServer(&out, WithAuthenticator(tokenAuthenticator("customers:read")))
+	called := false
+
+	serveAuthorized(s, authRequest(t, "good"), []HandlerOption{RequirePermission("customers:read", "customers:delete")},
+		func(context.Context, body) (string, error) { called = true; return "", nil })
+
+	rpcErr := decodeResponse(t, out[0])["err"].(map[string]any)
+	if called || rpcErr["code"] != "FORBIDDEN" {
+		t.Fatalf("expected FORBIDDEN without running the handler, got %v", rpcErr)
+	}
+	if details, _ := rpcErr["details"].(map[string]any); details["permission"] != "customers:delete" {
+		t.Errorf("expected the missing permission in details, got %v", rpcErr["details"])
+	}
+}
+
+func TestAuthorize_AuthenticatorFailureIsPassedOn(t *testing.T) {
+	var out []published
+	s := newTestServer(&out, WithAuthenticator(func(context.Context, string) (*Principal, error) {
+		return nil, NewError(CodeUnavailable, "auth is down").WithRetryable(true)
+	}))
+
+	serveAuthorized(s, authRequest(t, "good"), []HandlerOption{Authenticated()},
+		func(context.Context, body) (string, error) { return "", nil })
+
+	if errCode(t, out[0]) != "UNAVAILABLE" {
+		t.Errorf("expected UNAVAILABLE, got %v", decodeResponse(t, out[0]))
+	}
+}
+
+func TestRegister_RequiresAuthenticator(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	err := Handle(s, "test.pattern", func(context.Context, body) (string, error) { return "", nil }, RequirePermission("x:y"))
+
+	if !errors.Is(err, errNoAuthenticator) {
+		t.Errorf("expected errNoAuthenticator, got %v", err)
+	}
+}
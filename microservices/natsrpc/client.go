@@ -0,0 +1,93 @@
+package natsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+// Requester sends a request and waits for the first reply; *nats.Conn
+// implements it.
+type Requester interface {
+	RequestMsgWithContext(ctx context.Context, msg *nats.Msg) (*nats.Msg, error)
+}
+
+// Call invokes a message pattern the way the NestJS ClientProxy does and
+// decodes the response into Resp. An err in the reply is returned as
+// *Error. Streamed replies are not supported; only the first message is read.
+func Call[Resp any](ctx context.Context, r Requester, subject string, data any) (Resp, error) {
+	var resp Resp
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return resp, BadRequest("Invalid request payload").Wrap(err)
+	}
+	body, err := json.Marshal(Request{Pattern: subject, Data: raw, ID: nuid.Next()})
+	if err != nil {
+		return resp, err
+	}
+
+	msg := &nats.Msg{Subject: subject, Data: body, Header: nats.Header{}}
+	if deadline, ok := ctx.Deadline(); ok {
+		msg.Header.Set(TimeoutHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+
+	reply, err := r.RequestMsgWithContext(ctx, msg)
+	if errors.Is(err, nats.ErrNoResponders) {
+		return resp, NewError(CodeUnavailable, "No responders for "+subject).WithRetryable(true).Wrap(err)
+	}
+	if err != nil {
+		return resp, AsError(err)
+	}
+
+	var envelope struct {
+		Response json.RawMessage `json:"response"`
+		Err      *Error          `json:"err"`
+	}
+	if err := json.Unmarshal(reply.Data, &envelope); err != nil {
+		return resp, Internal("Invalid NATS response").Wrap(err)
+	}
+	if envelope.Err != nil {
+		return resp, envelope.Err
+	}
+	if err := decodeData(envelope.Response, &resp); err != nil {
+		return resp, Internal("Invalid NATS response").Wrap(err)
+	}
+	return resp, nil
+}
+
+// introspection is the reply of the auth service's auth.validateToken.
+type introspection struct {
+	Active bool     `json:"active"`
+	Sub    string   `json:"sub"`
+	Email  string   `json:"email"`
+	Roles  []string `json:"roles"`
+	Scopes []string `json:"scopes"`
+}
+
+// Introspect returns an Authenticator that asks the auth service about each
+// token on subject (normally "auth.validateToken"), so services authorize
+// calls without holding any signing key.
+func Introspect(r Requester, subject string) Authenticator {
+	return func(ctx context.Context, token string) (*Principal, error) {
+		res, err := Call[introspection](ctx, r, subject, map[string]string{"token": token})
+		if err != nil {
+			return nil, err
+		}
+		if !res.Active {
+			return nil, NewError(CodeUnauthenticated, "Invalid or expired access token")
+		}
+
+		return &Principal{
+			Subject:     res.Sub,
+			Email:       res.Email,
+			Roles:       res.Roles,
+			Permissions: res.Scopes,
+		}, nil
+	}
+}
@@ -0,0 +1,123 @@
+package natsrpc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// loopback answers requests with a Server handler, in process.
+type loopback struct {
+	s       *Server
+	h       handler
+	out     []published
+	lastMsg *nats.Msg
+}
+
+func newLoopback[Req, Resp any](h HandlerFunc[Req, Resp]) *loopback {
+	l := &loopback{h: unaryHandler(h)}
+	l.s = newTestServer(&l.out)
+	return l
+}
+
+func (l *loopback) RequestMsgWithContext(_ context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	l.lastMsg = msg
+	msg.Reply = "_INBOX.loopback"
+	l.s.serve(msg.Subject, msg, l.h)
+	last := l.out[len(l.out)-1]
+	return &nats.Msg{Subject: last.subject, Data: last.data}, nil
+}
+
+type noResponders struct{}
+
+func (noResponders) RequestMsgWithContext(context.Context, *nats.Msg) (*nats.Msg, error) {
+	return nil, nats.ErrNoResponders
+}
+
+// ── Call ─────────────────────────────────────────────────────────────────────
+
+func TestCall_RoundTrip(t *testing.T) {
+	l := newLoopback(func(_ context.Context, req body) (body, error) {
+		return body{Email: "echo " + req.Email}, nil
+	})
+
+	got, err := Call[body](context.Background(), l, "test.pattern", body{Email: "a@b.com"})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Email != "echo a@b.com" {
+		t.Errorf("unexpected response: %+v", got)
+	}
+}
+
+func TestCall_ReturnsRemoteError(t *testing.T) {
+	l := newLoopback(func(context.Context, body) (body, error) {
+		return body{}, NotFound("Customer not found")
+	})
+
+	_, err := Call[body](context.Background(), l, "test.pattern", body{})
+
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeNotFound || rpcErr.Message != "Customer not found" {
+		t.Errorf("expected the remote NOT_FOUND, got %v", err)
+	}
+}
+
+func TestCall_ForwardsDeadline(t *testing.T) {
+	l := newLoopback(func(context.Context, body) (body, error) { return body{}, nil })
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	Call[body](ctx, l, "test.pattern", body{})
+
+	ms, err := strconv.Atoi(l.lastMsg.Header.Get(TimeoutHeader))
+	if err != nil || ms <= 0 || ms > 2000 {
+		t.Errorf("expected the remaining budget in %s, got %q", TimeoutHeader, l.lastMsg.Header.Get(TimeoutHeader))
+	}
+}
+
+func TestCall_NoRespondersIsUnavailable(t *testing.T) {
+	_, err := Call[body](context.Background(), noResponders{}, "test.pattern", body{})
+
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeUnavailable || !rpcErr.Retryable {
+		t.Errorf("expected retryable UNAVAILABLE, got %v", err)
+	}
+}
+
+// ── Introspect ───────────────────────────────────────────────────────────────
+
+func TestIntrospect_ActiveTokenBecomesPrincipal(t *testing.T) {
+	var token string
+	l := newLoopback(func(_ context.Context, req map[string]string) (map[string]any, error) {
+		token = req["token"]
+		return map[string]any{"active": true, "sub": "u1", "roles": []string{"admin"}, "scopes": []string{"*"}}, nil
+	})
+
+	p, err := Introspect(l, "auth.validateToken")(context.Background(), "abc")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "abc" || p.Subject != "u1" || !p.HasPermission("customers:delete") {
+		t.Errorf("unexpected principal %+v for token %q", p, token)
+	}
+}
+
+func TestIntrospect_InactiveTokenIsUnauthenticated(t *testing.T) {
+	l := newLoopback(func(context.Context, map[string]string) (map[string]any, error) {
+		return map[string]any{"active": false}, nil
+	})
+
+	_, err := Introspect(l, "auth.validateToken")(context.Background(), "revoked")
+
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeUnauthenticated {
+		t.Errorf("expected UNAUTHENTICATED, got %v", err)
+	}
+}
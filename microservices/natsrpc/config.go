@@ -0,0 +1,215 @@
+package natsrpc
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Config holds the NATS settings shared by the Go services: where and how to
+// connect, and how the Server subscribes.
+type Config struct {
+	URLs      []string
+	Name      string
+	CredsFile string
+	NKeyFile  string
+	TLSCert   string
+	TLSKey    string
+	TLSCA     string
+
+	QueueGroup     string
+	RequestTimeout time.Duration
+	MaxInFlight    int
+}
+
+// DefaultConfig connects to a local server and uses the service name as
+// client name and queue group.
+func DefaultConfig(service string) Config {
+	return Config{
+		URLs:           []string{nats.DefaultURL},
+		Name:           service,
+		QueueGroup:     service,
+		RequestTimeout: DefaultTimeout,
+	}
+}
+
+// ApplyEnv overrides c with the NATS_* variables that are set.
+func (c *Config) ApplyEnv(getenv func(string) string) error {
+	if v := getenv("NATS_URL"); v != "" {
+		c.URLs = splitList(v)
+	}
+	for key, dst := range map[string]*string{
+		"NATS_NAME":        &c.Name,
+		"NATS_CREDS":       &c.CredsFile,
+		"NATS_NKEY":        &c.NKeyFile,
+		"NATS_TLS_CERT":    &c.TLSCert,
+		"NATS_TLS_KEY":     &c.TLSKey,
+		"NATS_TLS_CA":      &c.TLSCA,
+		"NATS_QUEUE_GROUP": &c.QueueGroup,
+	} {
+		if v := getenv(key); v != "" {
+			*dst = v
+		}
+	}
+
+	var errs []error
+	if v := getenv("NATS_REQUEST_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("NATS_REQUEST_TIMEOUT: %w", err))
+		} else {
+			c.RequestTimeout = d
+		}
+	}
+	if v := getenv("NATS_MAX_IN_FLIGHT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("NATS_MAX_IN_FLIGHT: %w", err))
+		} else {
+			c.MaxInFlight = n
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterFlags binds the -nats-* flags to c. Register after ApplyEnv so the
+// flag defaults show the environment values.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.Var((*listFlag)(&c.URLs), "nats-url", "comma-separated NATS server URLs")
+	fs.StringVar(&c.Name, "nats-name", c.Name, "NATS client name")
+	fs.StringVar(&c.CredsFile, "nats-creds", c.CredsFile, "NATS user credentials file")
+	fs.StringVar(&c.NKeyFile, "nats-nkey", c.NKeyFile, "NATS NKey seed file")
+	fs.StringVar(&c.TLSCert, "nats-tls-cert", c.TLSCert, "NATS client certificate")
+	fs.StringVar(&c.TLSKey, "nats-tls-key", c.TLSKey, "NATS client certificate key")
+	fs.StringVar(&c.TLSCA, "nats-tls-ca", c.TLSCA, "NATS root CA")
+	fs.StringVar(&c.QueueGroup, "nats-queue-group", c.QueueGroup, "queue group shared by the service replicas")
+	fs.DurationVar(&c.RequestTimeout, "nats-request-timeout", c.RequestTimeout, "handler deadline when a request has no timeout header")
+	fs.IntVar(&c.MaxInFlight, "nats-max-in-flight", c.MaxInFlight, "requests handled at once per subject (0 keeps the service default)")
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if len(c.URLs) == 0 {
+		errs = append(errs, errors.New("NATS_URL is required"))
+	}
+	for _, u := range c.URLs {
+		if _, err := url.Parse(u); err != nil {
+			errs = append(errs, fmt.Errorf("NATS_URL: %w", err))
+		}
+	}
+	if c.CredsFile != "" && c.NKeyFile != "" {
+		errs = append(errs, errors.New("NATS_CREDS and NATS_NKEY are mutually exclusive"))
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("NATS_TLS_CERT and NATS_TLS_KEY must be set together"))
+	}
+	if c.RequestTimeout < 0 {
+		errs = append(errs, errors.New("NATS_REQUEST_TIMEOUT must not be negative"))
+	}
+	if c.MaxInFlight < 0 {
+		errs = append(errs, errors.New("NATS_MAX_IN_FLIGHT must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Connect dials the configured servers with the configured credentials.
+func (c Config) Connect() (*nats.Conn, error) {
+	opts := []nats.Option{nats.Name(c.Name)}
+
+	if c.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(c.CredsFile))
+	}
+	if c.NKeyFile != "" {
+		opt, err := nats.NkeyOptionFromSeed(c.NKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	if c.TLSCert != "" {
+		opts = append(opts, nats.ClientCert(c.TLSCert, c.TLSKey))
+	}
+	if c.TLSCA != "" {
+		opts = append(opts, nats.RootCAs(c.TLSCA))
+	}
+
+	return nats.Connect(strings.Join(c.URLs, ","), opts...)
+}
+
+// ServerOptions turns the subscription settings into Server options.
+func (c Config) ServerOptions() []Option {
+	opts := []Option{
+		WithQueueGroup(c.QueueGroup),
+		WithDefaultTimeout(c.RequestTimeout),
+	}
+	if c.MaxInFlight > 0 {
+		opts = append(opts, WithHandlerDefaults(MaxInFlight(c.MaxInFlight)))
+	}
+	return opts
+}
+
+// Redacted lists the settings as key=value lines with URL passwords masked.
+func (c Config) Redacted() []string {
+	urls := make([]string, len(c.URLs))
+	for i, u := range c.URLs {
+		urls[i] = RedactURL(u)
+	}
+
+	return []string{
+		"NATS_URL=" + strings.Join(urls, ","),
+		"NATS_NAME=" + c.Name,
+		"NATS_CREDS=" + c.CredsFile,
+		"NATS_NKEY=" + c.NKeyFile,
+		"NATS_TLS_CERT=" + c.TLSCert,
+		"NATS_TLS_KEY=" + c.TLSKey,
+		"NATS_TLS_CA=" + c.TLSCA,
+		"NATS_QUEUE_GROUP=" + c.QueueGroup,
+		"NATS_REQUEST_TIMEOUT=" + c.RequestTimeout.String(),
+		"NATS_MAX_IN_FLIGHT=" + strconv.Itoa(c.MaxInFlight),
+	}
+}
+
+// RedactURL masks the password (or token) in a URL's user info.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	} else {
+		u.User = url.User("xxxxx")
+	}
+	return u.String()
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// listFlag is a comma-separated flag value.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = splitList(v)
+	return nil
+}
@@ -0,0 +1,32 @@
+package natsrpc
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// NestJS ClientProxy wraps messages as {"pattern":..., "data":..., "id":...}
+// and expects responses as {"response":..., "id":..., "isDisposed":true, "err":null}
+type Request struct {
+	Pattern string          `json:"pattern"`
+	Data    json.RawMessage `json:"data"`
+	ID      string          `json:"id"`
+}
+
+type Response struct {
+	Response   any    `json:"response"`
+	ID         string `json:"id"`
+	IsDisposed bool   `json:"isDisposed"`
+	Err        *Error `json:"err"`
+}
+
+// decodeData unmarshals the envelope data into v. The gateway sends an empty
+// string (or nothing at all) for patterns without a payload, so those leave v
+// at its zero value instead of failing struct decoding.
+func decodeData(data json.RawMessage, v any) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) || bytes.Equal(trimmed, []byte(`""`)) {
+		return nil
+	}
+	return json.Unmarshal(trimmed, v)
+}
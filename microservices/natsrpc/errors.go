@@ -0,0 +1,98 @@
+package natsrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Code classifies an RPC failure so the gateway can map it to an HTTP status.
+type Code string
+
+const (
+	CodeBadRequest      Code = "BAD_REQUEST"
+	CodeValidation      Code = "VALIDATION"
+	CodeUnauthenticated Code = "UNAUTHENTICATED"
+	CodeForbidden       Code = "FORBIDDEN"
+	CodeAccountLocked   Code = "ACCOUNT_LOCKED"
+	CodeNotFound        Code = "NOT_FOUND"
+	CodeConflict        Code = "CONFLICT"
+	CodeTimeout         Code = "TIMEOUT"
+	CodeUnavailable     Code = "UNAVAILABLE"
+	CodeInternal        Code = "INTERNAL"
+)
+
+// Error is serialized into the envelope's err field. NestJS rejects the
+// ClientProxy observable with this object as-is.
+type Error struct {
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	Retryable bool   `json:"retryable"`
+
+	cause error
+}
+
+func NewError(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func Errorf(code Code, format string, args ...any) *Error {
+	return NewError(code, fmt.Sprintf(format, args...))
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// WithDetails attaches structured details, e.g. field-level validation errors.
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// WithRetryable marks whether the caller may safely retry the request.
+func (e *Error) WithRetryable(retryable bool) *Error {
+	e.Retryable = retryable
+	return e
+}
+
+// Wrap records the underlying error for logging. It is never sent to the
+// caller.
+func (e *Error) Wrap(cause error) *Error {
+	e.cause = cause
+	return e
+}
+
+func BadRequest(message string) *Error { return NewError(CodeBadRequest, message) }
+func Validation(message string) *Error { return NewError(CodeValidation, message) }
+func NotFound(message string) *Error   { return NewError(CodeNotFound, message) }
+func Conflict(message string) *Error   { return NewError(CodeConflict, message) }
+func Internal(message string) *Error   { return NewError(CodeInternal, message) }
+
+// AsError converts any handler error into an *Error. Errors that aren't
+// already typed are reported as INTERNAL so driver messages don't leak to
+// clients; context errors become a retryable TIMEOUT.
+func AsError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return NewError(CodeTimeout, "The request timed out").WithRetryable(true).Wrap(err)
+	}
+
+	return Internal("Internal server error").Wrap(err)
+}
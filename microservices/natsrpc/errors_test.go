@@ -0,0 +1,34 @@
+package natsrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAsError_KeepsWrappedTypedError(t *testing.T) {
+	original := Conflict("Customer has orders")
+	wrapped := fmt.Errorf("delete: %w", original)
+
+	if got := AsError(wrapped); got != original {
+		t.Errorf("expected the wrapped *Error, got %v", got)
+	}
+}
+
+func TestAsError_DeadlineIsRetryableTimeout(t *testing.T) {
+	got := AsError(fmt.Errorf("query: %w", context.DeadlineExceeded))
+
+	if got.Code != CodeTimeout || !got.Retryable {
+		t.Errorf("expected retryable TIMEOUT, got %+v", got)
+	}
+	if !errors.Is(got, context.DeadlineExceeded) {
+		t.Error("expected cause to be preserved")
+	}
+}
+
+func TestAsError_Nil(t *testing.T) {
+	if AsError(nil) != nil {
+		t.Error("expected nil")
+	}
+}
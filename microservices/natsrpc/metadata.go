@@ -0,0 +1,53 @@
+package natsrpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// AuthorizationHeader carries the caller's credentials, usually
+// "Bearer <access token>" forwarded by the gateway.
+const AuthorizationHeader = "Authorization"
+
+// ForwardedForHeader carries the address of the client the request was made
+// for, set by the gateway from the HTTP connection.
+const ForwardedForHeader = "X-Forwarded-For"
+
+type headerKey struct{}
+
+// WithHeader returns a copy of ctx carrying h as the request headers, as
+// the server does for every request. It lets handlers be called directly,
+// e.g. from tests.
+func WithHeader(ctx context.Context, h nats.Header) context.Context {
+	return context.WithValue(ctx, headerKey{}, h)
+}
+
+// Header returns the NATS headers of the request being handled. It is nil
+// outside a handler or when the caller sent none.
+func Header(ctx context.Context) nats.Header {
+	h, _ := ctx.Value(headerKey{}).(nats.Header)
+	return h
+}
+
+// BearerToken returns the token from the request's AuthorizationHeader, or ""
+// when there is none.
+func BearerToken(ctx context.Context) string {
+	v := Header(ctx).Get(AuthorizationHeader)
+	scheme, token, ok := strings.Cut(v, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// ClientIP returns the last address in the request's ForwardedForHeader, the
+// one added by the nearest proxy, or "" when there is none. Earlier entries
+// come from the client and can be anything. The header itself is only as
+// trustworthy as whoever may publish the request, so subjects that rely on it
+// should be open to the gateway alone.
+func ClientIP(ctx context.Context) string {
+	v := Header(ctx).Get(ForwardedForHeader)
+	return strings.TrimSpace(v[strings.LastIndex(v, ",")+1:])
+}
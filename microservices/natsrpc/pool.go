@@ -0,0 +1,119 @@
+package natsrpc
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultPendingMsgs  = 4096
+	DefaultPendingBytes = 8 * 1024 * 1024
+)
+
+// HandlerOption tunes how one subject's requests are processed.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	maxInFlight  int
+	pendingMsgs  int
+	pendingBytes int
+
+	authenticate bool
+	permissions  []string
+}
+
+func defaultHandlerConfig() handlerConfig {
+	return handlerConfig{
+		maxInFlight:  runtime.GOMAXPROCS(0),
+		pendingMsgs:  DefaultPendingMsgs,
+		pendingBytes: DefaultPendingBytes,
+	}
+}
+
+// MaxInFlight bounds how many requests of the subject are handled at once.
+// nats.go delivers a subscription's messages one at a time, so without it one
+// slow request holds up every request behind it.
+func MaxInFlight(n int) HandlerOption {
+	return func(c *handlerConfig) {
+		if n > 0 {
+			c.maxInFlight = n
+		}
+	}
+}
+
+// PendingLimits bounds the messages and bytes queued for the subject while
+// all workers are busy. Messages beyond either limit are dropped by the client
+// and reported as a slow consumer.
+func PendingLimits(msgs, bytes int) HandlerOption {
+	return func(c *handlerConfig) {
+		c.pendingMsgs = msgs
+		c.pendingBytes = bytes
+	}
+}
+
+// WithHandlerDefaults applies opts to every handler registered on the server,
+// before the handler's own options.
+func WithHandlerDefaults(opts ...HandlerOption) Option {
+	return func(s *Server) {
+		s.handlerDefaults = append(s.handlerDefaults, opts...)
+	}
+}
+
+// WithSlowConsumerHandler is called whenever a subscription drops messages
+// because its pending limits were hit, in addition to the log line.
+func WithSlowConsumerHandler(fn func(subject string, dropped int)) Option {
+	return func(s *Server) {
+		s.onSlowConsumer = fn
+	}
+}
+
+func (s *Server) handlerConfig(opts []HandlerOption) handlerConfig {
+	cfg := defaultHandlerConfig()
+	for _, opt := range s.handlerDefaults {
+		opt(&cfg)
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// dispatch returns the subscription callback. It hands each message to a
+// worker goroutine and blocks once maxInFlight are busy, which leaves further
+// messages in the subscription's bounded pending queue.
+func (s *Server) dispatch(subject string, h handler, cfg handlerConfig) nats.MsgHandler {
+	workers := make(chan struct{}, cfg.maxInFlight)
+
+	return func(msg *nats.Msg) {
+		workers <- struct{}{}
+		s.inflight.Add(1)
+
+		go func() {
+			defer func() {
+				<-workers
+				s.inflight.Done()
+			}()
+			s.serve(subject, msg, h)
+		}()
+	}
+}
+
+// asyncError reports slow consumers and passes every error on to the handler
+// that was installed on the connection before the server.
+func (s *Server) asyncError(next nats.ErrHandler) nats.ErrHandler {
+	return func(nc *nats.Conn, sub *nats.Subscription, err error) {
+		if sub != nil && errors.Is(err, nats.ErrSlowConsumer) {
+			dropped, _ := sub.Dropped()
+			msgs, bytes, _ := sub.Pending()
+			s.logger.Printf("%s: slow consumer, %d messages dropped (%d messages / %d bytes pending)", sub.Subject, dropped, msgs, bytes)
+			if s.onSlowConsumer != nil {
+				s.onSlowConsumer(sub.Subject, dropped)
+			}
+		}
+		if next != nil {
+			next(nc, sub, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package natsrpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestDispatch_BoundsConcurrentHandlers(t *testing.T) {
+	var out []published
+	var mu sync.Mutex
+	s := newTestServer(&out)
+	s.publish = func(msg *nats.Msg) error {
+		mu.Lock()
+		defer mu.Unlock()
+		out = append(out, published{subject: msg.Subject, data: msg.Data})
+		return nil
+	}
+
+	var running, peak int32
+	release := make(chan struct{})
+	h := unaryHandler(func(_ context.Context, _ body) (any, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return "ok", nil
+	})
+
+	cfg := s.handlerConfig([]HandlerOption{MaxInFlight(3)})
+	deliver := s.dispatch("test.pattern", h, cfg)
+
+	// nats.go calls the callback serially from one goroutine.
+	delivered := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			deliver(request(t, "1", body{}))
+		}
+		close(delivered)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&running); got != 3 {
+		t.Errorf("expected 3 handlers in flight, got %d", got)
+	}
+
+	close(release)
+	<-delivered
+	s.inflight.Wait()
+
+	if peak != 3 {
+		t.Errorf("expected peak concurrency 3, got %d", peak)
+	}
+	if len(out) != 10 {
+		t.Errorf("expected 10 replies, got %d", len(out))
+	}
+}
+
+func TestHandlerConfig_PerHandlerOptionsOverrideDefaults(t *testing.T) {
+	s := NewServer(nil, WithHandlerDefaults(MaxInFlight(8), PendingLimits(100, 1024)))
+
+	cfg := s.handlerConfig([]HandlerOption{MaxInFlight(2)})
+
+	if cfg.maxInFlight != 2 || cfg.pendingMsgs != 100 || cfg.pendingBytes != 1024 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestAsyncError_ReportsSlowConsumerAndChains(t *testing.T) {
+	var reported string
+	s := NewServer(nil, WithLogger(discardLogger()), WithSlowConsumerHandler(func(subject string, _ int) {
+		reported = subject
+	}))
+
+	var chained error
+	cb := s.asyncError(func(_ *nats.Conn, _ *nats.Subscription, err error) { chained = err })
+	cb(nil, &nats.Subscription{Subject: "customers.findCustomers"}, nats.ErrSlowConsumer)
+
+	if reported != "customers.findCustomers" {
+		t.Errorf("expected slow consumer report for the subject, got %q", reported)
+	}
+	if !errors.Is(chained, nats.ErrSlowConsumer) {
+		t.Errorf("expected the previous handler to be called, got %v", chained)
+	}
+}
@@ -0,0 +1,292 @@
+// Package natsrpc exposes typed Go handlers as NestJS message patterns over
+// NATS request–reply, so services don't hand-roll the envelope per subject.
+package natsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// TimeoutHeader carries the caller's remaining budget in milliseconds.
+	// Handlers get a context with that deadline, so work is cancelled once
+	// nobody is waiting for the reply.
+	TimeoutHeader = "Nats-Rpc-Timeout"
+
+	DefaultTimeout = 30 * time.Second
+
+	shutdownPollInterval = 50 * time.Millisecond
+)
+
+// HandlerFunc handles one decoded request and returns the value to send back
+// as the envelope response.
+type HandlerFunc[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// call is one decoded request as seen by a registered handler.
+type call struct {
+	msg  *nats.Msg
+	id   string
+	data json.RawMessage
+}
+
+type handler func(ctx context.Context, c *call) (any, error)
+
+type Server struct {
+	nc      *nats.Conn
+	logger  *log.Logger
+	publish func(msg *nats.Msg) error
+	queue   string
+
+	defaultTimeout  time.Duration
+	streamChunkSize int
+	streamHighWater int
+
+	handlerDefaults []HandlerOption
+	onSlowConsumer  func(subject string, dropped int)
+	authenticator   Authenticator
+	inflight        sync.WaitGroup
+
+	mu      sync.Mutex
+	subs    []*nats.Subscription
+	closing bool
+}
+
+// ErrServerClosed is returned when registering a handler after Shutdown.
+var ErrServerClosed = errors.New("natsrpc: server closed")
+
+type Option func(*Server)
+
+// WithDefaultTimeout sets the handler deadline used when a request doesn't
+// carry a TimeoutHeader.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.defaultTimeout = d
+	}
+}
+
+// WithQueueGroup subscribes every handler in the given queue group, so each
+// request is delivered to only one of the replicas sharing the group.
+func WithQueueGroup(name string) Option {
+	return func(s *Server) {
+		s.queue = name
+	}
+}
+
+// WithLogger replaces the default stderr logger.
+func WithLogger(l *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+func NewServer(nc *nats.Conn, opts ...Option) *Server {
+	s := &Server{
+		nc:              nc,
+		logger:          log.New(os.Stderr, "[natsrpc] ", log.LstdFlags),
+		defaultTimeout:  DefaultTimeout,
+		streamChunkSize: defaultStreamChunkSize,
+		streamHighWater: defaultStreamHighWater,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if nc != nil {
+		s.publish = nc.PublishMsg
+		nc.SetErrorHandler(s.asyncError(nc.ErrorHandler()))
+	}
+	return s
+}
+
+// Handle subscribes h to subject. The envelope data is decoded into Req and
+// whatever h returns is encoded as the envelope response. Errors returned by h
+// are sent in the envelope err field; see AsError.
+func Handle[Req, Resp any](s *Server, subject string, h HandlerFunc[Req, Resp], opts ...HandlerOption) error {
+	return s.register(subject, unaryHandler(h), opts)
+}
+
+func unaryHandler[Req, Resp any](h HandlerFunc[Req, Resp]) handler {
+	return func(ctx context.Context, c *call) (any, error) {
+		var req Req
+		if err := decodeData(c.data, &req); err != nil {
+			return nil, BadRequest("Invalid request payload").Wrap(err)
+		}
+		return h(ctx, req)
+	}
+}
+
+func (s *Server) register(subject string, h handler, opts []HandlerOption) error {
+	cfg := s.handlerConfig(opts)
+	if cfg.authenticate {
+		if s.authenticator == nil {
+			return errNoAuthenticator
+		}
+		h = s.authorize(h, cfg)
+	}
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+
+	sub, err := s.nc.QueueSubscribe(subject, s.queue, s.dispatch(subject, h, cfg))
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.subs = append(s.subs, sub)
+	s.mu.Unlock()
+
+	if err := sub.SetPendingLimits(cfg.pendingMsgs, cfg.pendingBytes); err != nil {
+		return err
+	}
+
+	if s.queue != "" {
+		s.logger.Printf("Listening on %s (queue %s, %d workers)", subject, s.queue, cfg.maxInFlight)
+	} else {
+		s.logger.Printf("Listening on %s (%d workers)", subject, cfg.maxInFlight)
+	}
+	return s.nc.Flush()
+}
+
+func (s *Server) serve(subject string, msg *nats.Msg, h handler) {
+	var req Request
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		s.logger.Printf("%s: couldn't unmarshal NATS request: %v", subject, err)
+		s.reply(msg, "", nil, BadRequest("Invalid NATS request").Wrap(err))
+		return
+	}
+
+	ctx, cancel := s.requestContext(msg)
+	defer cancel()
+
+	response, err := h(ctx, &call{msg: msg, id: req.ID, data: req.Data})
+	if err != nil {
+		rpcErr := AsError(err)
+		s.logger.Printf("%s: %v", subject, rpcErr)
+		s.reply(msg, req.ID, nil, rpcErr)
+		return
+	}
+
+	// Streaming handlers have already sent their terminal message.
+	if _, ok := response.(streamed); ok {
+		return
+	}
+
+	s.reply(msg, req.ID, response, nil)
+}
+
+// Shutdown drains the server: subscriptions stop receiving new requests,
+// requests already delivered are handled and replied to by their workers, and
+// the connection is closed. If ctx expires first the connection is closed
+// immediately and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	subs := s.subs
+	s.mu.Unlock()
+
+	err := s.shutdown(ctx, subs)
+	if err != nil {
+		s.nc.Close()
+	}
+	return err
+}
+
+func (s *Server) shutdown(ctx context.Context, subs []*nats.Subscription) error {
+	for _, sub := range subs {
+		if err := sub.Drain(); err != nil {
+			return err
+		}
+	}
+
+	// Once every subscription is drained no callback can start a worker, so
+	// waiting on inflight is safe.
+	if err := s.waitUntil(ctx, func() bool {
+		for _, sub := range subs {
+			if sub.IsValid() {
+				return false
+			}
+		}
+		return true
+	}); err != nil {
+		return err
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if err := s.nc.Drain(); err != nil {
+		return err
+	}
+	return s.waitUntil(ctx, s.nc.IsClosed)
+}
+
+func (s *Server) waitUntil(ctx context.Context, done func() bool) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// requestContext derives the handler context from the message's
+// TimeoutHeader, falling back to the server default. The message headers
+// stay reachable through Header.
+func (s *Server) requestContext(msg *nats.Msg) (context.Context, context.CancelFunc) {
+	timeout := s.defaultTimeout
+	if v := msg.Header.Get(TimeoutHeader); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	ctx := WithHeader(context.Background(), msg.Header)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (s *Server) reply(msg *nats.Msg, id string, response any, rpcErr *Error) {
+	if msg.Reply == "" {
+		return
+	}
+
+	data, err := json.Marshal(Response{
+		Response:   response,
+		ID:         id,
+		IsDisposed: true,
+		Err:        rpcErr,
+	})
+	if err != nil {
+		s.logger.Printf("couldn't marshal NATS response: %v", err)
+		return
+	}
+
+	if err := s.publish(&nats.Msg{Subject: msg.Reply, Data: data}); err != nil {
+		s.logger.Printf("couldn't publish NATS response: %v", err)
+	}
+}
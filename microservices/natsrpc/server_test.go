@@ -0,0 +1,306 @@
+package natsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+type published struct {
+	subject string
+	data    []byte
+	header  nats.Header
+}
+
+func discardLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+func newTestServer(out *[]published, opts ...Option) *Server {
+	s := NewServer(nil, append([]Option{WithLogger(discardLogger())}, opts...)...)
+	s.publish = func(msg *nats.Msg) error {
+		*out = append(*out, published{subject: msg.Subject, data: msg.Data, header: msg.Header})
+		return nil
+	}
+	return s
+}
+
+func request(t *testing.T, id string, data any) *nats.Msg {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(Request{Pattern: "test.pattern", Data: raw, ID: id})
+	return &nats.Msg{Subject: "test.pattern", Reply: "_INBOX.test", Data: body}
+}
+
+func decodeResponse(t *testing.T, p published) map[string]any {
+	t.Helper()
+	var out map[string]any
+	if err := json.Unmarshal(p.data, &out); err != nil {
+		t.Fatalf("invalid response JSON: %v", err)
+	}
+	return out
+}
+
+func serveWith[Req, Resp any](s *Server, msg *nats.Msg, h HandlerFunc[Req, Resp]) {
+	s.serve("test.pattern", msg, unaryHandler(h))
+}
+
+type body struct {
+	Email string `json:"email"`
+}
+
+// ── serve ────────────────────────────────────────────────────────────────────
+
+func TestServe_DecodesTypedPayloadAndRepliesWithEnvelope(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	serveWith(s, request(t, "42", body{Email: "a@b.com"}), func(_ context.Context, req body) (string, error) {
+		return "hello " + req.Email, nil
+	})
+
+	if len(out) != 1 {
+		t.Fatalf("expected 1 reply, got %d", len(out))
+	}
+	if out[0].subject != "_INBOX.test" {
+		t.Errorf("expected reply on inbox, got %q", out[0].subject)
+	}
+	resp := decodeResponse(t, out[0])
+	if resp["id"] != "42" || resp["isDisposed"] != true || resp["err"] != nil {
+		t.Errorf("unexpected envelope: %v", resp)
+	}
+	if resp["response"] != "hello a@b.com" {
+		t.Errorf("unexpected response: %v", resp["response"])
+	}
+}
+
+func TestServe_EmptyStringDataLeavesStructZero(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	called := false
+	serveWith(s, request(t, "1", ""), func(_ context.Context, req body) (bool, error) {
+		called = true
+		return req.Email == "", nil
+	})
+
+	if !called {
+		t.Fatal("handler was not called for empty payload")
+	}
+	if resp := decodeResponse(t, out[0]); resp["response"] != true {
+		t.Errorf("expected zero value request, got response %v", resp["response"])
+	}
+}
+
+func TestServe_StringPayload(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	var got string
+	serveWith(s, request(t, "1", "ALFKI"), func(_ context.Context, id string) (any, error) {
+		got = id
+		return nil, nil
+	})
+
+	if got != "ALFKI" {
+		t.Errorf("expected ALFKI, got %q", got)
+	}
+}
+
+func TestServe_TypedErrorIsSentInErrField(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	serveWith(s, request(t, "7", body{}), func(_ context.Context, _ body) (any, error) {
+		return nil, NotFound("Customer not found").WithDetails(map[string]string{"id": "ALFKI"})
+	})
+
+	resp := decodeResponse(t, out[0])
+	if resp["id"] != "7" || resp["isDisposed"] != true {
+		t.Errorf("unexpected envelope: %v", resp)
+	}
+	if resp["response"] != nil {
+		t.Errorf("expected null response, got %v", resp["response"])
+	}
+	rpcErr, ok := resp["err"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected err object, got %v", resp["err"])
+	}
+	if rpcErr["code"] != "NOT_FOUND" || rpcErr["message"] != "Customer not found" || rpcErr["retryable"] != false {
+		t.Errorf("unexpected err: %v", rpcErr)
+	}
+	if details, _ := rpcErr["details"].(map[string]any); details["id"] != "ALFKI" {
+		t.Errorf("expected details to be forwarded, got %v", rpcErr["details"])
+	}
+}
+
+func TestServe_UntypedErrorDoesNotLeak(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	serveWith(s, request(t, "7", body{}), func(_ context.Context, _ body) (any, error) {
+		return nil, errors.New("pq: password authentication failed")
+	})
+
+	rpcErr := decodeResponse(t, out[0])["err"].(map[string]any)
+	if rpcErr["code"] != "INTERNAL" || rpcErr["message"] != "Internal server error" {
+		t.Errorf("unexpected err: %v", rpcErr)
+	}
+}
+
+func TestServe_BadPayloadIsBadRequest(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	serveWith(s, request(t, "7", []int{1, 2}), func(_ context.Context, _ body) (any, error) {
+		t.Fatal("handler must not run")
+		return nil, nil
+	})
+
+	rpcErr := decodeResponse(t, out[0])["err"].(map[string]any)
+	if rpcErr["code"] != "BAD_REQUEST" {
+		t.Errorf("expected BAD_REQUEST, got %v", rpcErr["code"])
+	}
+}
+
+func TestServe_DeadlineFromTimeoutHeader(t *testing.T) {
+	var out []published
+	s := newTestServer(&out, WithDefaultTimeout(time.Hour))
+
+	msg := request(t, "1", body{})
+	msg.Header = nats.Header{}
+	msg.Header.Set(TimeoutHeader, "250")
+
+	var remaining time.Duration
+	serveWith(s, msg, func(ctx context.Context, _ body) (any, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Fatal("expected a deadline")
+		}
+		remaining = time.Until(deadline)
+		return nil, nil
+	})
+
+	if remaining <= 0 || remaining > 250*time.Millisecond {
+		t.Errorf("expected deadline within 250ms, got %v", remaining)
+	}
+}
+
+func TestServe_DefaultDeadline(t *testing.T) {
+	var out []published
+	s := newTestServer(&out, WithDefaultTimeout(time.Minute))
+
+	var remaining time.Duration
+	serveWith(s, request(t, "1", body{}), func(ctx context.Context, _ body) (any, error) {
+		deadline, _ := ctx.Deadline()
+		remaining = time.Until(deadline)
+		return nil, nil
+	})
+
+	if remaining <= 30*time.Second || remaining > time.Minute {
+		t.Errorf("expected the configured default deadline, got %v", remaining)
+	}
+}
+
+func TestServe_ExpiredDeadlineIsTimeout(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	msg := request(t, "1", body{})
+	msg.Header = nats.Header{}
+	msg.Header.Set(TimeoutHeader, "1")
+
+	serveWith(s, msg, func(ctx context.Context, _ body) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	if rpcErr, _ := decodeResponse(t, out[0])["err"].(map[string]any); rpcErr["code"] != "TIMEOUT" {
+		t.Errorf("expected TIMEOUT, got %v", rpcErr)
+	}
+}
+
+func TestServe_ExposesHeadersAndBearerToken(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	msg := request(t, "1", body{})
+	msg.Header = nats.Header{}
+	msg.Header.Set(AuthorizationHeader, "Bearer abc.def")
+	msg.Header.Set("X-Request-Id", "r-1")
+
+	var token, requestID string
+	serveWith(s, msg, func(ctx context.Context, _ body) (any, error) {
+		token = BearerToken(ctx)
+		requestID = Header(ctx).Get("X-Request-Id")
+		return nil, nil
+	})
+
+	if token != "abc.def" || requestID != "r-1" {
+		t.Errorf("expected headers in the handler context, got token=%q id=%q", token, requestID)
+	}
+}
+
+func TestBearerToken_IgnoresOtherSchemes(t *testing.T) {
+	for _, v := range []string{"", "Basic dXNlcjpwdw==", "Bearer", "abc.def"} {
+		h := nats.Header{}
+		h.Set(AuthorizationHeader, v)
+		ctx := WithHeader(context.Background(), h)
+
+		if got := BearerToken(ctx); got != "" {
+			t.Errorf("%q: expected no token, got %q", v, got)
+		}
+	}
+}
+
+func TestClientIP_TakesTheLastForwardedAddress(t *testing.T) {
+	cases := map[string]string{
+		"":                             "",
+		"203.0.113.7":                  "203.0.113.7",
+		" 198.51.100.9 , 203.0.113.7 ": "203.0.113.7",
+	}
+	for v, want := range cases {
+		h := nats.Header{}
+		h.Set(ForwardedForHeader, v)
+		ctx := WithHeader(context.Background(), h)
+
+		if got := ClientIP(ctx); got != want {
+			t.Errorf("%q: expected %q, got %q", v, want, got)
+		}
+	}
+}
+
+func TestServe_NoReplySubjectPublishesNothing(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	msg := request(t, "1", body{})
+	msg.Reply = ""
+	serveWith(s, msg, func(_ context.Context, _ body) (any, error) { return "ok", nil })
+
+	if len(out) != 0 {
+		t.Errorf("expected no reply, got %d", len(out))
+	}
+}
+
+func TestRegister_AfterShutdownIsRejected(t *testing.T) {
+	s := newTestServer(new([]published))
+	s.closing = true
+
+	err := Handle(s, "test.pattern", func(_ context.Context, _ body) (any, error) { return nil, nil })
+
+	if !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package natsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// SeqHeader numbers the messages of a streamed reply, starting at 1. The
+	// terminal message carries the last number.
+	SeqHeader = "Nats-Rpc-Seq"
+
+	defaultStreamChunkSize = 64 * 1024
+	defaultStreamHighWater = 1024 * 1024
+
+	// envelopeOverhead is reserved in every chunk for the envelope fields
+	// around the response array.
+	envelopeOverhead = 512
+)
+
+// StreamHandlerFunc handles one decoded request by sending any number of items
+// on stream. Returning nil ends the stream; returning an error ends it with
+// the error in the envelope err field.
+type StreamHandlerFunc[Req, Item any] func(ctx context.Context, req Req, stream *Stream[Item]) error
+
+// streamed is returned by stream handlers to tell serve the reply is done.
+type streamed struct{}
+
+// WithStreamChunkSize caps the encoded size of one streamed chunk. It is
+// always kept below the server's max payload.
+func WithStreamChunkSize(bytes int) Option {
+	return func(s *Server) {
+		s.streamChunkSize = bytes
+	}
+}
+
+// WithStreamHighWater sets how many bytes may sit in the connection's
+// outbound buffer before a stream waits for the server to catch up.
+func WithStreamHighWater(bytes int) Option {
+	return func(s *Server) {
+		s.streamHighWater = bytes
+	}
+}
+
+// HandleStream subscribes h to subject and replies with a sequence of chunks.
+// Each chunk is a JSON array of items sent with isDisposed false, which the
+// NestJS ClientProxy emits as a separate value; a final isDisposed message
+// completes the observable.
+func HandleStream[Req, Item any](s *Server, subject string, h StreamHandlerFunc[Req, Item], opts ...HandlerOption) error {
+	return s.register(subject, streamHandler(s, h), opts)
+}
+
+func streamHandler[Req, Item any](s *Server, h StreamHandlerFunc[Req, Item]) handler {
+	return func(ctx context.Context, c *call) (any, error) {
+		var req Req
+		if err := decodeData(c.data, &req); err != nil {
+			return nil, BadRequest("Invalid request payload").Wrap(err)
+		}
+
+		stream := newStream[Item](ctx, s, c)
+		if err := h(ctx, req, stream); err != nil {
+			return nil, err
+		}
+		if err := stream.close(); err != nil {
+			return nil, err
+		}
+		return streamed{}, nil
+	}
+}
+
+// Stream batches items into chunks that fit in a single NATS message. It is
+// not safe for concurrent use.
+type Stream[T any] struct {
+	ctx   context.Context
+	srv   *Server
+	reply string
+	id    string
+
+	limit int
+	items []json.RawMessage
+	size  int
+	seq   int
+}
+
+func newStream[T any](ctx context.Context, s *Server, c *call) *Stream[T] {
+	limit := s.streamChunkSize
+	if s.nc != nil {
+		if max := int(s.nc.MaxPayload()) - envelopeOverhead; max < limit {
+			limit = max
+		}
+	}
+
+	return &Stream[T]{
+		ctx:   ctx,
+		srv:   s,
+		reply: c.msg.Reply,
+		id:    c.id,
+		limit: limit,
+		size:  2,
+	}
+}
+
+// Send queues item for the current chunk, publishing the chunk first if item
+// wouldn't fit. It blocks while the connection is above the high-water mark.
+func (st *Stream[T]) Send(item T) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	if len(data)+2 > st.limit {
+		return Errorf(CodeInternal, "Stream item of %d bytes exceeds the %d byte chunk limit", len(data), st.limit)
+	}
+
+	if len(st.items) > 0 && st.size+len(data)+1 > st.limit {
+		if err := st.Flush(); err != nil {
+			return err
+		}
+	}
+
+	st.items = append(st.items, data)
+	st.size += len(data) + 1
+	return nil
+}
+
+// Flush publishes the pending items as one chunk.
+func (st *Stream[T]) Flush() error {
+	if len(st.items) == 0 {
+		return nil
+	}
+	if err := st.ctx.Err(); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 0, st.size)
+	chunk = append(chunk, '[')
+	for i, item := range st.items {
+		if i > 0 {
+			chunk = append(chunk, ',')
+		}
+		chunk = append(chunk, item...)
+	}
+	chunk = append(chunk, ']')
+
+	data, err := json.Marshal(Response{
+		Response:   json.RawMessage(chunk),
+		ID:         st.id,
+		IsDisposed: false,
+	})
+	if err != nil {
+		return err
+	}
+
+	st.items = st.items[:0]
+	st.size = 2
+
+	if err := st.publish(data); err != nil {
+		return err
+	}
+	return st.srv.waitForBuffer(st.ctx)
+}
+
+// close flushes what is left and sends the terminal message. The terminal
+// message has no response field so NestJS completes without emitting null.
+func (st *Stream[T]) close() error {
+	if err := st.Flush(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(struct {
+		ID         string `json:"id"`
+		IsDisposed bool   `json:"isDisposed"`
+		Err        *Error `json:"err"`
+	}{ID: st.id, IsDisposed: true})
+	if err != nil {
+		return err
+	}
+
+	return st.publish(data)
+}
+
+func (st *Stream[T]) publish(data []byte) error {
+	if st.reply == "" {
+		return nil
+	}
+
+	st.seq++
+	msg := &nats.Msg{Subject: st.reply, Data: data, Header: nats.Header{}}
+	msg.Header.Set(SeqHeader, strconv.Itoa(st.seq))
+
+	return st.srv.publish(msg)
+}
+
+// waitForBuffer applies back-pressure: once the outbound buffer passes the
+// high-water mark, the producer blocks until the server has read it.
+func (s *Server) waitForBuffer(ctx context.Context) error {
+	if s.nc == nil {
+		return nil
+	}
+
+	buffered, err := s.nc.Buffered()
+	if err != nil || buffered < s.streamHighWater {
+		return err
+	}
+
+	return s.nc.FlushWithContext(ctx)
+}
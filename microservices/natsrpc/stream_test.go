@@ -0,0 +1,140 @@
+package natsrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+func serveStream[Req, Item any](s *Server, msg *nats.Msg, h StreamHandlerFunc[Req, Item]) {
+	s.serve("test.stream", msg, streamHandler(s, h))
+}
+
+type row struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ── HandleStream ─────────────────────────────────────────────────────────────
+
+func TestStream_SplitsIntoSequencedChunksAndTerminates(t *testing.T) {
+	var out []published
+	s := newTestServer(&out, WithStreamChunkSize(100))
+
+	serveStream(s, request(t, "9", ""), func(_ context.Context, _ struct{}, st *Stream[row]) error {
+		for i := 0; i < 20; i++ {
+			if err := st.Send(row{ID: i, Name: "customer"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if len(out) < 3 {
+		t.Fatalf("expected several chunks plus a terminal message, got %d messages", len(out))
+	}
+
+	var got []row
+	for i, p := range out {
+		if len(p.data) > 100+envelopeOverhead {
+			t.Errorf("message %d is %d bytes", i, len(p.data))
+		}
+		if seq := p.header.Get(SeqHeader); seq != strconv.Itoa(i+1) {
+			t.Errorf("message %d: expected seq %d, got %q", i, i+1, seq)
+		}
+
+		resp := decodeResponse(t, p)
+		if resp["id"] != "9" {
+			t.Errorf("message %d: unexpected id %v", i, resp["id"])
+		}
+
+		last := i == len(out)-1
+		if resp["isDisposed"] != last {
+			t.Errorf("message %d: expected isDisposed=%v", i, last)
+		}
+		if last {
+			if _, ok := resp["response"]; ok {
+				t.Errorf("terminal message must not carry a response, got %v", resp["response"])
+			}
+			continue
+		}
+
+		var chunk struct {
+			Response []row `json:"response"`
+		}
+		json.Unmarshal(p.data, &chunk)
+		got = append(got, chunk.Response...)
+	}
+
+	if len(got) != 20 {
+		t.Fatalf("expected 20 rows across chunks, got %d", len(got))
+	}
+	for i, r := range got {
+		if r.ID != i {
+			t.Fatalf("rows out of order at %d: %+v", i, r)
+		}
+	}
+}
+
+func TestStream_EmptyStreamOnlySendsTerminal(t *testing.T) {
+	var out []published
+	s := newTestServer(&out)
+
+	serveStream(s, request(t, "1", ""), func(_ context.Context, _ struct{}, _ *Stream[row]) error {
+		return nil
+	})
+
+	if len(out) != 1 || decodeResponse(t, out[0])["isDisposed"] != true {
+		t.Fatalf("expected only a terminal message, got %d", len(out))
+	}
+}
+
+func TestStream_ErrorMidStreamEndsWithErr(t *testing.T) {
+	var out []published
+	s := newTestServer(&out, WithStreamChunkSize(40))
+
+	serveStream(s, request(t, "1", ""), func(_ context.Context, _ struct{}, st *Stream[row]) error {
+		st.Send(row{ID: 1, Name: "a"})
+		st.Send(row{ID: 2, Name: "b"})
+		return errors.New("connection reset")
+	})
+
+	resp := decodeResponse(t, out[len(out)-1])
+	if resp["isDisposed"] != true {
+		t.Error("expected the error message to dispose the stream")
+	}
+	if rpcErr, _ := resp["err"].(map[string]any); rpcErr["code"] != "INTERNAL" {
+		t.Errorf("expected INTERNAL err, got %v", resp["err"])
+	}
+}
+
+func TestStream_RejectsItemLargerThanChunk(t *testing.T) {
+	s := newTestServer(new([]published), WithStreamChunkSize(16))
+	st := newStream[row](context.Background(), s, &call{msg: &nats.Msg{Reply: "_INBOX.x"}})
+
+	err := st.Send(row{ID: 1, Name: "far too long to fit"})
+
+	if err == nil {
+		t.Fatal("expected an error for an oversized item")
+	}
+}
+
+func TestStream_StopsWhenContextIsCancelled(t *testing.T) {
+	s := newTestServer(new([]published), WithStreamChunkSize(40))
+	ctx, cancel := context.WithCancel(context.Background())
+	st := newStream[row](ctx, s, &call{msg: &nats.Msg{Reply: "_INBOX.x"}})
+	cancel()
+
+	st.Send(row{ID: 1, Name: "a"})
+	err := st.Flush()
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 import { Test, TestingModule } from '@nestjs/testing';
 import { AuthController } from './auth.controller';
+import { JwtAuthGuard } from 'src/guards/jwt.guard';
 import { of, throwError } from 'rxjs';
-import { Response } from 'express';
+import { Request, Response } from 'express';
+import { REQUEST_TIMEOUT_MS } from 'src/rpc';
 
 const mockClientProxy = {
   send: jest.fn(),
@@ -11,8 +13,12 @@ const mockResponse = (): Partial<Response> => ({
   status: jest.fn().mockReturnThis(),
   send: jest.fn().mockReturnThis(),
   cookie: jest.fn().mockReturnThis(),
+  clearCookie: jest.fn().mockReturnThis(),
+  setHeader: jest.fn().mockReturnThis(),
 });
 
+const loginReq = { ip: '203.0.113.7', headers: {} } as Request;
+
 describe('AuthController', () => {
   let controller: AuthController;
 
@@ -20,7 +26,10 @@ describe('AuthController', () => {
     const module: TestingModule = await Test.createTestingModule({
       controllers: [AuthController],
       providers: [{ provide: 'NATS_SERVICE', useValue: mockClientProxy }],
-    }).compile();
+    })
+      .overrideGuard(JwtAuthGuard)
+      .useValue({ canActivate: () => true })
+      .compile();
 
     controller = module.get<AuthController>(AuthController);
     jest.clearAllMocks();
@@ -35,12 +44,18 @@ describe('AuthController', () => {
       );
       const res = mockResponse() as Response;
 
-      controller.login({ email: 'user@test.com', password: 'pass' }, res);
+      controller.login(
+        { email: 'user@test.com', password: 'pass' },
+        loginReq,
+        res,
+      );
 
-      expect(mockClientProxy.send).toHaveBeenCalledWith('auth.loginUser', {
-        email: 'user@test.com',
-        password: 'pass',
-      });
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.loginUser',
+        expect.objectContaining({
+          data: { email: 'user@test.com', password: 'pass' },
+        }),
+      );
       expect(res.cookie).toHaveBeenCalledWith(
         'cookie',
         token,
@@ -56,21 +71,142 @@ describe('AuthController', () => {
       );
       const res = mockResponse() as Response;
 
-      controller.login({ email: 'bad@test.com', password: 'wrong' }, res);
+      controller.login(
+        { email: 'bad@test.com', password: 'wrong' },
+        loginReq,
+        res,
+      );
 
       expect(res.cookie).not.toHaveBeenCalled();
       expect(res.status).toHaveBeenCalledWith(200);
       expect(res.send).toHaveBeenCalledWith('Invalid credentials');
     });
 
+    it('responds 401 when the auth service rejects the credentials', () => {
+      mockClientProxy.send.mockReturnValue(
+        throwError(() => ({ code: 'UNAUTHENTICATED', message: 'Invalid' })),
+      );
+      const res = mockResponse() as Response;
+
+      controller.login(
+        { email: 'bad@test.com', password: 'wrong' },
+        loginReq,
+        res,
+      );
+
+      expect(res.cookie).not.toHaveBeenCalled();
+      expect(res.status).toHaveBeenCalledWith(401);
+    });
+
     it('forwards to NATS subject auth.loginUser', () => {
       mockClientProxy.send.mockReturnValue(of({ context: false, message: '' }));
       const res = mockResponse() as Response;
       const body = { email: 'a@b.com', password: '123' };
 
-      controller.login(body, res);
+      controller.login(body, loginReq, res);
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.loginUser',
+        expect.objectContaining({ data: body }),
+      );
+    });
+
+    it('sends the gateway deadline as Nats-Rpc-Timeout', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: false, message: '' }));
+      const res = mockResponse() as Response;
+
+      controller.login({ email: 'a@b.com', password: '123' }, loginReq, res);
+
+      const [, record] = mockClientProxy.send.mock.calls[0];
+      expect(record.headers.get('Nats-Rpc-Timeout')).toBe(
+        String(REQUEST_TIMEOUT_MS),
+      );
+    });
+
+    it('forwards the client address as X-Forwarded-For', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: false, message: '' }));
+      const res = mockResponse() as Response;
+
+      controller.login({ email: 'a@b.com', password: '123' }, loginReq, res);
+
+      const [, record] = mockClientProxy.send.mock.calls[0];
+      expect(record.headers.get('X-Forwarded-For')).toBe('203.0.113.7');
+    });
+
+    it('responds 429 with Retry-After when the account is locked', () => {
+      mockClientProxy.send.mockReturnValue(
+        throwError(() => ({
+          code: 'ACCOUNT_LOCKED',
+          message: 'Too many failed logins',
+          details: { retry_after: 60 },
+        })),
+      );
+      const res = mockResponse() as Response;
+
+      controller.login({ email: 'a@b.com', password: '123' }, loginReq, res);
+
+      expect(res.setHeader).toHaveBeenCalledWith('Retry-After', '60');
+      expect(res.status).toHaveBeenCalledWith(429);
+    });
+  });
+
+  // ── refresh ──────────────────────────────────────────────────────────────
+  describe('POST /auth/refresh', () => {
+    const pair = {
+      context: true,
+      message: 'new.access.token',
+      refresh_token: 'new-refresh',
+    };
+
+    it('forwards the refresh cookie and sets the rotated cookies', () => {
+      mockClientProxy.send.mockReturnValue(of(pair));
+      const res = mockResponse() as Response;
+      const req = {
+        body: {},
+        headers: { cookie: 'cookie=old; refresh_token=old-refresh' },
+      } as Request;
+
+      controller.refresh(req, res);
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.refreshToken',
+        expect.objectContaining({ data: { refresh_token: 'old-refresh' } }),
+      );
+      expect(res.cookie).toHaveBeenCalledWith(
+        'refresh_token',
+        'new-refresh',
+        expect.objectContaining({ httpOnly: true }),
+      );
+      expect(res.status).toHaveBeenCalledWith(200);
+      expect(res.send).toHaveBeenCalledWith('new.access.token');
+    });
+
+    it('prefers a refresh token from the body', () => {
+      mockClientProxy.send.mockReturnValue(of(pair));
+      const res = mockResponse() as Response;
+      const req = {
+        body: { refresh_token: 'from-body' },
+        headers: {},
+      } as Request;
+
+      controller.refresh(req, res);
 
-      expect(mockClientProxy.send).toHaveBeenCalledWith('auth.loginUser', body);
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.refreshToken',
+        expect.objectContaining({ data: { refresh_token: 'from-body' } }),
+      );
+    });
+
+    it('responds 401 when the refresh token is rejected', () => {
+      mockClientProxy.send.mockReturnValue(
+        throwError(() => ({ code: 'UNAUTHENTICATED', message: 'Invalid' })),
+      );
+      const res = mockResponse() as Response;
+
+      controller.refresh({ body: {}, headers: {} } as Request, res);
+
+      expect(res.cookie).not.toHaveBeenCalled();
+      expect(res.status).toHaveBeenCalledWith(401);
     });
   });
 
@@ -89,18 +225,23 @@ describe('AuthController', () => {
         res,
       );
 
-      expect(mockClientProxy.send).toHaveBeenCalledWith('auth.registerUser', {
-        username: 'newuser',
-        email: 'new@test.com',
-        password: 'pass',
-      });
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.registerUser',
+        expect.objectContaining({
+          data: {
+            username: 'newuser',
+            email: 'new@test.com',
+            password: 'pass',
+          },
+        }),
+      );
       expect(res.status).toHaveBeenCalledWith(200);
       expect(res.send).toHaveBeenCalledWith(successResponse);
     });
 
-    it('returns 404 when NATS emits an error', () => {
+    it('maps the RPC error code to an HTTP status', () => {
       mockClientProxy.send.mockReturnValue(
-        throwError(() => ({ message: 'Email exists' })),
+        throwError(() => ({ code: 'CONFLICT', message: 'Email exists' })),
       );
       const res = mockResponse() as Response;
 
@@ -109,7 +250,7 @@ describe('AuthController', () => {
         res,
       );
 
-      expect(res.status).toHaveBeenCalledWith(404);
+      expect(res.status).toHaveBeenCalledWith(409);
     });
 
     it('forwards to NATS subject auth.registerUser', () => {
@@ -121,8 +262,235 @@ describe('AuthController', () => {
 
       expect(mockClientProxy.send).toHaveBeenCalledWith(
         'auth.registerUser',
-        body,
+        expect.objectContaining({ data: body }),
       );
     });
   });
+
+  // ── password reset ───────────────────────────────────────────────────────
+  describe('POST /auth/password', () => {
+    it('forgot sends auth.requestPasswordReset with the email', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: true }));
+
+      controller.forgotPassword({ email: 'a@b.com' });
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.requestPasswordReset',
+        expect.objectContaining({ data: { email: 'a@b.com' } }),
+      );
+    });
+
+    it('reset sends auth.resetPassword with the token and password', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: true }));
+      const body = { token: 'reset-token', password: 'a new password' };
+
+      controller.resetPassword(body);
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.resetPassword',
+        expect.objectContaining({ data: body }),
+      );
+    });
+  });
+
+  // ── email verification ───────────────────────────────────────────────────
+  describe('POST /auth/email', () => {
+    it('verify sends auth.verifyEmail with the token', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: true }));
+
+      controller.verifyEmail({ token: 'verify-token' });
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.verifyEmail',
+        expect.objectContaining({ data: { token: 'verify-token' } }),
+      );
+    });
+
+    it('resend sends auth.resendVerification with the email', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: true }));
+
+      controller.resendVerification({ email: 'a@b.com' });
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.resendVerification',
+        expect.objectContaining({ data: { email: 'a@b.com' } }),
+      );
+    });
+  });
+
+  // ── MFA ──────────────────────────────────────────────────────────────────
+  describe('POST /auth/mfa', () => {
+    const bearerReq = {
+      ip: '203.0.113.7',
+      headers: { authorization: 'Bearer access-token' },
+    } as Request;
+
+    it('login answers the challenge without setting cookies', () => {
+      mockClientProxy.send.mockReturnValue(
+        of({
+          context: true,
+          message: 'Enter the code from your authenticator app',
+          mfa_required: true,
+          mfa_token: 'challenge',
+          expires_in: 300,
+        }),
+      );
+      const res = mockResponse() as Response;
+
+      controller.login(
+        { email: 'user@test.com', password: 'pass' },
+        loginReq,
+        res,
+      );
+
+      expect(res.cookie).not.toHaveBeenCalled();
+      expect(res.send).toHaveBeenCalledWith({
+        mfa_required: true,
+        mfa_token: 'challenge',
+        expires_in: 300,
+      });
+    });
+
+    it('verify redeems the challenge and sets the session cookies', () => {
+      mockClientProxy.send.mockReturnValue(
+        of({ context: true, message: 'jwt', refresh_token: 'refresh' }),
+      );
+      const res = mockResponse() as Response;
+
+      controller.verifyMfa(
+        { mfa_token: 'challenge', code: '123456' },
+        loginReq,
+        res,
+      );
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.mfa.verify',
+        expect.objectContaining({
+          data: { mfa_token: 'challenge', code: '123456' },
+        }),
+      );
+      expect(res.cookie).toHaveBeenCalledWith(
+        'refresh_token',
+        'refresh',
+        expect.any(Object),
+      );
+      expect(res.send).toHaveBeenCalledWith('jwt');
+    });
+
+    it('verify responds 401 for a wrong code', () => {
+      mockClientProxy.send.mockReturnValue(
+        throwError(() => ({ code: 'UNAUTHENTICATED', message: 'Invalid' })),
+      );
+      const res = mockResponse() as Response;
+
+      controller.verifyMfa(
+        { mfa_token: 'challenge', code: '000000' },
+        loginReq,
+        res,
+      );
+
+      expect(res.cookie).not.toHaveBeenCalled();
+      expect(res.status).toHaveBeenCalledWith(401);
+    });
+
+    it('enroll, confirm and disable forward the bearer token', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: true }));
+
+      controller.enrollMfa(bearerReq);
+      controller.confirmMfa({ code: '123456' }, bearerReq);
+      controller.disableMfa({ code: '123456' }, bearerReq);
+
+      for (const [pattern, data] of [
+        ['auth.mfa.enroll', {}],
+        ['auth.mfa.confirm', { code: '123456' }],
+        ['auth.mfa.disable', { code: '123456' }],
+      ]) {
+        expect(mockClientProxy.send).toHaveBeenCalledWith(
+          pattern,
+          expect.objectContaining({ data }),
+        );
+      }
+      const record = mockClientProxy.send.mock.calls[0][1];
+      expect(record.headers.get('Authorization')).toBe('Bearer access-token');
+    });
+  });
+
+  // ── account ──────────────────────────────────────────────────────────────
+  describe('/auth/me', () => {
+    const bearerReq = {
+      headers: { authorization: 'Bearer access-token' },
+    } as Request;
+
+    it('profile and credential changes forward the bearer token', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: true }));
+      const email = { email: 'new@test.com', password: 'pass' };
+      const password = {
+        current_password: 'pass',
+        new_password: 'correct horse',
+      };
+
+      controller.getMe(bearerReq);
+      controller.updateProfile({ display_name: 'Ada' }, bearerReq);
+      controller.changeEmail(email, bearerReq);
+      controller.changePassword(password, bearerReq);
+
+      for (const [pattern, data] of [
+        ['auth.getMe', {}],
+        ['auth.updateProfile', { display_name: 'Ada' }],
+        ['auth.changeEmail', email],
+        ['auth.changePassword', password],
+      ]) {
+        expect(mockClientProxy.send).toHaveBeenCalledWith(
+          pattern,
+          expect.objectContaining({ data }),
+        );
+      }
+      const record = mockClientProxy.send.mock.calls[0][1];
+      expect(record.headers.get('Authorization')).toBe('Bearer access-token');
+    });
+
+    it('confirm email change sends the token without a session', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: true }));
+
+      controller.confirmEmailChange({ token: 'change-token' });
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.confirmEmailChange',
+        expect.objectContaining({ data: { token: 'change-token' } }),
+      );
+    });
+
+    it('delete clears the session cookies', () => {
+      mockClientProxy.send.mockReturnValue(of({ context: true }));
+      const res = mockResponse() as Response;
+
+      controller.deleteAccount({ password: 'pass' }, bearerReq, res);
+
+      expect(mockClientProxy.send).toHaveBeenCalledWith(
+        'auth.deleteAccount',
+        expect.objectContaining({ data: { password: 'pass' } }),
+      );
+      expect(res.clearCookie).toHaveBeenCalledWith(
+        'cookie',
+        expect.any(Object),
+      );
+      expect(res.clearCookie).toHaveBeenCalledWith(
+        'refresh_token',
+        expect.any(Object),
+      );
+      expect(res.status).toHaveBeenCalledWith(200);
+    });
+
+    it('delete responds 401 for a wrong password', () => {
+      mockClientProxy.send.mockReturnValue(
+        throwError(() => ({ code: 'UNAUTHENTICATED', message: 'Invalid' })),
+      );
+      const res = mockResponse() as Response;
+
+      controller.deleteAccount({ password: 'wrong' }, bearerReq, res);
+
+      expect(res.clearCookie).not.toHaveBeenCalled();
+      expect(res.status).toHaveBeenCalledWith(401);
+    });
+  });
 });
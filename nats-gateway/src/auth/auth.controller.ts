@@ -1,8 +1,62 @@
-import { Body, Controller, Inject, Post, Res } from '@nestjs/common';
+import {
+  Body,
+  Controller,
+  Delete,
+  Get,
+  Inject,
+  Patch,
+  Post,
+  Req,
+  Res,
+  UseGuards,
+} from '@nestjs/common';
 import { ClientProxy } from '@nestjs/microservices';
 import { RegisterDto } from './dto/register-auth.dto';
 import { LoginDto } from './dto/login-auth.dto';
-import { Response } from 'express';
+import {
+  ForgotPasswordDto,
+  ResetPasswordDto,
+} from './dto/password-reset.dto';
+import {
+  ResendVerificationDto,
+  VerifyEmailDto,
+} from './dto/verify-email.dto';
+import { MfaCodeDto, MfaVerifyDto } from './dto/mfa.dto';
+import {
+  ChangeEmailDto,
+  ChangePasswordDto,
+  ConfirmEmailChangeDto,
+  DeleteAccountDto,
+  UpdateProfileDto,
+} from './dto/account.dto';
+import { Request, Response } from 'express';
+import { sendRpcError } from 'src/filters/rpc-error.filter';
+import { JwtAuthGuard, withAuthorization } from 'src/guards/jwt.guard';
+import { rpcRecord } from 'src/rpc';
+import { headers } from 'nats';
+
+const REFRESH_COOKIE = 'refresh_token';
+
+// readCookie avoids pulling in cookie-parser for the one cookie we read.
+function readCookie(req: Request, name: string): string | undefined {
+  for (const part of (req.headers.cookie ?? '').split(';')) {
+    const [key, ...value] = part.trim().split('=');
+    if (key === name) {
+      return decodeURIComponent(value.join('='));
+    }
+  }
+  return undefined;
+}
+
+// withClientIP tells the auth service who is logging in, so failed logins
+// can be throttled per address.
+function withClientIP<T>(req: Request, data: T) {
+  const h = headers();
+  if (req.ip) {
+    h.set('X-Forwarded-For', req.ip);
+  }
+  return rpcRecord(data, h);
+}
 
 @Controller('auth')
 export class AuthController {
@@ -11,30 +65,190 @@ export class AuthController {
   ) {}
 
   @Post('login')
-  login(@Body() body: LoginDto, @Res() res: Response) {
-    return this.clientProxy.send('auth.loginUser', body).subscribe({
+  login(@Body() body: LoginDto, @Req() req: Request, @Res() res: Response) {
+    const record = withClientIP(req, body);
+    return this.clientProxy.send('auth.loginUser', record).subscribe({
       next: (response) => {
+        // With MFA enabled the password only earns a challenge, redeemed at
+        // POST /auth/mfa/verify.
+        if (response.mfa_required) {
+          const { mfa_required, mfa_token, expires_in } = response;
+          return res.status(200).send({ mfa_required, mfa_token, expires_in });
+        }
         const { context, message } = response;
         if (context) {
-          res.cookie('cookie', message, { httpOnly: false, path: '/' });
+          this.setSessionCookies(res, response);
         }
         return res.status(200).send(message);
       },
       error: (err) => {
-        console.log(err);
+        return sendRpcError(res, err);
+      },
+    });
+  }
+
+  @Post('mfa/verify')
+  verifyMfa(
+    @Body() body: MfaVerifyDto,
+    @Req() req: Request,
+    @Res() res: Response,
+  ) {
+    const record = withClientIP(req, body);
+    return this.clientProxy.send('auth.mfa.verify', record).subscribe({
+      next: (response) => {
+        this.setSessionCookies(res, response);
+        return res.status(200).send(response.message);
+      },
+      error: (err) => {
+        return sendRpcError(res, err);
       },
     });
   }
 
+  @UseGuards(JwtAuthGuard)
+  @Post('mfa/enroll')
+  enrollMfa(@Req() req: Request) {
+    return this.clientProxy.send('auth.mfa.enroll', withAuthorization(req, {}));
+  }
+
+  // The answer holds the recovery codes; they are not shown again.
+  @UseGuards(JwtAuthGuard)
+  @Post('mfa/confirm')
+  confirmMfa(@Body() body: MfaCodeDto, @Req() req: Request) {
+    return this.clientProxy.send(
+      'auth.mfa.confirm',
+      withAuthorization(req, body),
+    );
+  }
+
+  @UseGuards(JwtAuthGuard)
+  @Post('mfa/disable')
+  disableMfa(@Body() body: MfaCodeDto, @Req() req: Request) {
+    return this.clientProxy.send(
+      'auth.mfa.disable',
+      withAuthorization(req, body),
+    );
+  }
+
+  @Post('refresh')
+  refresh(@Req() req: Request, @Res() res: Response) {
+    const refreshToken =
+      req.body?.refresh_token ?? readCookie(req, REFRESH_COOKIE);
+    return this.clientProxy
+      .send('auth.refreshToken', rpcRecord({ refresh_token: refreshToken }))
+      .subscribe({
+        next: (response) => {
+          this.setSessionCookies(res, response);
+          return res.status(200).send(response.message);
+        },
+        error: (err) => {
+          return sendRpcError(res, err);
+        },
+      });
+  }
+
   @Post('register')
   register(@Body() body: RegisterDto, @Res() res: Response) {
-    return this.clientProxy.send('auth.registerUser', body).subscribe({
+    const record = rpcRecord(body);
+    return this.clientProxy.send('auth.registerUser', record).subscribe({
       next: (response) => {
         return res.status(200).send(response);
       },
       error: (err) => {
-        return res.status(404).send(err);
+        return sendRpcError(res, err);
       },
     });
   }
+
+  // The answer is the same whether or not the email is registered.
+  @Post('password/forgot')
+  forgotPassword(@Body() body: ForgotPasswordDto) {
+    return this.clientProxy.send('auth.requestPasswordReset', rpcRecord(body));
+  }
+
+  @Post('password/reset')
+  resetPassword(@Body() body: ResetPasswordDto) {
+    return this.clientProxy.send('auth.resetPassword', rpcRecord(body));
+  }
+
+  @Post('email/verify')
+  verifyEmail(@Body() body: VerifyEmailDto) {
+    return this.clientProxy.send('auth.verifyEmail', rpcRecord(body));
+  }
+
+  @Post('email/resend')
+  resendVerification(@Body() body: ResendVerificationDto) {
+    return this.clientProxy.send('auth.resendVerification', rpcRecord(body));
+  }
+
+  @UseGuards(JwtAuthGuard)
+  @Get('me')
+  getMe(@Req() req: Request) {
+    return this.clientProxy.send('auth.getMe', withAuthorization(req, {}));
+  }
+
+  @UseGuards(JwtAuthGuard)
+  @Patch('me')
+  updateProfile(@Body() body: UpdateProfileDto, @Req() req: Request) {
+    return this.clientProxy.send(
+      'auth.updateProfile',
+      withAuthorization(req, body),
+    );
+  }
+
+  // The new address only takes over once the link mailed to it is used.
+  @UseGuards(JwtAuthGuard)
+  @Post('email/change')
+  changeEmail(@Body() body: ChangeEmailDto, @Req() req: Request) {
+    return this.clientProxy.send(
+      'auth.changeEmail',
+      withAuthorization(req, body),
+    );
+  }
+
+  @Post('email/change/confirm')
+  confirmEmailChange(@Body() body: ConfirmEmailChangeDto) {
+    return this.clientProxy.send('auth.confirmEmailChange', rpcRecord(body));
+  }
+
+  // Every other session is signed out; this one stays.
+  @UseGuards(JwtAuthGuard)
+  @Post('password/change')
+  changePassword(@Body() body: ChangePasswordDto, @Req() req: Request) {
+    return this.clientProxy.send(
+      'auth.changePassword',
+      withAuthorization(req, body),
+    );
+  }
+
+  @UseGuards(JwtAuthGuard)
+  @Delete('me')
+  deleteAccount(
+    @Body() body: DeleteAccountDto,
+    @Req() req: Request,
+    @Res() res: Response,
+  ) {
+    const record = withAuthorization(req, body);
+    return this.clientProxy.send('auth.deleteAccount', record).subscribe({
+      next: (response) => {
+        res.clearCookie('cookie', { path: '/' });
+        res.clearCookie(REFRESH_COOKIE, { path: '/auth' });
+        return res.status(200).send(response);
+      },
+      error: (err) => {
+        return sendRpcError(res, err);
+      },
+    });
+  }
+
+  private setSessionCookies(res: Response, response: any) {
+    res.cookie('cookie', response.message, { httpOnly: false, path: '/' });
+    if (response.refresh_token) {
+      res.cookie(REFRESH_COOKIE, response.refresh_token, {
+        httpOnly: true,
+        path: '/auth',
+        sameSite: 'strict',
+      });
+    }
+  }
 }
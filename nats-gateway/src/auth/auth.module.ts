@@ -1,19 +1,10 @@
 import { Module } from '@nestjs/common';
 import { ClientsModule, Transport } from '@nestjs/microservices';
 import { AuthController } from './auth.controller';
-import { PassportModule } from '@nestjs/passport';
-import { JwtModule } from '@nestjs/jwt';
-import { JwtStrategy } from './strategies/local.strategy';
 import { JwtAuthGuard } from 'src/guards/jwt.guard';
 
 @Module({
   imports: [
-    PassportModule,
-    JwtModule.register({
-      secret: process.env.JWT_SECRET_KEY,
-      signOptions: { expiresIn: '1h' },
-    }),
-
     ClientsModule.register([
       {
         name: 'NATS_SERVICE',
@@ -28,6 +19,6 @@ import { JwtAuthGuard } from 'src/guards/jwt.guard';
     ]),
   ],
   controllers: [AuthController],
-  providers: [JwtStrategy, JwtAuthGuard],
+  providers: [JwtAuthGuard],
 })
 export class AuthModule {}
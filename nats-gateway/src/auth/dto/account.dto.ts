@@ -0,0 +1,47 @@
+import { IsNotEmpty, IsOptional, IsString } from 'class-validator';
+
+export class UpdateProfileDto {
+  @IsOptional()
+  @IsString()
+  username?: string;
+
+  @IsOptional()
+  @IsString()
+  display_name?: string;
+}
+
+export class ChangeEmailDto {
+  @IsNotEmpty()
+  @IsString()
+  email: string;
+
+  @IsNotEmpty()
+  @IsString()
+  password: string;
+}
+
+export class ConfirmEmailChangeDto {
+  @IsNotEmpty()
+  @IsString()
+  token: string;
+}
+
+export class ChangePasswordDto {
+  @IsNotEmpty()
+  @IsString()
+  current_password: string;
+
+  @IsNotEmpty()
+  @IsString()
+  new_password: string;
+}
+
+export class DeleteAccountDto {
+  @IsNotEmpty()
+  @IsString()
+  password: string;
+
+  @IsOptional()
+  @IsString()
+  code?: string;
+}
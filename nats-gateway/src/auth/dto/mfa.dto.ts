@@ -0,0 +1,17 @@
+import { IsNotEmpty, IsString } from 'class-validator';
+
+export class MfaCodeDto {
+  @IsNotEmpty()
+  @IsString()
+  code: string;
+}
+
+export class MfaVerifyDto {
+  @IsNotEmpty()
+  @IsString()
+  mfa_token: string;
+
+  @IsNotEmpty()
+  @IsString()
+  code: string;
+}
@@ -0,0 +1,13 @@
+import { IsNotEmpty, IsString } from 'class-validator';
+
+export class VerifyEmailDto {
+  @IsNotEmpty()
+  @IsString()
+  token: string;
+}
+
+export class ResendVerificationDto {
+  @IsNotEmpty()
+  @IsString()
+  email: string;
+}
@@ -1,21 +0,0 @@
-import { Injectable } from '@nestjs/common';
-import { PassportStrategy } from '@nestjs/passport';
-import { ExtractJwt, Strategy } from 'passport-jwt';
-
-@Injectable()
-export class JwtStrategy extends PassportStrategy(Strategy) {
-  constructor() {
-    super({
-      jwtFromRequest: ExtractJwt.fromAuthHeaderAsBearerToken(),
-      ignoreExpiration: false,
-      secretOrKey: process.env.JWT_SECRET_KEY,
-      usernameField: 'email',
-    });
-  }
-
-  async validate(payload: any) {
-    console.log('Hello world');
-    console.log(payload);
-    return { userId: payload.sub, username: payload.username };
-  }
-}
@@ -1,10 +1,15 @@
 import { Test, TestingModule } from '@nestjs/testing';
 import { CustomersController } from './customers.controller';
 import { JwtAuthGuard } from 'src/guards/jwt.guard';
-import { of } from 'rxjs';
+import { from, of } from 'rxjs';
 
 const mockClientProxy = { send: jest.fn() };
 
+const req: any = { headers: { authorization: 'Bearer abc.def' } };
+
+// record matches the NATS record wrapping the payload.
+const record = (data: unknown) => expect.objectContaining({ data });
+
 const sample = {
   customer_id: 'ABCD',
   contact_name: 'Alice',
@@ -28,22 +33,35 @@ describe('CustomersController', () => {
     jest.clearAllMocks();
   });
 
-  it('getCustomers() sends customers.findCustomers', () => {
-    mockClientProxy.send.mockReturnValue(of([sample]));
-    const result = controller.getCustomers();
+  it('getCustomers() sends customers.findCustomers with paging and filters', () => {
+    const page = { data: [sample], total: 1, page: 1, limit: 20, pages: 1 };
+    mockClientProxy.send.mockReturnValue(of(page));
+    const result = controller.getCustomers(1, 20, { city_prefix: 'Ca' }, req);
     expect(mockClientProxy.send).toHaveBeenCalledWith(
       'customers.findCustomers',
-      '',
+      record({ city_prefix: 'Ca', page: 1, limit: 20 }),
+    );
+    result.subscribe((r) => expect(r).toEqual(page));
+  });
+
+  it('exportCustomers() concatenates streamed chunks', (done) => {
+    mockClientProxy.send.mockReturnValue(from([[sample], [sample, sample]]));
+    controller.exportCustomers({ country: 'Egypt' }, req).subscribe((r) => {
+      expect(r).toEqual([sample, sample, sample]);
+      done();
+    });
+    expect(mockClientProxy.send).toHaveBeenCalledWith(
+      'customers.exportCustomers',
+      record({ country: 'Egypt' }),
     );
-    result.subscribe((r) => expect(r).toEqual([sample]));
   });
 
   it('getCustomer() sends customers.findCustomer with id', () => {
     mockClientProxy.send.mockReturnValue(of(sample));
-    const result = controller.getCustomer('ABCD');
+    const result = controller.getCustomer('ABCD', req);
     expect(mockClientProxy.send).toHaveBeenCalledWith(
       'customers.findCustomer',
-      'ABCD',
+      record('ABCD'),
     );
     result.subscribe((r) => expect(r).toEqual(sample));
   });
@@ -56,10 +74,10 @@ describe('CustomersController', () => {
       city: 'Cairo',
       country: 'Egypt',
     };
-    const result = controller.createCustomer(dto);
+    const result = controller.createCustomer(dto, req);
     expect(mockClientProxy.send).toHaveBeenCalledWith(
       'customers.createCustomer',
-      dto,
+      record(dto),
     );
     result.subscribe((r) => expect(r).toEqual(sample));
   });
@@ -67,25 +85,32 @@ describe('CustomersController', () => {
   it('updateCustomer() sends customers.updateCustomer with data and id', () => {
     mockClientProxy.send.mockReturnValue(of({ ...sample, city: 'Alex' }));
     const dto = { city: 'Alex' };
-    controller.updateCustomer(dto, 'ABCD');
+    controller.updateCustomer(dto, 'ABCD', req);
     expect(mockClientProxy.send).toHaveBeenCalledWith(
       'customers.updateCustomer',
-      {
+      record({
         customer: dto,
         id: 'ABCD',
-      },
+      }),
     );
   });
 
-  it('deleteCustomer() sends customers.deleteCustomer', () => {
-    mockClientProxy.send.mockReturnValue(of(null));
-    controller.deleteCustomer();
+  it('deleteCustomer() sends customers.deleteCustomer with id', () => {
+    mockClientProxy.send.mockReturnValue(of({ customer_id: 'ABCD' }));
+    controller.deleteCustomer('ABCD', req);
     expect(mockClientProxy.send).toHaveBeenCalledWith(
       'customers.deleteCustomer',
-      '',
+      record('ABCD'),
     );
   });
 
+  it('forwards the bearer token as a NATS header', () => {
+    mockClientProxy.send.mockReturnValue(of(sample));
+    controller.getCustomer('ABCD', req);
+    const [, sent] = mockClientProxy.send.mock.calls[0];
+    expect(sent.headers.get('Authorization')).toBe('Bearer abc.def');
+  });
+
   it('JwtAuthGuard is applied on the controller', () => {
     const guards = Reflect.getMetadata('__guards__', CustomersController);
     expect(guards).toBeDefined();
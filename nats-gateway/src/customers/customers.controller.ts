@@ -8,12 +8,21 @@ import {
   Patch,
   Post,
   UseGuards,
+  Query,
+  DefaultValuePipe,
+  ParseIntPipe,
+  Req,
 } from '@nestjs/common';
 import { ClientProxy } from '@nestjs/microservices';
 import { CreateCustomerDto } from './dto/create-customer.dto';
 import { UpdateCustomerDto } from './dto/update-customer.dto';
-import { JwtAuthGuard } from 'src/guards/jwt.guard';
+import { FindCustomersDto } from './dto/find-customers.dto';
+import { JwtAuthGuard, withAuthorization } from 'src/guards/jwt.guard';
+import { reduce } from 'rxjs';
+import { Request } from 'express';
 
+// Every request forwards the caller's token; the customers service enforces
+// the customers:read, customers:write and customers:delete permissions.
 @UseGuards(JwtAuthGuard)
 @Controller('customers')
 export class CustomersController {
@@ -22,30 +31,62 @@ export class CustomersController {
   ) {}
 
   @Get('findAll')
-  getCustomers() {
-    return this.clientProxy.send('customers.findCustomers', '');
+  getCustomers(
+    @Query('page', new DefaultValuePipe(1), ParseIntPipe) page: number,
+    @Query('limit', new DefaultValuePipe(20), ParseIntPipe) limit: number,
+    @Query() query: FindCustomersDto,
+    @Req() req: Request,
+  ) {
+    return this.clientProxy.send(
+      'customers.findCustomers',
+      withAuthorization(req, { ...query, page, limit }),
+    );
+  }
+
+  // The customers service streams the export as several chunks.
+  @Get('export')
+  exportCustomers(@Query() query: FindCustomersDto, @Req() req: Request) {
+    return this.clientProxy
+      .send('customers.exportCustomers', withAuthorization(req, query))
+      .pipe(reduce((all, chunk) => all.concat(chunk), []));
   }
 
   @Get(':id')
-  getCustomer(@Param('id') id: string) {
-    return this.clientProxy.send('customers.findCustomer', id);
+  getCustomer(@Param('id') id: string, @Req() req: Request) {
+    return this.clientProxy.send(
+      'customers.findCustomer',
+      withAuthorization(req, id),
+    );
   }
 
   @Post('create')
-  createCustomer(@Body() createCustomerDto: CreateCustomerDto) {
-    return this.clientProxy.send('customers.createCustomer', createCustomerDto);
+  createCustomer(
+    @Body() createCustomerDto: CreateCustomerDto,
+    @Req() req: Request,
+  ) {
+    return this.clientProxy.send(
+      'customers.createCustomer',
+      withAuthorization(req, createCustomerDto),
+    );
   }
 
   @Patch('update/:id')
-  updateCustomer(@Body() data: UpdateCustomerDto, @Param('id') id: string) {
-    return this.clientProxy.send('customers.updateCustomer', {
-      customer: data,
-      id,
-    });
+  updateCustomer(
+    @Body() data: UpdateCustomerDto,
+    @Param('id') id: string,
+    @Req() req: Request,
+  ) {
+    return this.clientProxy.send(
+      'customers.updateCustomer',
+      withAuthorization(req, { customer: data, id }),
+    );
   }
 
   @Delete('delete/:id')
-  deleteCustomer() {
-    return this.clientProxy.send('customers.deleteCustomer', '');
+  deleteCustomer(@Param('id') id: string, @Req() req: Request) {
+    return this.clientProxy.send(
+      'customers.deleteCustomer',
+      withAuthorization(req, id),
+    );
   }
 }
@@ -0,0 +1,39 @@
+import { IsIn, IsOptional, IsString } from 'class-validator';
+
+export class FindCustomersDto {
+  @IsOptional()
+  @IsString()
+  cursor?: string;
+
+  @IsOptional()
+  @IsIn(['customer_id', 'contact_name', 'city', 'country'])
+  sort?: string;
+
+  @IsOptional()
+  @IsIn(['asc', 'desc'])
+  order?: string;
+
+  @IsOptional()
+  @IsString()
+  city?: string;
+
+  @IsOptional()
+  @IsString()
+  city_prefix?: string;
+
+  @IsOptional()
+  @IsString()
+  country?: string;
+
+  @IsOptional()
+  @IsString()
+  country_prefix?: string;
+
+  @IsOptional()
+  @IsString()
+  contact_name?: string;
+
+  @IsOptional()
+  @IsString()
+  contact_name_prefix?: string;
+}
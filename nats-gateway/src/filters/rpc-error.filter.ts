@@ -0,0 +1,67 @@
+import {
+  ArgumentsHost,
+  Catch,
+  HttpException,
+  HttpStatus,
+} from '@nestjs/common';
+import { BaseExceptionFilter } from '@nestjs/core';
+import { Response } from 'express';
+
+// Error object sent by the Go services in the NATS envelope's `err` field.
+export interface RpcError {
+  code: string;
+  message: string;
+  details?: unknown;
+  retryable?: boolean;
+}
+
+const STATUS_BY_CODE: Record<string, HttpStatus> = {
+  BAD_REQUEST: HttpStatus.BAD_REQUEST,
+  VALIDATION: HttpStatus.UNPROCESSABLE_ENTITY,
+  UNAUTHENTICATED: HttpStatus.UNAUTHORIZED,
+  FORBIDDEN: HttpStatus.FORBIDDEN,
+  ACCOUNT_LOCKED: HttpStatus.TOO_MANY_REQUESTS,
+  NOT_FOUND: HttpStatus.NOT_FOUND,
+  CONFLICT: HttpStatus.CONFLICT,
+  TIMEOUT: HttpStatus.GATEWAY_TIMEOUT,
+  UNAVAILABLE: HttpStatus.SERVICE_UNAVAILABLE,
+  INTERNAL: HttpStatus.INTERNAL_SERVER_ERROR,
+};
+
+export function isRpcError(err: unknown): err is RpcError {
+  return (
+    typeof err === 'object' &&
+    err !== null &&
+    typeof (err as RpcError).code === 'string' &&
+    (err as RpcError).code in STATUS_BY_CODE
+  );
+}
+
+export function rpcErrorStatus(err: unknown): HttpStatus {
+  return isRpcError(err)
+    ? STATUS_BY_CODE[err.code]
+    : HttpStatus.INTERNAL_SERVER_ERROR;
+}
+
+// sendRpcError answers with the status for err, plus Retry-After when the
+// service said how long to wait (e.g. ACCOUNT_LOCKED).
+export function sendRpcError(res: Response, err: unknown) {
+  const retryAfter = isRpcError(err)
+    ? (err.details as { retry_after?: number } | undefined)?.retry_after
+    : undefined;
+  if (retryAfter) {
+    res.setHeader('Retry-After', String(retryAfter));
+  }
+  return res.status(rpcErrorStatus(err)).send(err);
+}
+
+@Catch()
+export class RpcErrorFilter extends BaseExceptionFilter {
+  catch(exception: unknown, host: ArgumentsHost) {
+    if (exception instanceof HttpException || !isRpcError(exception)) {
+      return super.catch(exception, host);
+    }
+
+    return sendRpcError(host.switchToHttp().getResponse<Response>(), exception);
+  }
+}
@@ -0,0 +1,73 @@
+import { ExecutionContext, UnauthorizedException } from '@nestjs/common';
+import { of, throwError } from 'rxjs';
+import { JwtAuthGuard } from './jwt.guard';
+
+const mockClientProxy = {
+  send: jest.fn(),
+};
+
+const contextFor = (req: any): ExecutionContext =>
+  ({
+    switchToHttp: () => ({ getRequest: () => req }),
+  }) as ExecutionContext;
+
+describe('JwtAuthGuard', () => {
+  let guard: JwtAuthGuard;
+
+  beforeEach(() => {
+    guard = new JwtAuthGuard(mockClientProxy as any);
+    jest.clearAllMocks();
+  });
+
+  it('introspects the bearer token and attaches the user', async () => {
+    mockClientProxy.send.mockReturnValue(
+      of({
+        active: true,
+        sub: 'u1',
+        email: 'a@b.com',
+        roles: ['admin'],
+        scopes: ['customers:read'],
+      }),
+    );
+    const req: any = { headers: { authorization: 'Bearer abc.def' } };
+
+    await expect(guard.canActivate(contextFor(req))).resolves.toBe(true);
+
+    expect(mockClientProxy.send).toHaveBeenCalledWith(
+      'auth.validateToken',
+      expect.objectContaining({ data: { token: 'abc.def' } }),
+    );
+    expect(req.user).toEqual({
+      userId: 'u1',
+      email: 'a@b.com',
+      roles: ['admin'],
+      scopes: ['customers:read'],
+    });
+  });
+
+  it('rejects requests without a bearer token', async () => {
+    const req: any = { headers: {} };
+
+    await expect(guard.canActivate(contextFor(req))).rejects.toBeInstanceOf(
+      UnauthorizedException,
+    );
+    expect(mockClientProxy.send).not.toHaveBeenCalled();
+  });
+
+  it('rejects inactive tokens', async () => {
+    mockClientProxy.send.mockReturnValue(of({ active: false }));
+    const req: any = { headers: { authorization: 'Bearer revoked' } };
+
+    await expect(guard.canActivate(contextFor(req))).rejects.toBeInstanceOf(
+      UnauthorizedException,
+    );
+  });
+
+  it('propagates auth service failures', async () => {
+    const rpcError = { code: 'UNAVAILABLE', message: 'down' };
+    mockClientProxy.send.mockReturnValue(throwError(() => rpcError));
+    const req: any = { headers: { authorization: 'Bearer abc' } };
+
+    await expect(guard.canActivate(contextFor(req))).rejects.toBe(rpcError);
+  });
+});
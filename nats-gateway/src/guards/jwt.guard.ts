@@ -1,11 +1,68 @@
-import { ExecutionContext, Injectable } from '@nestjs/common';
-import { AuthGuard } from '@nestjs/passport';
+import {
+  CanActivate,
+  ExecutionContext,
+  Inject,
+  Injectable,
+  UnauthorizedException,
+} from '@nestjs/common';
+import { ClientProxy, NatsRecord } from '@nestjs/microservices';
+import { Request } from 'express';
+import { headers } from 'nats';
+import { firstValueFrom } from 'rxjs';
+import { rpcRecord } from 'src/rpc';
 
+export interface AuthenticatedUser {
+  userId: string;
+  email: string;
+  roles: string[];
+  scopes: string[];
+}
+
+export function bearerToken(req: Request): string | undefined {
+  const [scheme, token] = (req.headers.authorization ?? '').split(' ');
+  return scheme?.toLowerCase() === 'bearer' && token ? token : undefined;
+}
+
+// withAuthorization wraps data in a NATS record that carries the caller's
+// bearer token, so the Go services can check permissions on their side.
+export function withAuthorization<T>(req: Request, data: T): NatsRecord<T> {
+  const h = headers();
+  const token = bearerToken(req);
+  if (token) {
+    h.set('Authorization', `Bearer ${token}`);
+  }
+  return rpcRecord(data, h);
+}
+
+// JwtAuthGuard asks the auth service to introspect the bearer token, so the
+// gateway never holds the signing secret and revoked tokens are refused.
 @Injectable()
-export class JwtAuthGuard extends AuthGuard('jwt') {
-  canActivate(context: ExecutionContext) {
-    // Add your custom authentication logic here
-    // for example, call super.logIn(request) to establish a session.
-    return super.canActivate(context);
+export class JwtAuthGuard implements CanActivate {
+  constructor(
+    @Inject('NATS_SERVICE') private readonly clientProxy: ClientProxy,
+  ) {}
+
+  async canActivate(context: ExecutionContext): Promise<boolean> {
+    const req = context.switchToHttp().getRequest();
+    const token = bearerToken(req);
+    if (!token) {
+      throw new UnauthorizedException();
+    }
+
+    const result = await firstValueFrom(
+      this.clientProxy.send('auth.validateToken', rpcRecord({ token })),
+    );
+    if (!result?.active) {
+      throw new UnauthorizedException();
+    }
+
+    const user: AuthenticatedUser = {
+      userId: result.sub,
+      email: result.email,
+      roles: result.roles ?? [],
+      scopes: result.scopes ?? [],
+    };
+    req.user = user;
+    return true;
   }
 }
@@ -1,16 +1,21 @@
-import { NestFactory } from '@nestjs/core';
+import { HttpAdapterHost, NestFactory } from '@nestjs/core';
 import { AppModule } from './app.module';
 import { ValidationPipe } from '@nestjs/common';
+import { RpcErrorFilter } from './filters/rpc-error.filter';
+import { REQUEST_TIMEOUT_MS } from './rpc';
 
 async function bootstrap() {
   const app = await NestFactory.create(AppModule);
   app.useGlobalPipes(new ValidationPipe());
+  const { httpAdapter } = app.get(HttpAdapterHost);
+  app.useGlobalFilters(new RpcErrorFilter(httpAdapter));
 
   const server = await app.listen(3000);
 
-  // Set timeout on the underlying HTTP server
-  server.setTimeout(15000);
-  server.headersTimeout = 16000;
+  // Set timeout on the underlying HTTP server; the services get the same
+  // deadline in the Nats-Rpc-Timeout header.
+  server.setTimeout(REQUEST_TIMEOUT_MS);
+  server.headersTimeout = REQUEST_TIMEOUT_MS + 1000;
 
   console.log('[GATEWAY] ✓ Server listening on port 3000');
   console.log('[GATEWAY] Request timeout: 15s, Headers timeout: 16s');
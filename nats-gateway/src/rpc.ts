@@ -0,0 +1,13 @@
+import { NatsRecord, NatsRecordBuilder } from '@nestjs/microservices';
+import { headers, MsgHdrs } from 'nats';
+
+// REQUEST_TIMEOUT_MS is how long the gateway lets an HTTP request run.
+export const REQUEST_TIMEOUT_MS = 15000;
+
+// rpcRecord wraps data in a NATS record whose Nats-Rpc-Timeout header
+// carries the gateway's deadline, so the Go services give up on a request
+// when the gateway does instead of after their own default.
+export function rpcRecord<T>(data: T, h: MsgHdrs = headers()): NatsRecord<T> {
+  h.set('Nats-Rpc-Timeout', String(REQUEST_TIMEOUT_MS));
+  return new NatsRecordBuilder(data).setHeaders(h).build();
+}